@@ -2,16 +2,16 @@ package commands
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"io"
-	"os"
+	"path/filepath"
+	"reflect"
+	"time"
 
-	"github.com/goccy/go-yaml"
 	"github.com/jessevdk/go-flags"
 
 	"github.com/nasa9084/gmac/gmail"
 	"github.com/nasa9084/gmac/log"
+	"github.com/nasa9084/gmac/state"
 )
 
 var applyCommand *flags.Command
@@ -21,91 +21,284 @@ func init() {
 }
 
 type ApplyCommand struct {
-	Target                string `short:"f" long:"filename" required:"yes"`
-	ApplyToExistingEmails bool   `short:"e" long:"apply-to-existing"`
+	Target                string        `short:"f" long:"filename" required:"yes"`
+	ApplyToExistingEmails bool          `short:"e" long:"apply-to-existing"`
+	PruneUnmanaged        bool          `long:"prune-unmanaged" description:"delete also the filters which are not managed by gmac, e.g. created in Gmail UI"`
+	State                 string        `long:"state" description:"state recording the filters managed by gmac: path to the state file, file://<path>, dir://<directory>[?name=<name>] or URL of custom backend (default: ~/.gmac/state.yml)"`
+	LockTimeout           time.Duration `long:"lock-timeout" default:"1m" description:"duration to retry acquiring the state lock, 0 to fail at once if it is locked"`
 }
 
 func (cmd *ApplyCommand) Execute([]string) error {
-	var r io.Reader
-	switch cmd.Target {
-	case "-":
-		r = os.Stdin
-	default:
-		f, err := os.Open(cmd.Target)
+	res, err := readResource(cmd.Target)
+	if err != nil {
+		return err
+	}
+
+	switch res.Kind {
+	case gmail.ResourceTypeFilter:
+		file, err := res.filterResource()
 		if err != nil {
 			return err
 		}
-		defer f.Close()
-
-		r = f
+		warnFilterIDs(cmd.Target, file.Filters)
+		return cmd.applyFilter(file.Filters)
 	}
 
-	var proxy struct {
-		Kind string         `yaml:"kind"`
-		Rest map[string]raw `yaml:",inline"`
+	return fmt.Errorf("unknown resource kind: %s", res.Kind)
+}
+
+// warnFilterIDs warns the filters which have id in the resource file.
+// id is written by get, but apply identifies filters by their criteria
+// and action, so it does not update the filter of the id.
+func warnFilterIDs(target string, filters []gmail.Filter) {
+	for i, f := range filters {
+		if f.ID != "" {
+			log.Printf("WARN: %s: filter #%d: id %s is ignored, as filters are identified by their criteria and action on apply", target, i+1, f.ID)
+		}
 	}
-	log.Println("unmarshalYAML")
-	if err := yaml.NewDecoder(r).Decode(&proxy); err != nil {
+}
+
+func (cmd *ApplyCommand) applyFilter(filters []gmail.Filter) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := newGmailClient(ctx, cmd.CredentialsFilePath(), cmd.RefreshToken())
+	if err != nil {
 		return err
 	}
-	if proxy.Kind == "" {
-		return errors.New("kind is not found")
-	}
 
-	switch proxy.Kind {
-	case gmail.ResourceTypeFilter:
-		return cmd.applyFilter(proxy.Rest["filters"])
+	backend, err := openStateBackend(cmd.State)
+	if err != nil {
+		return err
 	}
+	return applyFilters(ctx, c, backend, cmd.Target, filters, applyOptions{
+		operation:             "apply",
+		lockTimeout:           cmd.LockTimeout,
+		pruneUnmanaged:        cmd.PruneUnmanaged,
+		applyToExistingEmails: cmd.ApplyToExistingEmails,
+	})
+}
 
-	return fmt.Errorf("unknown resource kind: %s", proxy.Kind)
+type applyOptions struct {
+	// operation is the name of operation recorded in the state lock.
+	operation             string
+	lockTimeout           time.Duration
+	pruneUnmanaged        bool
+	applyToExistingEmails bool
 }
 
-func (cmd *ApplyCommand) applyFilter(data []byte) error {
-	if len(data) == 0 {
-		return errors.New("required key `filters` not found")
-	}
-	var filters []gmail.Filter
-	if err := yaml.Unmarshal(data, &filters); err != nil {
+// applyFilters applies the filters defined in the resource file of
+// given source, with locking the state.
+func applyFilters(ctx context.Context, c *gmail.Client, backend state.StateBackend, source string, filters []gmail.Filter, opts applyOptions) error {
+	unlock, err := lockState(ctx, backend, opts.operation, opts.lockTimeout)
+	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	oauthConfig, err := getOAuthConfig(cmd.CredentialsFilePath())
+	st, err := backend.Load(ctx)
 	if err != nil {
 		return err
 	}
 
-	token, err := getToken(cmd.RefreshToken())
+	live, err := c.ListFilters(ctx)
 	if err != nil {
 		return err
 	}
-
-	c, err := gmail.New(ctx, oauthConfig, token)
+	plan, err := planApply(live, filters, st, c.IsSameFilter, opts.pruneUnmanaged)
 	if err != nil {
 		return err
 	}
+	for i := range plan.keep {
+		plan.keep[i].Source = source
+	}
 
-	log.Printf("Delete all filters...")
-	if err := c.DeleteAllFilter(ctx); err != nil {
-		return err
+	// the state is saved even if applying fails, so that the filters
+	// created so far are managed in the next run
+	next, err := executeApplyPlan(ctx, c, plan, st, source, opts.applyToExistingEmails)
+	if saveErr := backend.Save(ctx, next); saveErr != nil && err == nil {
+		err = saveErr
 	}
-	for _, filter := range filters {
+	return err
+}
+
+// filterWriter is the part of *gmail.Client which changes filters in
+// Gmail. It is replaceable for testing purpose.
+type filterWriter interface {
+	CreateFilter(ctx context.Context, filter gmail.Filter) (string, error)
+	DeleteFilterByID(ctx context.Context, id string) error
+	ApplyLabelToExistingEmail(ctx context.Context, filter gmail.Filter) error
+}
+
+// executeApplyPlan makes the changes of the plan, then returns the next
+// state. The next state is returned even if an error occurs, and has the
+// managed filters which are not deleted yet, so that they are deleted in
+// the next run.
+func executeApplyPlan(ctx context.Context, c filterWriter, plan applyPlan, st *state.State, source string, applyToExistingEmails bool) (*state.State, error) {
+	next := state.New()
+	next.Filters = append(next.Filters, plan.keep...)
+	for _, filter := range plan.delete {
+		if e, ok := st.Lookup(filter.ID); ok {
+			next.Filters = append(next.Filters, e)
+		}
+	}
+
+	for _, filter := range plan.delete {
+		log.Printf("Delete filter: %s", filter.String())
+		if err := c.DeleteFilterByID(ctx, filter.ID); err != nil {
+			return next, err
+		}
+		next.Remove(filter.ID)
+	}
+	for _, filter := range plan.create {
 		log.Printf("Create filter: %s", filter.String())
-		if err := c.CreateFilter(ctx, filter); err != nil {
-			return err
+		id, err := c.CreateFilter(ctx, filter)
+		if err != nil {
+			return next, err
 		}
+		entry := state.Entry{Filter: filter, Source: source}
+		entry.ID = id
+		next.Filters = append(next.Filters, entry)
 
-		if cmd.ApplyToExistingEmails {
+		if applyToExistingEmails {
 			log.Printf("Apply filter %s to existing emails", filter.String())
 			if err := c.ApplyLabelToExistingEmail(ctx, filter); err != nil {
-				return err
+				return next, err
+			}
+		}
+	}
+	return next, nil
+}
+
+// applyPlan is the changes to be made by apply.
+type applyPlan struct {
+	// keep is the filters in Gmail which are kept as they are. They are
+	// managed by gmac after applying.
+	keep []state.Entry
+	// create is the filters to be created.
+	create []gmail.Filter
+	// delete is the filters in Gmail to be deleted.
+	delete []gmail.Filter
+	// unmanaged is the filters in Gmail which are not managed by gmac,
+	// and are left as they are.
+	unmanaged []gmail.Filter
+}
+
+// planApply compares the filters in Gmail with the filters to be applied.
+//
+// A filter to be applied is kept if a managed filter is created from the
+// same definition, or a filter in Gmail is the same as it, which is
+// adopted to be managed. Otherwise, it is created. The managed filters
+// which are not kept are deleted, and the unmanaged filters are deleted
+// only if pruneUnmanaged is true.
+func planApply(live, filters []gmail.Filter, st *state.State, same func(live, filter gmail.Filter) (bool, error), pruneUnmanaged bool) (applyPlan, error) {
+	var plan applyPlan
+	used := make([]bool, len(live))
+	find := func(match func(i int) (bool, error)) (int, error) {
+		for i := range live {
+			if used[i] {
+				continue
+			}
+			ok, err := match(i)
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				used[i] = true
+				return i, nil
 			}
 		}
+		return -1, nil
 	}
 
-	return nil
+	for _, filter := range filters {
+		i, err := find(func(i int) (bool, error) {
+			e, ok := st.Lookup(live[i].ID)
+			return ok && reflect.DeepEqual(e.Criteria, filter.Criteria) && reflect.DeepEqual(e.Action, filter.Action), nil
+		})
+		if err != nil {
+			return applyPlan{}, err
+		}
+		if i < 0 {
+			i, err = find(func(i int) (bool, error) { return same(live[i], filter) })
+			if err != nil {
+				return applyPlan{}, err
+			}
+		}
+		if i < 0 {
+			filter.ID = ""
+			plan.create = append(plan.create, filter)
+			continue
+		}
+		entry := state.Entry{Filter: filter}
+		entry.ID = live[i].ID
+		plan.keep = append(plan.keep, entry)
+	}
+
+	for i, f := range live {
+		switch {
+		case used[i]:
+		case pruneUnmanaged || st.IsManaged(f.ID):
+			plan.delete = append(plan.delete, f)
+		default:
+			plan.unmanaged = append(plan.unmanaged, f)
+		}
+	}
+	return plan, nil
+}
+
+// lockState acquires the lock of the state, retrying until lockTimeout.
+// The returned function releases the lock.
+func lockState(ctx context.Context, backend state.StateBackend, operation string, lockTimeout time.Duration) (unlock func(), err error) {
+	lockCtx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+	release, err := backend.Lock(lockCtx, state.NewLockInfo(operation))
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := release(); err != nil {
+			log.Printf("failed to unlock state: %v", err)
+		}
+	}, nil
+}
+
+// updateState calls update with the state of given location locked, so
+// that filters are not changed while apply is running. The state is
+// saved if update changes it, even if update returns an error, so that
+// the changes made so far are recorded.
+func updateState(ctx context.Context, location, operation string, lockTimeout time.Duration, update func(st *state.State) error) error {
+	backend, err := openStateBackend(location)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockState(ctx, backend, operation, lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	st, err := backend.Load(ctx)
+	if err != nil {
+		return err
+	}
+	before := append([]state.Entry{}, st.Filters...)
+	err = update(st)
+	if after := append([]state.Entry{}, st.Filters...); !reflect.DeepEqual(after, before) {
+		if saveErr := backend.Save(ctx, st); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	return err
+}
+
+// openStateBackend returns the backend of the state at given location,
+// which is the state file in the config directory by default.
+func openStateBackend(location string) (state.StateBackend, error) {
+	if location == "" {
+		return state.NewFileBackend(filepath.Join(configDir, "state.yml")), nil
+	}
+	return state.OpenBackend(location)
 }
 
 func (*ApplyCommand) CredentialsFilePath() string {
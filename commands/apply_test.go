@@ -0,0 +1,262 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/state"
+)
+
+func TestPlanApply(t *testing.T) {
+	fooFilter := gmail.Filter{
+		Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+		Action:   gmail.FilterAction{AddLabel: "Foo"},
+	}
+	barFilter := gmail.Filter{
+		Criteria: gmail.FilterCriteria{From: gmail.Terms{"bar@example.com"}},
+		Action:   gmail.FilterAction{Archive: true},
+	}
+	bazFilter := gmail.Filter{
+		Criteria: gmail.FilterCriteria{Subject: gmail.Terms{"baz"}},
+		Action:   gmail.FilterAction{Star: true},
+	}
+	withID := func(f gmail.Filter, id string) gmail.Filter {
+		f.ID = id
+		return f
+	}
+	entry := func(f gmail.Filter, id string) state.Entry {
+		return state.Entry{Filter: withID(f, id)}
+	}
+	// same compares filters by their criteria and action, as Gmail does
+	same := func(live, filter gmail.Filter) (bool, error) {
+		return reflect.DeepEqual(live.Criteria, filter.Criteria) && reflect.DeepEqual(live.Action, filter.Action), nil
+	}
+
+	tests := []struct {
+		label          string
+		live           []gmail.Filter
+		filters        []gmail.Filter
+		state          []state.Entry
+		pruneUnmanaged bool
+		want           applyPlan
+	}{
+		{
+			label:   "create new filters",
+			filters: []gmail.Filter{fooFilter, barFilter},
+			want: applyPlan{
+				create: []gmail.Filter{fooFilter, barFilter},
+			},
+		},
+		{
+			label:   "keep managed filters and delete removed ones",
+			live:    []gmail.Filter{withID(fooFilter, "id1"), withID(barFilter, "id2")},
+			filters: []gmail.Filter{fooFilter},
+			state:   []state.Entry{entry(fooFilter, "id1"), entry(barFilter, "id2")},
+			want: applyPlan{
+				keep:   []state.Entry{entry(fooFilter, "id1")},
+				delete: []gmail.Filter{withID(barFilter, "id2")},
+			},
+		},
+		{
+			label:   "replace changed filters",
+			live:    []gmail.Filter{withID(fooFilter, "id1")},
+			filters: []gmail.Filter{bazFilter},
+			state:   []state.Entry{entry(fooFilter, "id1")},
+			want: applyPlan{
+				create: []gmail.Filter{bazFilter},
+				delete: []gmail.Filter{withID(fooFilter, "id1")},
+			},
+		},
+		{
+			label:   "leave unmanaged filters",
+			live:    []gmail.Filter{withID(fooFilter, "id1"), withID(barFilter, "ui")},
+			filters: []gmail.Filter{bazFilter},
+			state:   []state.Entry{entry(fooFilter, "id1")},
+			want: applyPlan{
+				create:    []gmail.Filter{bazFilter},
+				delete:    []gmail.Filter{withID(fooFilter, "id1")},
+				unmanaged: []gmail.Filter{withID(barFilter, "ui")},
+			},
+		},
+		{
+			label:          "prune unmanaged filters",
+			live:           []gmail.Filter{withID(fooFilter, "id1"), withID(barFilter, "ui")},
+			filters:        []gmail.Filter{fooFilter},
+			state:          []state.Entry{entry(fooFilter, "id1")},
+			pruneUnmanaged: true,
+			want: applyPlan{
+				keep:   []state.Entry{entry(fooFilter, "id1")},
+				delete: []gmail.Filter{withID(barFilter, "ui")},
+			},
+		},
+		{
+			label:   "adopt the same unmanaged filters",
+			live:    []gmail.Filter{withID(fooFilter, "ui")},
+			filters: []gmail.Filter{fooFilter, barFilter},
+			want: applyPlan{
+				keep:   []state.Entry{entry(fooFilter, "ui")},
+				create: []gmail.Filter{barFilter},
+			},
+		},
+		{
+			label:   "ignore ids in resource file",
+			filters: []gmail.Filter{withID(fooFilter, "id1")},
+			want: applyPlan{
+				create: []gmail.Filter{fooFilter},
+			},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			st := state.New()
+			st.Filters = tt.state
+			got, err := planApply(tt.live, tt.filters, st, same, tt.pruneUnmanaged)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected plan:\n  got:  %+v\n  want: %+v", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+// fakeFilterWriter records the changes instead of making them in Gmail.
+type fakeFilterWriter struct {
+	created   []gmail.Filter
+	deleted   []string
+	failingID string
+}
+
+func (w *fakeFilterWriter) CreateFilter(_ context.Context, filter gmail.Filter) (string, error) {
+	w.created = append(w.created, filter)
+	return "new" + strconv.Itoa(len(w.created)), nil
+}
+
+func (w *fakeFilterWriter) DeleteFilterByID(_ context.Context, id string) error {
+	if id == w.failingID {
+		return errors.New("failed to delete")
+	}
+	w.deleted = append(w.deleted, id)
+	return nil
+}
+
+func (w *fakeFilterWriter) ApplyLabelToExistingEmail(context.Context, gmail.Filter) error {
+	return nil
+}
+
+func TestExecuteApplyPlan(t *testing.T) {
+	fooFilter := gmail.Filter{
+		Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+		Action:   gmail.FilterAction{AddLabel: "Foo"},
+	}
+	barFilter := gmail.Filter{
+		Criteria: gmail.FilterCriteria{From: gmail.Terms{"bar@example.com"}},
+		Action:   gmail.FilterAction{Archive: true},
+	}
+	bazFilter := gmail.Filter{
+		Criteria: gmail.FilterCriteria{Subject: gmail.Terms{"baz"}},
+		Action:   gmail.FilterAction{Star: true},
+	}
+	entry := func(f gmail.Filter, id string) state.Entry {
+		e := state.Entry{Filter: f, Source: "filters.yml"}
+		e.ID = id
+		return e
+	}
+	withID := func(f gmail.Filter, id string) gmail.Filter {
+		f.ID = id
+		return f
+	}
+	st := state.New()
+	st.Filters = []state.Entry{entry(fooFilter, "id1"), entry(barFilter, "id2"), entry(bazFilter, "id3")}
+	plan := applyPlan{
+		keep: []state.Entry{entry(fooFilter, "id1")},
+		// id4 is an unmanaged filter to be pruned
+		delete: []gmail.Filter{withID(barFilter, "id2"), withID(gmail.Filter{}, "id4"), withID(bazFilter, "id3")},
+		create: []gmail.Filter{bazFilter},
+	}
+
+	tests := []struct {
+		label     string
+		failingID string
+		want      []state.Entry
+		wantErr   bool
+	}{
+		{
+			label: "success",
+			want:  []state.Entry{entry(fooFilter, "id1"), entry(bazFilter, "new1")},
+		},
+		{
+			label:     "failing delete keeps filters to be deleted",
+			failingID: "id4",
+			want:      []state.Entry{entry(fooFilter, "id1"), entry(bazFilter, "id3")},
+			wantErr:   true,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			w := &fakeFilterWriter{failingID: tt.failingID}
+			next, err := executeApplyPlan(context.Background(), w, plan, st, "filters.yml", false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if !reflect.DeepEqual(next.Filters, tt.want) {
+				t.Errorf("unexpected state:\n  got:  %+v\n  want: %+v", next.Filters, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestUpdateState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gmac-state-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.yml")
+	ctx := context.Background()
+
+	// the state is not saved if it is not changed
+	if err := updateState(ctx, path, "test", 0, func(*state.State) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("state should not be saved: %v", err)
+		return
+	}
+
+	// the changes are saved even if an error occurs
+	entry := state.Entry{Filter: gmail.Filter{ID: "id1"}}
+	errUpdate := errors.New("failed")
+	err = updateState(ctx, path, "test", 0, func(st *state.State) error {
+		st.Filters = append(st.Filters, entry)
+		return errUpdate
+	})
+	if err != errUpdate {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	st, err := state.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(st.Filters, []state.Entry{entry}) {
+		t.Errorf("unexpected state: %+v", st.Filters)
+		return
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock should be released: %v", err)
+		return
+	}
+}
@@ -39,7 +39,7 @@ func init() {
 }
 
 type Command struct {
-	OutputFormat string `short:"o" long:"output" choice:"yaml" choice:"wide"`
+	OutputFormat string `short:"o" long:"output" description:"output format. one of: wide, yaml, json, xml, sieve, jsonpath=<template>, go-template=<template>, template=<file>"`
 
 	CredentialsFilePath string `short:"c" long:"credentials-file" description:"path to OAuth credentials file"`
 	RefreshToken        string `short:"t" long:"refresh-token" env:"GMAC_REFRESH_TOKEN" description:"OAuth reflesh token"`
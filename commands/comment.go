@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/lexer"
+	yamlparser "github.com/goccy/go-yaml/parser"
+	"github.com/goccy/go-yaml/token"
+)
+
+// comment is a comment in a YAML document, which is attached to the node
+// at the path so that it can be restored after the document is rewritten.
+type comment struct {
+	// path is the path to the node, whose elements are mapping keys
+	// (string) or sequence indices (int). nil path means the end of
+	// the document.
+	path []interface{}
+	// text is the comment without leading "#".
+	text string
+	// trailing is true if the comment follows the node on the same line.
+	// otherwise the comment is placed on the lines before the node.
+	trailing bool
+}
+
+// extractComments returns the comments in given YAML document.
+func extractComments(source []byte) ([]comment, error) {
+	f, err := yamlparser.ParseBytes(source, 0)
+	if err != nil {
+		return nil, err
+	}
+	// the first node on each line is the shallowest one, and is used for
+	// the comments on the lines before it. a trailing comment is for the
+	// first mapping key on the line if any.
+	firstPaths := map[int][]interface{}{}
+	keyPaths := map[int][]interface{}{}
+	for _, doc := range f.Docs {
+		walkNodes(doc.Body, nil, func(path []interface{}, pos *token.Position) {
+			line := pos.Line
+			if _, ok := firstPaths[line]; !ok {
+				firstPaths[line] = path
+			}
+			if _, isKey := path[len(path)-1].(string); isKey {
+				if _, ok := keyPaths[line]; !ok {
+					keyPaths[line] = path
+				}
+			}
+		})
+	}
+
+	var comments []comment
+	// leading comments waiting for the next node
+	var leading []comment
+	contentLines := map[int]bool{}
+	for _, tk := range lexer.Tokenize(string(source)) {
+		line := tk.Position.Line
+		if tk.Type != token.CommentType {
+			if !contentLines[line] {
+				contentLines[line] = true
+				if path, ok := firstPaths[line]; ok {
+					for _, c := range leading {
+						c.path = path
+						comments = append(comments, c)
+					}
+					leading = nil
+				}
+			}
+			continue
+		}
+		text := strings.TrimRight(tk.Value, " \t\r")
+		if !contentLines[line] {
+			leading = append(leading, comment{text: text})
+			continue
+		}
+		path, ok := keyPaths[line]
+		if !ok {
+			path = firstPaths[line]
+		}
+		comments = append(comments, comment{path: path, text: text, trailing: true})
+	}
+	return append(comments, leading...), nil
+}
+
+// insertComments inserts comments into given YAML document.
+// If the node of a comment is not found, the comment is attached to the
+// nearest ancestor.
+func insertComments(source []byte, comments []comment) ([]byte, error) {
+	if len(comments) == 0 {
+		return source, nil
+	}
+	f, err := yamlparser.ParseBytes(source, 0)
+	if err != nil {
+		return nil, err
+	}
+	pathLines := map[string]int{}
+	for _, doc := range f.Docs {
+		walkNodes(doc.Body, nil, func(path []interface{}, pos *token.Position) {
+			if _, ok := pathLines[pathKey(path)]; !ok {
+				pathLines[pathKey(path)] = pos.Line
+			}
+		})
+	}
+
+	leading := map[int][]string{}
+	trailing := map[int]string{}
+	var footer []string
+	for _, c := range comments {
+		line := 0
+		for path := c.path; len(path) > 0; path = path[:len(path)-1] {
+			if l, ok := pathLines[pathKey(path)]; ok {
+				line = l
+				break
+			}
+		}
+		switch {
+		case line == 0:
+			footer = append(footer, c.text)
+		case c.trailing && trailing[line] == "":
+			trailing[line] = c.text
+		default:
+			leading[line] = append(leading[line], c.text)
+		}
+	}
+
+	var b strings.Builder
+	lines := strings.Split(strings.TrimSuffix(string(source), "\n"), "\n")
+	for i, l := range lines {
+		indent := l[:len(l)-len(strings.TrimLeft(l, " "))]
+		for _, text := range leading[i+1] {
+			b.WriteString(indent + "#" + text + "\n")
+		}
+		b.WriteString(l)
+		if text := trailing[i+1]; text != "" {
+			b.WriteString(" #" + text)
+		}
+		b.WriteString("\n")
+	}
+	for _, text := range footer {
+		b.WriteString("#" + text + "\n")
+	}
+	return []byte(b.String()), nil
+}
+
+func pathKey(path []interface{}) string {
+	elems := make([]string, 0, len(path))
+	for _, elem := range path {
+		elems = append(elems, fmt.Sprint(elem))
+	}
+	return strings.Join(elems, "\x00")
+}
+
+// walkNodes calls f for each mapping key and sequence item with its path
+// and the position where it starts, in the document order.
+func walkNodes(node ast.Node, path []interface{}, f func(path []interface{}, pos *token.Position)) {
+	switch n := node.(type) {
+	case *ast.AnchorNode:
+		walkNodes(n.Value, path, f)
+	case *ast.MappingNode:
+		for _, v := range n.Values {
+			walkNodes(v, path, f)
+		}
+	case *ast.MappingValueNode:
+		p := appendPath(path, n.Key.String())
+		f(p, n.Key.GetToken().Position)
+		walkNodes(n.Value, p, f)
+	case *ast.SequenceNode:
+		for i, v := range n.Values {
+			p := appendPath(path, i)
+			f(p, startPosition(v))
+			walkNodes(v, p, f)
+		}
+	}
+}
+
+func appendPath(path []interface{}, elem interface{}) []interface{} {
+	p := make([]interface{}, 0, len(path)+1)
+	p = append(p, path...)
+	return append(p, elem)
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/log"
+	"github.com/nasa9084/gmac/query"
+	"github.com/nasa9084/gmac/state"
+)
+
+var (
+	createCommand       *flags.Command
+	createFilterCommand *flags.Command
+)
+
+func init() {
+	createCommand = must(parser.AddCommand("create", "Create a resource", "Create a resource", &CreateCommand{}))
+	createFilterCommand = must(createCommand.AddCommand("filter", "Create a filter", "Create a filter from the flags", &CreateFilterCommand{}))
+}
+
+type CreateCommand struct {
+}
+
+type CreateFilterCommand struct {
+	From          []string `long:"from" description:"sender's display name or email address. can be specified multiple times to match any of them"`
+	To            []string `long:"to" description:"recipient's display name or email address. can be specified multiple times to match any of them"`
+	Subject       []string `long:"subject" description:"words in the subject. can be specified multiple times to match any of them"`
+	Query         string   `long:"query" description:"search query which messages match"`
+	NegatedQuery  string   `long:"negated-query" description:"search query which messages do not match"`
+	LargerThan    int64    `long:"larger-than" description:"size of messages in bytes which messages are larger than"`
+	SmallerThan   int64    `long:"smaller-than" description:"size of messages in bytes which messages are smaller than"`
+	HasAttachment bool     `long:"has-attachment" description:"match messages which have any attachment"`
+	ExcludeChats  bool     `long:"exclude-chats" description:"exclude chats"`
+
+	Archive         bool   `long:"archive" description:"archive the messages"`
+	MarkAsRead      bool   `long:"mark-as-read" description:"mark the messages as read"`
+	Star            bool   `long:"star" description:"star the messages"`
+	AddLabel        string `long:"add-label" description:"label to be added to the messages"`
+	ForwardTo       string `long:"forward-to" description:"email address which the messages are forwarded to"`
+	Delete          bool   `long:"delete" description:"delete the messages"`
+	NeverMarkAsSpam bool   `long:"never-mark-as-spam" description:"never mark the messages as spam"`
+	Important       string `long:"important" choice:"always" choice:"never" description:"mark the messages as important or never"`
+	Category        string `long:"category" description:"category of the messages"`
+
+	ApplyToExistingEmails bool `short:"e" long:"apply-to-existing" description:"apply the filter to existing emails"`
+
+	State       string        `long:"state" description:"state recording the filters managed by gmac, which is locked while creating the filter (default: ~/.gmac/state.yml)"`
+	LockTimeout time.Duration `long:"lock-timeout" default:"1m" description:"duration to retry acquiring the state lock, 0 to fail at once if it is locked"`
+}
+
+func (cmd *CreateFilterCommand) Execute([]string) error {
+	filter, err := cmd.filter()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := newGmailClient(ctx, cmd.CredentialsFilePath(), cmd.RefreshToken())
+	if err != nil {
+		return err
+	}
+
+	// the created filter is not managed, as it is not in any resource
+	// file, but the state is locked so that apply running concurrently
+	// does not miss it on adopting filters
+	err = updateState(ctx, cmd.State, "create", cmd.LockTimeout, func(*state.State) error {
+		log.Printf("Create filter: %s", filter.String())
+		id, err := c.CreateFilter(ctx, filter)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("filter %s created\n", id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if cmd.ApplyToExistingEmails {
+		log.Printf("Apply filter %s to existing emails", filter.String())
+		if err := c.ApplyLabelToExistingEmail(ctx, filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filter builds a filter from the flags.
+func (cmd *CreateFilterCommand) filter() (gmail.Filter, error) {
+	f := gmail.Filter{
+		Criteria: gmail.FilterCriteria{
+			From:          gmail.LiteralTerms(cmd.From...),
+			To:            gmail.LiteralTerms(cmd.To...),
+			Subject:       gmail.LiteralTerms(cmd.Subject...),
+			Query:         cmd.Query,
+			NegatedQuery:  cmd.NegatedQuery,
+			LargerThan:    cmd.LargerThan,
+			SmallerThan:   cmd.SmallerThan,
+			HasAttachment: cmd.HasAttachment,
+			ExcludeChats:  cmd.ExcludeChats,
+		},
+		Action: gmail.FilterAction{
+			Archive:         cmd.Archive,
+			MarkAsRead:      cmd.MarkAsRead,
+			Star:            cmd.Star,
+			AddLabel:        cmd.AddLabel,
+			ForwardTo:       cmd.ForwardTo,
+			Delete:          cmd.Delete,
+			NeverMarkAsSpam: cmd.NeverMarkAsSpam,
+			Important:       gmail.FilterActionImportant(cmd.Important),
+			Category:        cmd.Category,
+		},
+	}
+	if query.IsEmpty(f.Criteria.Node()) {
+		return f, errors.New("at least one criteria must be specified")
+	}
+	if f.Action == (gmail.FilterAction{}) {
+		return f, errors.New("at least one action must be specified")
+	}
+	if f.Criteria.LargerThan > 0 && f.Criteria.SmallerThan > 0 {
+		return f, errors.New("--larger-than and --smaller-than cannot be used together")
+	}
+	if _, _, err := f.Action.SystemLabelChanges(); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+func (*CreateFilterCommand) CredentialsFilePath() string {
+	val := createFilterCommand.FindOptionByLongName("credentials-file").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
+
+func (*CreateFilterCommand) RefreshToken() string {
+	val := createFilterCommand.FindOptionByLongName("refresh-token").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
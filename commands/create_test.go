@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+func TestCreateFilterCommandFilter(t *testing.T) {
+	tests := []struct {
+		label   string
+		cmd     CreateFilterCommand
+		want    gmail.Filter
+		wantErr string
+	}{
+		{
+			label: "valid",
+			cmd: CreateFilterCommand{
+				From:     []string{"foo@example.com", "bar@example.com"},
+				AddLabel: "Foo",
+				Archive:  true,
+			},
+			want: gmail.Filter{
+				Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com", "bar@example.com"}},
+				Action:   gmail.FilterAction{AddLabel: "Foo", Archive: true},
+			},
+		},
+		{
+			label: "single value is quoted",
+			cmd: CreateFilterCommand{
+				From:     []string{"John Doe"},
+				AddLabel: "John",
+			},
+			want: gmail.Filter{
+				Criteria: gmail.FilterCriteria{From: gmail.Terms{`"John Doe"`}},
+				Action:   gmail.FilterAction{AddLabel: "John"},
+			},
+		},
+		{
+			label:   "no criteria",
+			cmd:     CreateFilterCommand{Star: true},
+			wantErr: "at least one criteria must be specified",
+		},
+		{
+			label:   "no action",
+			cmd:     CreateFilterCommand{Query: "foo"},
+			wantErr: "at least one action must be specified",
+		},
+		{
+			label:   "size comparison",
+			cmd:     CreateFilterCommand{LargerThan: 1, SmallerThan: 2, Star: true},
+			wantErr: "--larger-than and --smaller-than cannot be used together",
+		},
+		{
+			label:   "unknown category",
+			cmd:     CreateFilterCommand{Query: "foo", Category: "bar"},
+			wantErr: "unknown action.category value: bar",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			got, err := tt.cmd.filter()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("%v != %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%+v != %+v", got, tt.want)
+				return
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/nasa9084/gmac/encoder"
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/log"
+	"github.com/nasa9084/gmac/state"
+)
+
+var (
+	deleteCommand       *flags.Command
+	deleteFilterCommand *flags.Command
+)
+
+func init() {
+	deleteCommand = must(parser.AddCommand("delete", "Delete resources", "Delete resources", &DeleteCommand{}))
+	deleteFilterCommand = must(deleteCommand.AddCommand("filter", "Delete filters", "Delete filters of given IDs, or filters matching the selector", &DeleteFilterCommand{}))
+	deleteFilterCommand.Aliases = []string{"filters"}
+}
+
+type DeleteCommand struct {
+}
+
+type DeleteFilterCommand struct {
+	Selector string `short:"l" long:"selector" description:"delete filters matching the selector, e.g. action.add_label=Foo,criteria.from=foo@example.com"`
+	Yes      bool   `short:"y" long:"yes" description:"delete filters without confirmation"`
+
+	State       string        `long:"state" description:"state recording the filters managed by gmac, which the deleted filters are removed from (default: ~/.gmac/state.yml)"`
+	LockTimeout time.Duration `long:"lock-timeout" default:"1m" description:"duration to retry acquiring the state lock, 0 to fail at once if it is locked"`
+}
+
+func (cmd *DeleteFilterCommand) Execute(args []string) error {
+	if len(args) == 0 && cmd.Selector == "" {
+		return errors.New("filter IDs or --selector must be specified")
+	}
+	if len(args) > 0 && cmd.Selector != "" {
+		return errors.New("filter IDs and --selector cannot be used together")
+	}
+	var sel selector
+	if cmd.Selector != "" {
+		var err error
+		sel, err = parseSelector(cmd.Selector)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := newGmailClient(ctx, cmd.CredentialsFilePath(), cmd.RefreshToken())
+	if err != nil {
+		return err
+	}
+
+	var filters []gmail.Filter
+	if sel != nil {
+		all, err := c.ListFilters(ctx)
+		if err != nil {
+			return err
+		}
+		filters, err = selectFilters(all, sel)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, id := range args {
+			filter, err := c.GetFilter(ctx, id)
+			if err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+			filters = append(filters, filter)
+		}
+	}
+	if len(filters) == 0 {
+		fmt.Println("No filters matched.")
+		return nil
+	}
+
+	enc, err := encoder.NewFilterEncoder(os.Stdout, "wide")
+	if err != nil {
+		return err
+	}
+	if err := enc.Encode(filters); err != nil {
+		return err
+	}
+	if !cmd.Yes {
+		ok, err := confirm(os.Stdout, fmt.Sprintf("Delete %d filters?", len(filters)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("canceled")
+		}
+	}
+
+	return updateState(ctx, cmd.State, "delete", cmd.LockTimeout, func(st *state.State) error {
+		for _, filter := range filters {
+			log.Printf("Delete filter: %s", filter.ID)
+			if err := c.DeleteFilterByID(ctx, filter.ID); err != nil {
+				return err
+			}
+			st.Remove(filter.ID)
+		}
+		return nil
+	})
+}
+
+func (*DeleteFilterCommand) CredentialsFilePath() string {
+	val := deleteFilterCommand.FindOptionByLongName("credentials-file").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
+
+func (*DeleteFilterCommand) RefreshToken() string {
+	val := deleteFilterCommand.FindOptionByLongName("refresh-token").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/log"
+)
+
+var (
+	describeCommand       *flags.Command
+	describeFilterCommand *flags.Command
+)
+
+func init() {
+	describeCommand = must(parser.AddCommand("describe", "Show details of resources", "Show details of resources", &DescribeCommand{}))
+	describeFilterCommand = must(describeCommand.AddCommand("filter", "Show details of filters", "Show the criteria, the action and the number of matching messages of filters of given IDs", &DescribeFilterCommand{}))
+	describeFilterCommand.Aliases = []string{"filters"}
+}
+
+type DescribeCommand struct {
+}
+
+type DescribeFilterCommand struct {
+	Exact bool `long:"exact" description:"count messages exactly by fetching all pages, instead of using the estimation"`
+}
+
+func (cmd *DescribeFilterCommand) Execute(args []string) error {
+	if len(args) == 0 {
+		return errors.New("filter IDs must be specified")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := newGmailClient(ctx, cmd.CredentialsFilePath(), cmd.RefreshToken())
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for i, id := range args {
+		filter, err := c.GetFilter(ctx, id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		q := filter.Criteria.String()
+		log.Vprintf("count messages: %s", q)
+		count, err := c.CountMessages(ctx, q, cmd.Exact)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		if err := describeFilter(&buf, filter, count, cmd.Exact); err != nil {
+			return err
+		}
+	}
+	_, err = buf.WriteTo(os.Stdout)
+	return err
+}
+
+// describeFilter writes the details of the filter in human readable
+// format.
+func describeFilter(w io.Writer, filter gmail.Filter, count int64, exact bool) error {
+	criteria, err := yaml.Marshal(filter.Criteria)
+	if err != nil {
+		return err
+	}
+	action, err := yaml.Marshal(filter.Action)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "ID:       %s\n", orNone(filter.ID))
+	fmt.Fprintf(w, "Query:    %s\n", filter.Criteria.String())
+	fmt.Fprintf(w, "Criteria:\n%s", indentLines(string(criteria), "  "))
+	fmt.Fprintf(w, "Action:\n%s", indentLines(string(action), "  "))
+	if add, remove := filter.LabelIDs(); add != nil || remove != nil {
+		fmt.Fprintf(w, "Label IDs:\n")
+		fmt.Fprintf(w, "  Add:    %s\n", orNone(strings.Join(add, ", ")))
+		fmt.Fprintf(w, "  Remove: %s\n", orNone(strings.Join(remove, ", ")))
+	}
+	if exact {
+		fmt.Fprintf(w, "Matches:  %d\n", count)
+	} else {
+		fmt.Fprintf(w, "Matches:  %d (estimated)\n", count)
+	}
+	return nil
+}
+
+func indentLines(s, indent string) string {
+	lines := strings.SplitAfter(s, "\n")
+	var b strings.Builder
+	for _, line := range lines {
+		if line == "" || line == "\n" {
+			b.WriteString(line)
+			continue
+		}
+		b.WriteString(indent + line)
+	}
+	return b.String()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+func (*DescribeFilterCommand) CredentialsFilePath() string {
+	val := describeFilterCommand.FindOptionByLongName("credentials-file").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
+
+func (*DescribeFilterCommand) RefreshToken() string {
+	val := describeFilterCommand.FindOptionByLongName("refresh-token").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+func TestDescribeFilter(t *testing.T) {
+	filter := gmail.Filter{
+		ID: "id1",
+		Criteria: gmail.FilterCriteria{
+			From:   gmail.Terms{"foo@example.com"},
+			NoneOf: []gmail.FilterCriteria{{Subject: gmail.Terms{"bar"}}},
+		},
+		Action: gmail.FilterAction{
+			AddLabel: "Foo",
+			Archive:  true,
+		},
+	}
+	want := `ID:       id1
+Query:    from:foo@example.com -subject:bar
+Criteria:
+  from: foo@example.com
+  none_of:
+  - subject: bar
+Action:
+  archive: true
+  add_label: Foo
+Matches:  12 (estimated)
+`
+	var buf bytes.Buffer
+	if err := describeFilter(&buf, filter, 12, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, want)
+		return
+	}
+}
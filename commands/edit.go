@@ -0,0 +1,382 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/nasa9084/gmac/encoder"
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/lint"
+	"github.com/nasa9084/gmac/log"
+	"github.com/nasa9084/gmac/state"
+)
+
+var (
+	editCommand       *flags.Command
+	editFilterCommand *flags.Command
+)
+
+func init() {
+	editCommand = must(parser.AddCommand("edit", "Edit resources", "Edit resources with the editor", &EditCommand{}))
+	editFilterCommand = must(editCommand.AddCommand("filter", "Edit filters", "Edit the current filters with $EDITOR, then apply the changes", &EditFilterCommand{}))
+	editFilterCommand.Aliases = []string{"filters"}
+}
+
+type EditCommand struct {
+}
+
+type EditFilterCommand struct {
+	DryRun      bool          `long:"dry-run" description:"show the changes without applying them"`
+	State       string        `long:"state" description:"state recording the filters managed by gmac, which is updated for the edited filters (default: ~/.gmac/state.yml)"`
+	LockTimeout time.Duration `long:"lock-timeout" default:"1m" description:"duration to retry acquiring the state lock, 0 to fail at once if it is locked"`
+}
+
+const editHeader = `# Please edit the filters below. Lines beginning with '#' will be ignored,
+# and an empty file will abort the edit. If an error occurs while saving,
+# this file will be reopened with the errors.
+# Filters are identified by id: remove a filter to delete it, and add
+# a filter without id to create it.
+#
+`
+
+// runEditor opens the file of given path with the editor, and waits
+// until the editor exits. It is replaceable for testing purpose.
+var runEditor = func(path string) error {
+	args := editorCommand()
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// editorCommand returns the command line of the editor, which is read
+// from $EDITOR.
+func editorCommand() []string {
+	if args := strings.Fields(os.Getenv("EDITOR")); len(args) > 0 {
+		return args
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+func (cmd *EditFilterCommand) Execute([]string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := newGmailClient(ctx, cmd.CredentialsFilePath(), cmd.RefreshToken())
+	if err != nil {
+		return err
+	}
+
+	original, err := c.ListFilters(ctx)
+	if err != nil {
+		return err
+	}
+
+	edited, ok, err := editFilters(original, runEditor)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Edit cancelled, no changes made.")
+		return nil
+	}
+	changes, err := planFilterChanges(original, edited)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("Edit cancelled, no changes made.")
+		return nil
+	}
+
+	if err := writeFilterChanges(os.Stdout, changes); err != nil {
+		return err
+	}
+	if cmd.DryRun {
+		return nil
+	}
+
+	return updateState(ctx, cmd.State, "edit", cmd.LockTimeout, func(st *state.State) error {
+		return applyFilterChanges(ctx, c, changes, st)
+	})
+}
+
+// applyFilterChanges makes the changes in Gmail, and updates the state.
+// A filter updated from a managed filter is managed with the edited
+// definition, so that the next apply restores the definition in the
+// resource file. Filters created by edit are not managed, as they are
+// not in any resource file.
+func applyFilterChanges(ctx context.Context, c filterWriter, changes []filterChange, st *state.State) error {
+	for _, change := range changes {
+		if change.new != nil {
+			log.Printf("Create filter: %s", change.new.String())
+			id, err := c.CreateFilter(ctx, *change.new)
+			if err != nil {
+				return err
+			}
+			if change.old != nil {
+				if e, ok := st.Lookup(change.old.ID); ok {
+					entry := state.Entry{Filter: *change.new, Source: e.Source}
+					entry.ID = id
+					st.Filters = append(st.Filters, entry)
+				}
+			}
+		}
+		if change.old != nil {
+			log.Printf("Delete filter: %s", change.old.ID)
+			if err := c.DeleteFilterByID(ctx, change.old.ID); err != nil {
+				return err
+			}
+			st.Remove(change.old.ID)
+		}
+	}
+	return nil
+}
+
+// editFilters lets the user edit the filters in YAML format with the
+// editor, then returns the edited filters. If the edited file is invalid,
+// the editor is opened again with the errors. ok is false if the file
+// is not changed or is emptied. If the invalid file is saved without
+// changes, the edit is aborted and the file is left for recovery.
+func editFilters(filters []gmail.Filter, edit func(path string) error) (edited []gmail.Filter, ok bool, err error) {
+	var buf bytes.Buffer
+	enc, err := encoder.NewFilterEncoder(&buf, "yaml")
+	if err != nil {
+		return nil, false, err
+	}
+	if err := enc.Encode(filters); err != nil {
+		return nil, false, err
+	}
+	original := buf.String()
+
+	f, err := ioutil.TempFile("", "gmac-edit-*.yml")
+	if err != nil {
+		return nil, false, err
+	}
+	path := f.Name()
+	f.Close()
+	keep := false
+	defer func() {
+		if !keep {
+			os.Remove(path)
+		}
+	}()
+
+	body := original
+	var errs []string
+	for {
+		var content strings.Builder
+		content.WriteString(editHeader)
+		for _, e := range errs {
+			content.WriteString("# error: " + e + "\n")
+		}
+		if len(errs) > 0 {
+			content.WriteString("#\n")
+		}
+		content.WriteString(body)
+		if err := ioutil.WriteFile(path, []byte(content.String()), 0600); err != nil {
+			return nil, false, err
+		}
+
+		if err := edit(path); err != nil {
+			return nil, false, fmt.Errorf("editor: %w", err)
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, false, err
+		}
+		previous := body
+		body = stripLeadingComments(string(b))
+		if strings.TrimSpace(body) == "" || body == original {
+			return nil, false, nil
+		}
+		if len(errs) > 0 && body == previous {
+			keep = true
+			return nil, false, fmt.Errorf("edit cancelled, no valid changes were saved; a copy of your changes has been stored to %s", path)
+		}
+
+		edited, errs = parseEditedFilters([]byte(body), filters)
+		if len(errs) == 0 {
+			return edited, true, nil
+		}
+	}
+}
+
+// stripLeadingComments removes the comment lines at the beginning of s,
+// which are written by gmac.
+func stripLeadingComments(s string) string {
+	for strings.HasPrefix(s, "#") {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			return ""
+		}
+		s = s[i+1:]
+	}
+	return s
+}
+
+// parseEditedFilters parses and validates the edited resource file.
+// All errors found are returned as messages.
+func parseEditedFilters(source []byte, original []gmail.Filter) ([]gmail.Filter, []string) {
+	located, err := validateResource(source)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	if len(located) > 0 {
+		errs := make([]string, 0, len(located))
+		for _, e := range located {
+			errs = append(errs, fmt.Sprintf("%d:%d: %s", e.line, e.column, e.ValidationError))
+		}
+		return nil, errs
+	}
+
+	res := resource{name: "filters", source: source}
+	if err := yaml.Unmarshal(source, &res); err != nil {
+		return nil, []string{err.Error()}
+	}
+	file, err := res.filterResource()
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	// the filters in Gmail may have lint errors, which are reported only
+	// if they are edited so that the user is not forced to fix them
+	var errs []string
+	problems, err := lint.Lint(file.Filters, nil, nil)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	changed := changedFilters(original, file.Filters)
+	for _, p := range problems {
+		if p.Severity == lint.SeverityError && changed[p.Filter] {
+			errs = append(errs, fmt.Sprintf("filter #%d: %s (%s)", p.Filter+1, p.Message, p.Rule))
+		}
+	}
+	if _, err := planFilterChanges(original, file.Filters); err != nil {
+		errs = append(errs, err.Error())
+	}
+	return file.Filters, errs
+}
+
+// changedFilters returns the indices of the edited filters which are new
+// or changed from the original ones.
+func changedFilters(original, edited []gmail.Filter) map[int]bool {
+	byID := map[string]gmail.Filter{}
+	for _, f := range original {
+		byID[f.ID] = f
+	}
+	changed := map[int]bool{}
+	for i, f := range edited {
+		old, ok := byID[f.ID]
+		if f.ID == "" || !ok || !reflect.DeepEqual(f.Criteria, old.Criteria) || !reflect.DeepEqual(f.Action, old.Action) {
+			changed[i] = true
+		}
+	}
+	return changed
+}
+
+// filterChange is a change of a filter. old is nil if the filter is
+// created, and new is nil if the filter is deleted. As filters cannot
+// be updated in Gmail, updated filters are created, then the old ones
+// are deleted.
+type filterChange struct {
+	old, new *gmail.Filter
+}
+
+// planFilterChanges compares the edited filters with the original ones
+// by their IDs, then returns the changes to be made.
+func planFilterChanges(original, edited []gmail.Filter) ([]filterChange, error) {
+	byID := map[string]gmail.Filter{}
+	for _, f := range edited {
+		if f.ID != "" {
+			byID[f.ID] = f
+		}
+	}
+
+	var changes []filterChange
+	known := map[string]bool{}
+	for i := range original {
+		old := original[i]
+		known[old.ID] = true
+		f, ok := byID[old.ID]
+		switch {
+		case !ok:
+			changes = append(changes, filterChange{old: &old})
+		case !reflect.DeepEqual(f.Criteria, old.Criteria) || !reflect.DeepEqual(f.Action, old.Action):
+			f := f
+			changes = append(changes, filterChange{old: &old, new: &f})
+		}
+	}
+
+	seen := map[string]bool{}
+	for i := range edited {
+		f := edited[i]
+		if f.ID == "" {
+			changes = append(changes, filterChange{new: &f})
+			continue
+		}
+		if !known[f.ID] {
+			return nil, fmt.Errorf("filter #%d: unknown id %s, remove id to create a new filter", i+1, f.ID)
+		}
+		if seen[f.ID] {
+			return nil, fmt.Errorf("filter #%d: duplicate id %s", i+1, f.ID)
+		}
+		seen[f.ID] = true
+	}
+	return changes, nil
+}
+
+func writeFilterChanges(w io.Writer, changes []filterChange) error {
+	var buf bytes.Buffer
+	for _, change := range changes {
+		switch {
+		case change.old == nil:
+			fmt.Fprintf(&buf, "create filter:\n")
+		case change.new == nil:
+			fmt.Fprintf(&buf, "delete filter %s:\n", change.old.ID)
+		default:
+			fmt.Fprintf(&buf, "update filter %s:\n", change.old.ID)
+		}
+		if change.old != nil {
+			fmt.Fprintf(&buf, "  - %s\n", change.old.String())
+		}
+		if change.new != nil {
+			fmt.Fprintf(&buf, "  + %s\n", change.new.String())
+		}
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func (*EditFilterCommand) CredentialsFilePath() string {
+	val := editFilterCommand.FindOptionByLongName("credentials-file").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
+
+func (*EditFilterCommand) RefreshToken() string {
+	val := editFilterCommand.FindOptionByLongName("refresh-token").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
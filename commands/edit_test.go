@@ -0,0 +1,325 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/state"
+)
+
+var editTestFilters = []gmail.Filter{
+	{
+		ID:       "id1",
+		Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+		Action:   gmail.FilterAction{AddLabel: "Foo"},
+	},
+	{
+		ID:       "id2",
+		Criteria: gmail.FilterCriteria{From: gmail.Terms{"bar@example.com"}},
+		Action:   gmail.FilterAction{Star: true},
+	},
+}
+
+func TestEditFilters(t *testing.T) {
+	tests := []struct {
+		label string
+		// edits are the contents written by the editor in order.
+		edits []string
+		// wantErrors are the error comments expected in each content
+		// opened by the editor.
+		wantErrors []string
+		wantOK     bool
+		want       string
+	}{
+		{
+			label:  "no changes",
+			edits:  []string{""},
+			wantOK: false,
+		},
+		{
+			label:  "emptied",
+			edits:  []string{"# only comments\n"},
+			wantOK: false,
+		},
+		{
+			label: "changed",
+			edits: []string{`kind: Filter
+filters:
+- id: id1
+  criteria:
+    from: foo@example.com
+  action:
+    add_label: Bar
+- criteria:
+    subject: baz
+  action:
+    archive: true
+`},
+			wantOK: true,
+			want: `update filter id1:
+  - from:foo@example.com => Apply label "Foo"
+  + from:foo@example.com => Apply label "Bar"
+delete filter id2:
+  - from:bar@example.com => Star it
+create filter:
+  + subject:baz => Skip Inbox
+`,
+		},
+		{
+			label: "reopened with errors",
+			edits: []string{
+				`kind: Filter
+filters:
+- id: id3
+  criteria:
+    from: foo@example.com
+  action:
+    add_labels: Bar
+`,
+				`kind: Filter
+filters:
+- id: id3
+  criteria:
+    from: foo@example.com
+  action:
+    add_label: Bar
+`,
+				`kind: Filter
+filters:
+- id: id1
+  criteria:
+    from: foo@example.com
+  action:
+    add_label: Bar
+`,
+			},
+			wantErrors: []string{
+				"",
+				"# error: 7:5: filters.0.action.add_labels: unknown key `add_labels`\n",
+				"# error: filter #1: unknown id id3, remove id to create a new filter\n",
+			},
+			wantOK: true,
+			want: `update filter id1:
+  - from:foo@example.com => Apply label "Foo"
+  + from:foo@example.com => Apply label "Bar"
+delete filter id2:
+  - from:bar@example.com => Star it
+`,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			n := 0
+			edit := func(path string) error {
+				b, err := ioutil.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				content := string(b)
+				if !strings.HasPrefix(content, editHeader) {
+					t.Errorf("header is not found:\n%s", content)
+				}
+				if n < len(tt.wantErrors) && tt.wantErrors[n] != "" && !strings.Contains(content, tt.wantErrors[n]) {
+					t.Errorf("error comment %q is not found:\n%s", tt.wantErrors[n], content)
+				}
+				if tt.edits[n] != "" {
+					content = tt.edits[n]
+				}
+				n++
+				return ioutil.WriteFile(path, []byte(content), 0600)
+			}
+			edited, ok, err := editFilters(editTestFilters, edit)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if n != len(tt.edits) {
+				t.Errorf("the editor is opened %d times, but %d times expected", n, len(tt.edits))
+				return
+			}
+			if ok != tt.wantOK {
+				t.Errorf("%t != %t", ok, tt.wantOK)
+				return
+			}
+			if !ok {
+				return
+			}
+			changes, err := planFilterChanges(editTestFilters, edited)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			var buf bytes.Buffer
+			if err := writeFilterChanges(&buf, changes); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("unexpected changes:\n%s\nwant:\n%s", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestEditFiltersUnchangedAfterError(t *testing.T) {
+	invalid := `kind: Filter
+filters:
+- criteria:
+    from: foo@example.com
+  action:
+    add_labels: Bar
+`
+	n := 0
+	var path string
+	edit := func(p string) error {
+		path = p
+		n++
+		if n > 1 {
+			// the editor exits without changes
+			return nil
+		}
+		return ioutil.WriteFile(p, []byte(invalid), 0600)
+	}
+	_, _, err := editFilters(editTestFilters, edit)
+	if err == nil {
+		t.Errorf("error is expected")
+		return
+	}
+	if !strings.Contains(err.Error(), "no valid changes were saved") {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if n != 2 {
+		t.Errorf("the editor is opened %d times, but 2 times expected", n)
+		return
+	}
+	defer os.Remove(path)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Errorf("the file is not left for recovery: %v", err)
+		return
+	}
+	if !strings.HasSuffix(string(b), invalid) {
+		t.Errorf("unexpected content of the file left:\n%s", b)
+		return
+	}
+}
+
+func TestParseEditedFiltersLint(t *testing.T) {
+	original := []gmail.Filter{
+		{
+			ID:       "id1",
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+			Action:   gmail.FilterAction{AddLabel: "Foo"},
+		},
+		{
+			// filters in Gmail may have lint errors
+			ID:       "id2",
+			Criteria: gmail.FilterCriteria{Query: "(bar"},
+			Action:   gmail.FilterAction{Star: true},
+		},
+	}
+	tests := []struct {
+		label  string
+		source string
+		want   []string
+	}{
+		{
+			label: "errors of unchanged filters are ignored",
+			source: `kind: Filter
+filters:
+- id: id1
+  criteria:
+    from: foo@example.com
+  action:
+    add_label: Bar
+- id: id2
+  criteria:
+    query: (bar
+  action:
+    star: true
+`,
+		},
+		{
+			label: "errors of changed filters are reported",
+			source: `kind: Filter
+filters:
+- id: id1
+  criteria:
+    query: (foo
+  action:
+    add_label: Foo
+- id: id2
+  criteria:
+    query: (bar
+  action:
+    star: true
+`,
+			want: []string{`filter #1: query: unclosed bracket at offset 0 in "(foo" (query-syntax)`},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			_, errs := parseEditedFilters([]byte(tt.source), original)
+			if strings.Join(errs, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("unexpected errors:\n  got:  %q\n  want: %q", errs, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestPlanFilterChangesError(t *testing.T) {
+	edited := []gmail.Filter{editTestFilters[0], editTestFilters[0]}
+	_, err := planFilterChanges(editTestFilters, edited)
+	if err == nil || err.Error() != "filter #2: duplicate id id1" {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+}
+
+func TestApplyFilterChanges(t *testing.T) {
+	updated := gmail.Filter{
+		ID:       "id1",
+		Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+		Action:   gmail.FilterAction{AddLabel: "Bar"},
+	}
+	created := gmail.Filter{
+		Criteria: gmail.FilterCriteria{Subject: gmail.Terms{"baz"}},
+		Action:   gmail.FilterAction{Archive: true},
+	}
+	changes := []filterChange{
+		{old: &editTestFilters[0], new: &updated},
+		{old: &editTestFilters[1]},
+		{new: &created},
+	}
+	st := state.New()
+	st.Filters = []state.Entry{
+		{Filter: editTestFilters[0], Source: "filters.yml"},
+		{Filter: editTestFilters[1], Source: "filters.yml"},
+	}
+
+	w := &fakeFilterWriter{}
+	if err := applyFilterChanges(context.Background(), w, changes, st); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"id1", "id2"}; !reflect.DeepEqual(w.deleted, want) {
+		t.Errorf("unexpected deleted filters: %v != %v", w.deleted, want)
+		return
+	}
+	// the updated filter is still managed, and the created one is not
+	entry := state.Entry{Filter: updated, Source: "filters.yml"}
+	entry.ID = "new1"
+	if want := []state.Entry{entry}; !reflect.DeepEqual(st.Filters, want) {
+		t.Errorf("unexpected state:\n  got:  %+v\n  want: %+v", st.Filters, want)
+		return
+	}
+}
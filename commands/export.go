@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/nasa9084/gmac/encoder"
+	"github.com/nasa9084/gmac/gmail"
+)
+
+var exportCommand *flags.Command
+
+func init() {
+	exportCommand = must(parser.AddCommand("export", "Export filters into other format", "Export filters in resource file into other format without Gmail access", &ExportCommand{}))
+}
+
+type ExportCommand struct {
+	Target string `short:"f" long:"filename" required:"yes"`
+	To     string `long:"to" choice:"gmail-xml" choice:"sieve" required:"yes" description:"format to be exported. gmail-xml is mailFilters.xml which can be imported from Gmail settings, and sieve is Sieve script (RFC 5228)"`
+}
+
+// exportFormats maps export formats to output formats of FilterEncoder.
+var exportFormats = map[string]string{
+	"gmail-xml": "xml",
+	"sieve":     "sieve",
+}
+
+func (cmd *ExportCommand) Execute([]string) error {
+	format, ok := exportFormats[cmd.To]
+	if !ok {
+		return fmt.Errorf("unsupported format: %s", cmd.To)
+	}
+
+	res, err := readResource(cmd.Target)
+	if err != nil {
+		return err
+	}
+
+	switch res.Kind {
+	case gmail.ResourceTypeFilter:
+		file, err := res.filterResource()
+		if err != nil {
+			return err
+		}
+		enc, err := encoder.NewFilterEncoder(os.Stdout, format)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(file.Filters)
+	}
+
+	return fmt.Errorf("unknown resource kind: %s", res.Kind)
+}
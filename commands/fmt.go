@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/goccy/go-yaml"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/log"
+)
+
+var fmtCommand *flags.Command
+
+func init() {
+	fmtCommand = must(parser.AddCommand("fmt", "Format a resource file", "Format a resource file into canonical form", &FmtCommand{}))
+}
+
+type FmtCommand struct {
+	Target    string `short:"f" long:"filename" required:"true" description:"resource file to be formatted"`
+	Write     bool   `short:"w" long:"write" description:"write result to the resource file instead of stdout"`
+	Check     bool   `long:"check" description:"do not print the result, but exit with non-zero status if the resource file is not formatted"`
+	KeepOrder bool   `long:"keep-order" description:"keep the order of filters instead of sorting them"`
+	Merge     bool   `long:"merge" description:"merge filters which have the same criteria and compatible actions into one filter"`
+}
+
+func (cmd *FmtCommand) Execute([]string) error {
+	res, err := readResource(cmd.Target)
+	if err != nil {
+		return err
+	}
+	if res.Kind != gmail.ResourceTypeFilter {
+		return fmt.Errorf("unsupported resource kind: %s", res.Kind)
+	}
+	file, err := res.filterResource()
+	if err != nil {
+		return err
+	}
+	filters := file.Filters
+	b, err := formatFilters(res.source, filters, file.Tests, cmd.Merge, !cmd.KeepOrder)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case cmd.Check:
+		if !bytes.Equal(b, res.source) {
+			return fmt.Errorf("%s is not formatted", cmd.Target)
+		}
+		return nil
+	case cmd.Write && cmd.Target != "-":
+		if bytes.Equal(b, res.source) {
+			return nil
+		}
+		return ioutil.WriteFile(cmd.Target, b, 0644)
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
+
+// formatFilters formats Filter resource into canonical form.
+// The comments in the source are kept.
+func formatFilters(source []byte, filters []gmail.Filter, tests []gmail.FilterTest, merge, sortFilters bool) ([]byte, error) {
+	comments, err := extractComments(source)
+	if err != nil {
+		return nil, err
+	}
+
+	// order maps the index of each filter in the source to the index
+	// in the result, so that the comments follow the filters.
+	order := make([]int, len(filters))
+	for i := range order {
+		order[i] = i
+	}
+
+	result := make([]gmail.Filter, len(filters))
+	copy(result, filters)
+	if merge {
+		result = gmail.MergeFilters(result)
+		log.Vprintf("%d filters are merged", len(filters)-len(result))
+		for i, f := range filters {
+			key := f.Criteria.Normalize().String()
+			for j, merged := range result {
+				if merged.Criteria.Normalize().String() == key && merged.Action.Includes(f.Action) {
+					order[i] = j
+					break
+				}
+			}
+		}
+	}
+	for i := range result {
+		result[i].Action = result[i].Action.Normalize()
+	}
+	if sortFilters {
+		indices := make([]int, len(result))
+		for i := range indices {
+			indices[i] = i
+		}
+		sort.SliceStable(indices, func(i, j int) bool {
+			return lessFilter(result[indices[i]], result[indices[j]])
+		})
+		sorted := make([]gmail.Filter, len(result))
+		position := make([]int, len(result))
+		for to, from := range indices {
+			sorted[to] = result[from]
+			position[from] = to
+		}
+		for i := range order {
+			order[i] = position[order[i]]
+		}
+		result = sorted
+	}
+
+	for i, c := range comments {
+		if len(c.path) >= 2 && c.path[0] == "filters" {
+			if index, ok := c.path[1].(int); ok && index < len(order) {
+				path := append([]interface{}{"filters", order[index]}, c.path[2:]...)
+				comments[i].path = path
+			}
+		}
+	}
+
+	b, err := yaml.Marshal(filterResourceFile{
+		Kind:    gmail.ResourceTypeFilter,
+		Filters: result,
+		Tests:   tests,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return insertComments(b, comments)
+}
+
+// lessFilter reports whether filter a should be placed before b.
+// Filters are sorted by the label, then the criteria and the action.
+func lessFilter(a, b gmail.Filter) bool {
+	if a.Action.AddLabel != b.Action.AddLabel {
+		return a.Action.AddLabel < b.Action.AddLabel
+	}
+	ac, bc := a.Criteria.Normalize().String(), b.Criteria.Normalize().String()
+	if ac != bc {
+		return ac < bc
+	}
+	return a.Action.String() < b.Action.String()
+}
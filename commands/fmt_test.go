@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+func TestFormatFilters(t *testing.T) {
+	const source = `# my filters
+kind: Filter
+filters:
+  # CI notifications
+  - criteria:
+      from: ci@example.com # the CI server
+    action:
+      add_label: CI
+  - action:
+      category: new
+    criteria:
+      from: news@example.com
+  - criteria: {query: "from:CI@example.com"}
+    action:
+      archive: true
+`
+	tests := []struct {
+		label       string
+		merge       bool
+		sortFilters bool
+		want        string
+	}{
+		{
+			label: "keep order",
+			want: `# my filters
+kind: Filter
+filters:
+# CI notifications
+- criteria:
+    from: ci@example.com # the CI server
+  action:
+    add_label: CI
+- criteria:
+    from: news@example.com
+  action:
+    category: updates
+- criteria:
+    query: from:CI@example.com
+  action:
+    archive: true
+`,
+		},
+		{
+			label:       "sort",
+			sortFilters: true,
+			want: `# my filters
+kind: Filter
+filters:
+- criteria:
+    query: from:CI@example.com
+  action:
+    archive: true
+- criteria:
+    from: news@example.com
+  action:
+    category: updates
+# CI notifications
+- criteria:
+    from: ci@example.com # the CI server
+  action:
+    add_label: CI
+`,
+		},
+		{
+			label:       "merge",
+			merge:       true,
+			sortFilters: true,
+			want: `# my filters
+kind: Filter
+filters:
+- criteria:
+    from: news@example.com
+  action:
+    category: updates
+# CI notifications
+- criteria:
+    from: ci@example.com # the CI server
+  action:
+    archive: true
+    add_label: CI
+`,
+		},
+	}
+	for i, tt := range tests {
+		var file filterResourceFile
+		if err := yaml.Unmarshal([]byte(source), &file); err != nil {
+			t.Fatal(err)
+		}
+		got, err := formatFilters([]byte(source), file.Filters, nil, tt.merge, tt.sortFilters)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %+v", strconv.Itoa(i)+"."+tt.label, err)
+			return
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s:\n%s\n!=\n%s", strconv.Itoa(i)+"."+tt.label, got, tt.want)
+			return
+		}
+	}
+}
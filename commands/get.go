@@ -2,12 +2,17 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/jessevdk/go-flags"
 
 	"github.com/nasa9084/gmac/encoder"
 	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/query"
+	"github.com/nasa9084/gmac/state"
 )
 
 var (
@@ -25,35 +30,211 @@ type GetCommand struct {
 }
 
 type GetFilterCommand struct {
+	Raw bool `long:"raw" description:"show filters as they are got from Gmail API, without translation"`
+
+	Label        string   `long:"label" description:"show only filters adding the label or its sublabels"`
+	FromContains string   `long:"from-contains" description:"show only filters whose from criteria contains the text (case-insensitive)"`
+	Actions      []string `long:"action" choice:"archive" choice:"mark_as_read" choice:"star" choice:"add_label" choice:"forward_to" choice:"delete" choice:"never_mark_as_spam" choice:"important" choice:"category" description:"show only filters having the action. can be specified multiple times"`
+	Forwarding   bool     `long:"forwarding" description:"show only filters forwarding messages"`
+	QueryMatches string   `long:"query-matches" description:"show only filters whose search query contains the text (case-insensitive)"`
+	Selector     string   `short:"l" long:"selector" description:"show only filters matching the selector, e.g. action.add_label=Foo"`
+	SortBy       string   `long:"sort-by" choice:"criteria" choice:"label" choice:"action" description:"sort filters by the key"`
+	Limit        int      `long:"limit" description:"show only the first given number of filters"`
+	Managed      bool     `long:"managed" description:"show only filters managed by gmac"`
+	Unmanaged    bool     `long:"unmanaged" description:"show only filters not managed by gmac, e.g. created in Gmail UI"`
+	State        string   `long:"state" description:"state recording the filters managed by gmac: path to the state file, file://<path>, dir://<directory>[?name=<name>] or URL of custom backend (default: ~/.gmac/state.yml)"`
+
+	MaxWidth   int  `long:"max-width" description:"maximum width of the table lines. defaults to the terminal width if stdout is a terminal"`
+	NoTruncate bool `long:"no-truncate" description:"do not truncate the criteria and action in the table"`
 }
 
 func (cmd *GetFilterCommand) Execute([]string) error {
+	if cmd.Managed && cmd.Unmanaged {
+		return errors.New("--managed and --unmanaged cannot be used together")
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	oauthConfig, err := getOAuthConfig(cmd.CredentialsFilePath())
+	backend, err := openStateBackend(cmd.State)
+	if err != nil {
+		return err
+	}
+	st, err := backend.Load(ctx)
 	if err != nil {
 		return err
 	}
 
-	token, err := getToken(cmd.RefreshToken())
+	enc, err := cmd.encoder(os.Stdout, st)
 	if err != nil {
 		return err
 	}
 
-	c, err := gmail.New(ctx, oauthConfig, token)
+	c, err := newGmailClient(ctx, cmd.CredentialsFilePath(), cmd.RefreshToken())
 	if err != nil {
 		return err
 	}
+
 	filters, err := c.ListFilters(ctx)
+	if err != nil {
+		return err
+	}
+	filters, err = cmd.filterFilters(filters, st)
+	if err != nil {
+		return err
+	}
 
-	if err := encoder.NewFilterEncoder(os.Stdout, cmd.OutputFormat()).Encode(filters); err != nil {
+	if err := enc.Encode(filters); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// encoder returns FilterEncoder of the output format. The table is
+// fitted to the terminal if the output is a terminal, and shows whether
+// each filter is managed by gmac.
+func (cmd *GetFilterCommand) encoder(f *os.File, st *state.State) (encoder.FilterEncoder, error) {
+	format := cmd.OutputFormat()
+	if cmd.Raw {
+		return encoder.NewRawFilterEncoder(f, format)
+	}
+	switch format {
+	case "", "table", "wide":
+	default:
+		return encoder.NewFilterEncoder(f, format)
+	}
+	opts := encoder.TableOptions{
+		Wide:       format == "wide",
+		MaxWidth:   cmd.MaxWidth,
+		NoTruncate: cmd.NoTruncate,
+		IsManaged:  st.IsManaged,
+	}
+	if opts.MaxWidth == 0 && !opts.Wide {
+		if width, ok := terminalWidth(f); ok {
+			opts.MaxWidth = width
+		}
+	}
+	return encoder.NewTableFilterEncoder(f, opts), nil
+}
+
+// filterFilters returns the filters selected by the options, sorted and
+// limited as specified. st is the state to select managed or unmanaged
+// filters.
+func (cmd *GetFilterCommand) filterFilters(filters []gmail.Filter, st *state.State) ([]gmail.Filter, error) {
+	var sel selector
+	if cmd.Selector != "" {
+		var err error
+		sel, err = parseSelector(cmd.Selector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var selected []gmail.Filter
+	for _, f := range filters {
+		if !cmd.matches(f) {
+			continue
+		}
+		if (cmd.Managed || cmd.Unmanaged) && st.IsManaged(f.ID) != cmd.Managed {
+			continue
+		}
+		if sel != nil {
+			ok, err := sel.matches(f)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		selected = append(selected, f)
+	}
+
+	var key func(gmail.Filter) string
+	switch cmd.SortBy {
+	case "criteria":
+		key = func(f gmail.Filter) string { return f.Criteria.String() }
+	case "label":
+		key = func(f gmail.Filter) string { return f.Action.AddLabel }
+	case "action":
+		key = func(f gmail.Filter) string { return f.Action.String() }
+	}
+	if key != nil {
+		sort.SliceStable(selected, func(i, j int) bool { return key(selected[i]) < key(selected[j]) })
+	}
+
+	if cmd.Limit > 0 && len(selected) > cmd.Limit {
+		selected = selected[:cmd.Limit]
+	}
+	return selected, nil
+}
+
+// matches reports whether the filter satisfies all the conditions given
+// by the options.
+func (cmd *GetFilterCommand) matches(f gmail.Filter) bool {
+	if cmd.Label != "" && f.Action.AddLabel != cmd.Label && !strings.HasPrefix(f.Action.AddLabel, cmd.Label+"/") {
+		return false
+	}
+	if cmd.FromContains != "" && !fromContains(f.Criteria, cmd.FromContains) {
+		return false
+	}
+	for _, action := range cmd.Actions {
+		if !hasAction(f.Action, action) {
+			return false
+		}
+	}
+	if cmd.Forwarding && f.Action.ForwardTo == "" {
+		return false
+	}
+	if cmd.QueryMatches != "" && !strings.Contains(strings.ToLower(f.Criteria.String()), strings.ToLower(cmd.QueryMatches)) {
+		return false
+	}
+	return true
+}
+
+// fromContains reports whether any from: of the criteria, including
+// from: in the search query, contains the text case-insensitively.
+func fromContains(criteria gmail.FilterCriteria, text string) bool {
+	text = strings.ToLower(text)
+	found := false
+	query.Inspect(criteria.Node(), func(n query.Node) bool {
+		if field, ok := n.(query.Field); ok && strings.EqualFold(field.Name, "from") {
+			if strings.Contains(strings.ToLower(field.Value.String()), text) {
+				found = true
+			}
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// hasAction reports whether the action of given name in the resource
+// file is set.
+func hasAction(action gmail.FilterAction, name string) bool {
+	switch name {
+	case "archive":
+		return action.Archive
+	case "mark_as_read":
+		return action.MarkAsRead
+	case "star":
+		return action.Star
+	case "add_label":
+		return action.AddLabel != ""
+	case "forward_to":
+		return action.ForwardTo != ""
+	case "delete":
+		return action.Delete
+	case "never_mark_as_spam":
+		return action.NeverMarkAsSpam
+	case "important":
+		return action.Important != ""
+	case "category":
+		return action.Category != ""
+	}
+	return false
+}
+
 func (*GetFilterCommand) CredentialsFilePath() string {
 	val := getFilterCommand.FindOptionByLongName("credentials-file").Value()
 	if val == nil {
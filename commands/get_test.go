@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/state"
+)
+
+func TestGetFilterCommandFilterFilters(t *testing.T) {
+	filters := []gmail.Filter{
+		{
+			ID:       "id1",
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"ci@example.com"}},
+			Action:   gmail.FilterAction{AddLabel: "Work/CI/Nightly", Archive: true},
+		},
+		{
+			ID:       "id2",
+			Criteria: gmail.FilterCriteria{Query: "from:Alice@Example.com has:attachment"},
+			Action:   gmail.FilterAction{AddLabel: "Personal", ForwardTo: "bob@example.com"},
+		},
+		{
+			ID:       "id3",
+			Criteria: gmail.FilterCriteria{Subject: gmail.Terms{"build failed"}},
+			Action:   gmail.FilterAction{AddLabel: "Work/CI", Star: true},
+		},
+		{
+			ID:       "id4",
+			Criteria: gmail.FilterCriteria{To: gmail.Terms{"ci@example.com"}},
+			Action:   gmail.FilterAction{AddLabel: "Work/CIA", Archive: true},
+		},
+	}
+	st := state.New()
+	st.Filters = []state.Entry{
+		{Filter: filters[0]},
+		{Filter: filters[2]},
+	}
+	tests := []struct {
+		label string
+		cmd   GetFilterCommand
+		want  []string
+	}{
+		{
+			label: "no options",
+			want:  []string{"id1", "id2", "id3", "id4"},
+		},
+		{
+			label: "label with sublabels",
+			cmd:   GetFilterCommand{Label: "Work/CI"},
+			want:  []string{"id1", "id3"},
+		},
+		{
+			label: "from contains in terms and query",
+			cmd:   GetFilterCommand{FromContains: "example.COM"},
+			want:  []string{"id1", "id2"},
+		},
+		{
+			label: "action",
+			cmd:   GetFilterCommand{Actions: []string{"archive"}},
+			want:  []string{"id1", "id4"},
+		},
+		{
+			label: "all actions",
+			cmd:   GetFilterCommand{Actions: []string{"add_label", "star"}},
+			want:  []string{"id3"},
+		},
+		{
+			label: "forwarding",
+			cmd:   GetFilterCommand{Forwarding: true},
+			want:  []string{"id2"},
+		},
+		{
+			label: "query matches",
+			cmd:   GetFilterCommand{QueryMatches: "BUILD"},
+			want:  []string{"id3"},
+		},
+		{
+			label: "selector",
+			cmd:   GetFilterCommand{Selector: "criteria.to=ci@example.com"},
+			want:  []string{"id4"},
+		},
+		{
+			label: "managed",
+			cmd:   GetFilterCommand{Managed: true},
+			want:  []string{"id1", "id3"},
+		},
+		{
+			label: "unmanaged",
+			cmd:   GetFilterCommand{Unmanaged: true, Actions: []string{"archive"}},
+			want:  []string{"id4"},
+		},
+		{
+			label: "sort by label",
+			cmd:   GetFilterCommand{SortBy: "label"},
+			want:  []string{"id2", "id3", "id1", "id4"},
+		},
+		{
+			label: "sort and limit",
+			cmd:   GetFilterCommand{Actions: []string{"archive"}, SortBy: "criteria", Limit: 1},
+			want:  []string{"id1"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			got, err := tt.cmd.filterFilters(filters, st)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			ids := []string{}
+			for _, f := range got {
+				ids = append(ids, f.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("%v != %v", ids, tt.want)
+				return
+			}
+		})
+	}
+}
@@ -0,0 +1,415 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/nasa9084/gmac/encoder"
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/log"
+	"github.com/nasa9084/gmac/sieve"
+	"github.com/nasa9084/gmac/state"
+)
+
+var importCommand *flags.Command
+
+func init() {
+	importCommand = must(parser.AddCommand("import", "Import filters from other format", "Import filters from other format, then print them as Filter resource, or merge filters in Gmail into resource files", &ImportCommand{}))
+}
+
+type ImportCommand struct {
+	From   string `long:"from" choice:"gmail-xml" choice:"sieve" description:"format of the file to be imported. gmail-xml is mailFilters.xml exported from Gmail settings, and sieve is Sieve script (RFC 5228)"`
+	Merge  bool   `long:"merge" description:"merge the filters in Gmail which are not in the resource files into them"`
+	Target string `short:"f" long:"filename" description:"resource file, or directory of resource files, to be merged into with --merge"`
+	Into   string `long:"into" description:"resource file which new filters are appended to with --merge (default: the file given by -f)"`
+	State  string `long:"state" description:"state recording the filters managed by gmac, which are not merged: path to the state file, file://<path>, dir://<directory>[?name=<name>] or URL of custom backend (default: ~/.gmac/state.yml)"`
+}
+
+func (cmd *ImportCommand) Execute(args []string) error {
+	if cmd.Merge {
+		return cmd.merge(args)
+	}
+	if cmd.From == "" {
+		return errors.New("--from must be specified")
+	}
+	if len(args) != 1 {
+		return errors.New("a file to be imported must be specified")
+	}
+
+	var read func(io.Reader) ([]gmail.Filter, []string, error)
+	switch cmd.From {
+	case "gmail-xml":
+		read = gmail.ReadFiltersXML
+	case "sieve":
+		read = sieve.Parse
+	default:
+		return fmt.Errorf("unsupported format: %s", cmd.From)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	filters, warnings, err := read(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	for _, w := range warnings {
+		log.Printf("WARN: %s", w)
+	}
+
+	enc, err := encoder.NewFilterEncoder(os.Stdout, "yaml")
+	if err != nil {
+		return err
+	}
+	return enc.Encode(filters)
+}
+
+func (cmd *ImportCommand) merge(args []string) error {
+	if len(args) != 0 || cmd.From != "" {
+		return errors.New("--merge imports filters from Gmail, no file to be imported can be specified")
+	}
+	if cmd.Target == "" {
+		return errors.New("-f must be specified with --merge")
+	}
+	files, into, err := readMergeFiles(cmd.Target, cmd.Into)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	c, err := newGmailClient(ctx, cmd.CredentialsFilePath(), cmd.RefreshToken())
+	if err != nil {
+		return err
+	}
+	backend, err := openStateBackend(cmd.State)
+	if err != nil {
+		return err
+	}
+	st, err := backend.Load(ctx)
+	if err != nil {
+		return err
+	}
+	live, err := c.ListFilters(ctx)
+	if err != nil {
+		return err
+	}
+
+	added, conflicts := planMerge(live, files, st)
+	resolved, err := resolveMergeConflicts(os.Stdout, conflicts)
+	if err != nil {
+		return err
+	}
+	added = append(added, resolved...)
+	if len(added) > 0 {
+		into.filters = append(into.filters, added...)
+		into.changed = true
+		log.Printf("%d filters are added to %s", len(added), into.path)
+	}
+
+	for _, file := range files {
+		if !file.changed {
+			continue
+		}
+		b, err := formatFilters(file.source, file.filters, file.tests, false, false)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(file.path, b, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeFile is a resource file which filters are merged into.
+type mergeFile struct {
+	path    string
+	source  []byte
+	filters []gmail.Filter
+	tests   []gmail.FilterTest
+	// changed is true if the filters are changed and the file needs to
+	// be written.
+	changed bool
+}
+
+// readMergeFiles reads the resource file of given path, or the resource
+// files in the directory of given path. The file which new filters are
+// appended to is also returned, which is created if it does not exist.
+func readMergeFiles(target, into string) ([]*mergeFile, *mergeFile, error) {
+	fi, err := os.Stat(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	var paths []string
+	if fi.IsDir() {
+		if into == "" {
+			return nil, nil, errors.New("--into must be specified if -f is a directory")
+		}
+		err := filepath.Walk(target, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if ext := filepath.Ext(path); !info.IsDir() && (ext == ".yml" || ext == ".yaml") {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		paths = append(paths, target)
+		if into == "" {
+			into = target
+		}
+	}
+
+	var files []*mergeFile
+	var intoFile *mergeFile
+	for _, path := range paths {
+		res, err := readResource(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if res.Kind != gmail.ResourceTypeFilter {
+			// other kinds of resources may be placed in the directory
+			log.Vprintf("skip %s: kind is %s", path, res.Kind)
+			continue
+		}
+		file, err := res.filterResource()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		files = append(files, &mergeFile{
+			path:    path,
+			source:  res.source,
+			filters: file.Filters,
+			tests:   file.Tests,
+		})
+		if sameFile(path, into) {
+			intoFile = files[len(files)-1]
+		}
+	}
+	if intoFile == nil {
+		if _, err := os.Stat(into); err == nil {
+			return nil, nil, fmt.Errorf("%s is not a Filter resource file in %s", into, target)
+		}
+		intoFile = &mergeFile{path: into}
+		files = append(files, intoFile)
+	}
+	return files, intoFile, nil
+}
+
+func sameFile(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fa, fb)
+}
+
+// mergeLocation is the position of a filter in the resource files.
+type mergeLocation struct {
+	file  *mergeFile
+	index int
+}
+
+// mergeConflict is a filter in Gmail which has the same criteria as
+// filters in the resource files, but has a different action, e.g. a
+// filter modified via Gmail UI.
+type mergeConflict struct {
+	live gmail.Filter
+	// locations are the filters in the resource files with the same
+	// criteria, which are not the same as any filter in Gmail.
+	locations []mergeLocation
+}
+
+// planMerge returns the filters in Gmail which are not in the resource
+// files, and the conflicts with the filters in the resource files. The
+// filters are compared by their normalized criteria and actions, so the
+// filters written in different ways are regarded as the same. The
+// filters managed by gmac are skipped, as they are created from the
+// resource files, or removed from them to be deleted on next apply.
+// A filter in Gmail conflicts only with the filters in the resource
+// files which are not the same as any filter in Gmail, so that one of
+// the filters in Gmail with the same criteria does not replace another.
+func planMerge(live []gmail.Filter, files []*mergeFile, st *state.State) (added []gmail.Filter, conflicts []mergeConflict) {
+	criteria := map[string][]mergeLocation{}
+	for _, file := range files {
+		for i, f := range file.filters {
+			key := f.Criteria.Normalize().String()
+			criteria[key] = append(criteria[key], mergeLocation{file, i})
+		}
+	}
+	matched := map[mergeLocation]bool{}
+	for _, f := range live {
+		for _, l := range criteria[f.Criteria.Normalize().String()] {
+			if l.file.filters[l.index].Action.Normalize() == f.Action.Normalize() {
+				matched[l] = true
+			}
+		}
+	}
+
+	for _, f := range live {
+		if st.IsManaged(f.ID) {
+			continue
+		}
+		f.ID = ""
+		key := f.Criteria.Normalize().String()
+		found := false
+		var locations []mergeLocation
+		for _, l := range criteria[key] {
+			if l.file.filters[l.index].Action.Normalize() == f.Action.Normalize() {
+				found = true
+				break
+			}
+			if !matched[l] {
+				locations = append(locations, l)
+			}
+		}
+		for _, a := range added {
+			if a.Criteria.Normalize().String() == key && a.Action.Normalize() == f.Action.Normalize() {
+				found = true
+				break
+			}
+		}
+		for _, c := range conflicts {
+			if c.live.Criteria.Normalize().String() == key && c.live.Action.Normalize() == f.Action.Normalize() {
+				found = true
+				break
+			}
+		}
+		switch {
+		case found:
+			log.Vprintf("%s is already in the resource files", f.String())
+		case len(locations) > 0:
+			conflicts = append(conflicts, mergeConflict{live: f, locations: locations})
+		default:
+			added = append(added, f)
+		}
+	}
+	return added, conflicts
+}
+
+// mergeChoice is the choice of the user for a merge conflict.
+type mergeChoice int
+
+const (
+	// mergeReject keeps the filter in the resource file, and does not
+	// merge the filter in Gmail.
+	mergeReject mergeChoice = iota
+	// mergeAccept replaces the filter in the resource file with the
+	// filter in Gmail.
+	mergeAccept
+	// mergeAppend keeps the filter in the resource file, and appends
+	// the filter in Gmail as a new filter.
+	mergeAppend
+)
+
+// resolveMergeConflicts asks the user how to resolve each conflict, and
+// replaces the filters in the resource files with the accepted ones. The
+// filters to be appended as new filters are returned. A filter in the
+// resource files replaced by an accepted conflict is not offered for the
+// following conflicts, which are compared with the next filter with the
+// same criteria, or appended if there is no such filter.
+func resolveMergeConflicts(w io.Writer, conflicts []mergeConflict) ([]gmail.Filter, error) {
+	var added []gmail.Filter
+	replaced := map[mergeLocation]bool{}
+	for _, c := range conflicts {
+		var local *mergeLocation
+		for i, l := range c.locations {
+			if !replaced[l] {
+				local = &c.locations[i]
+				break
+			}
+		}
+		if local == nil {
+			added = append(added, c.live)
+			continue
+		}
+		choice, err := c.ask(w, *local)
+		if err != nil {
+			return nil, err
+		}
+		switch choice {
+		case mergeAccept:
+			local.file.filters[local.index] = c.live
+			local.file.changed = true
+			replaced[*local] = true
+		case mergeAppend:
+			added = append(added, c.live)
+		}
+	}
+	return added, nil
+}
+
+// ask shows the filter in the resource file and the filter in Gmail,
+// then asks the user whether to replace the former with the latter,
+// keep the former, or append the latter as a new filter.
+func (c mergeConflict) ask(w io.Writer, l mergeLocation) (mergeChoice, error) {
+	local, err := yaml.Marshal(l.file.filters[l.index])
+	if err != nil {
+		return mergeReject, err
+	}
+	live, err := yaml.Marshal(c.live)
+	if err != nil {
+		return mergeReject, err
+	}
+	fmt.Fprintf(w, "Filter #%d in %s has the same criteria as a filter in Gmail:\n", l.index+1, l.file.path)
+	for _, line := range strings.Split(strings.TrimSuffix(string(local), "\n"), "\n") {
+		fmt.Fprintf(w, "- %s\n", line)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(live), "\n"), "\n") {
+		fmt.Fprintf(w, "+ %s\n", line)
+	}
+	fmt.Fprint(w, "Accept the filter in Gmail (a), reject it (r), or append it as a new filter (n)? [a/R/n]: ")
+	answer, err := readLine(stdin)
+	if err != nil {
+		return mergeReject, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "a", "accept":
+		return mergeAccept, nil
+	case "n", "new":
+		return mergeAppend, nil
+	}
+	return mergeReject, nil
+}
+
+func (*ImportCommand) CredentialsFilePath() string {
+	val := importCommand.FindOptionByLongName("credentials-file").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
+
+func (*ImportCommand) RefreshToken() string {
+	val := importCommand.FindOptionByLongName("refresh-token").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
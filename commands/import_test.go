@@ -0,0 +1,360 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/state"
+)
+
+func TestPlanMerge(t *testing.T) {
+	fooFilter := gmail.Filter{
+		Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+		Action:   gmail.FilterAction{AddLabel: "Foo"},
+	}
+	barFilter := gmail.Filter{
+		Criteria: gmail.FilterCriteria{From: gmail.Terms{"bar@example.com"}},
+		Action:   gmail.FilterAction{Archive: true},
+	}
+	withID := func(f gmail.Filter, id string) gmail.Filter {
+		f.ID = id
+		return f
+	}
+
+	tests := []struct {
+		label         string
+		live          []gmail.Filter
+		local         []gmail.Filter
+		state         []state.Entry
+		want          []gmail.Filter
+		wantConflicts []mergeConflict
+	}{
+		{
+			label: "filters not in resource files are added",
+			live:  []gmail.Filter{withID(fooFilter, "id1"), withID(barFilter, "id2")},
+			local: []gmail.Filter{fooFilter},
+			want:  []gmail.Filter{barFilter},
+		},
+		{
+			label: "filters are compared by normalized criteria and action",
+			live:  []gmail.Filter{withID(fooFilter, "id1")},
+			local: []gmail.Filter{{
+				Criteria: gmail.FilterCriteria{Query: "from:(foo@example.com)"},
+				Action:   gmail.FilterAction{AddLabel: "Foo"},
+			}},
+		},
+		{
+			label: "duplicated filters in Gmail are added once",
+			live:  []gmail.Filter{withID(barFilter, "id1"), withID(barFilter, "id2")},
+			want:  []gmail.Filter{barFilter},
+		},
+		{
+			label: "managed filters are skipped",
+			live:  []gmail.Filter{withID(fooFilter, "id1")},
+			state: []state.Entry{{Filter: withID(fooFilter, "id1")}},
+		},
+		{
+			label: "filters with the same criteria conflict",
+			live: []gmail.Filter{withID(gmail.Filter{
+				Criteria: fooFilter.Criteria,
+				Action:   gmail.FilterAction{AddLabel: "Bar"},
+			}, "id1")},
+			local: []gmail.Filter{barFilter, fooFilter},
+			wantConflicts: []mergeConflict{{
+				live: gmail.Filter{
+					Criteria: fooFilter.Criteria,
+					Action:   gmail.FilterAction{AddLabel: "Bar"},
+				},
+				locations: []mergeLocation{{index: 1}},
+			}},
+		},
+		{
+			label: "filters in resource files which are in Gmail do not conflict",
+			live: []gmail.Filter{withID(fooFilter, "id1"), withID(gmail.Filter{
+				Criteria: fooFilter.Criteria,
+				Action:   gmail.FilterAction{AddLabel: "Bar"},
+			}, "id2")},
+			local: []gmail.Filter{fooFilter},
+			want: []gmail.Filter{{
+				Criteria: fooFilter.Criteria,
+				Action:   gmail.FilterAction{AddLabel: "Bar"},
+			}},
+		},
+		{
+			label: "filters conflicting with the same filters",
+			live: []gmail.Filter{
+				withID(gmail.Filter{Criteria: fooFilter.Criteria, Action: gmail.FilterAction{AddLabel: "Bar"}}, "id1"),
+				withID(gmail.Filter{Criteria: fooFilter.Criteria, Action: gmail.FilterAction{Star: true}}, "id2"),
+				withID(gmail.Filter{Criteria: fooFilter.Criteria, Action: gmail.FilterAction{Star: true}}, "id3"),
+			},
+			local: []gmail.Filter{fooFilter},
+			wantConflicts: []mergeConflict{
+				{
+					live:      gmail.Filter{Criteria: fooFilter.Criteria, Action: gmail.FilterAction{AddLabel: "Bar"}},
+					locations: []mergeLocation{{index: 0}},
+				},
+				{
+					live:      gmail.Filter{Criteria: fooFilter.Criteria, Action: gmail.FilterAction{Star: true}},
+					locations: []mergeLocation{{index: 0}},
+				},
+			},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			file := &mergeFile{path: "filters.yml", filters: tt.local}
+			for _, c := range tt.wantConflicts {
+				for i := range c.locations {
+					c.locations[i].file = file
+				}
+			}
+			got, conflicts := planMerge(tt.live, []*mergeFile{file}, &state.State{Version: state.Version, Filters: tt.state})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected added filters:\n  got:  %+v\n  want: %+v", got, tt.want)
+				return
+			}
+			if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+				t.Errorf("unexpected conflicts:\n  got:  %+v\n  want: %+v", conflicts, tt.wantConflicts)
+				return
+			}
+		})
+	}
+}
+
+func TestReadMergeFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gmac-import-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	work := write("work.yml", "kind: Filter\nfilters:\n  - criteria:\n      from: boss@example.com\n    action:\n      star: true\n")
+	write("private/family.yaml", "kind: Filter\nfilters:\n  - criteria:\n      from: mom@example.com\n    action:\n      add_label: Family\n")
+	write("README.md", "not a resource file\n")
+
+	t.Run("into existing file", func(t *testing.T) {
+		files, into, err := readMergeFiles(dir, work)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 2 {
+			t.Errorf("unexpected number of files: %d", len(files))
+			return
+		}
+		if into != files[1] || len(into.filters) != 1 {
+			t.Errorf("unexpected file to be appended: %+v", into)
+			return
+		}
+	})
+	t.Run("into new file", func(t *testing.T) {
+		path := filepath.Join(dir, "imported.yml")
+		files, into, err := readMergeFiles(dir, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 3 || into != files[2] || into.path != path || into.filters != nil {
+			t.Errorf("unexpected file to be appended: %+v", into)
+			return
+		}
+	})
+	t.Run("into is required for directory", func(t *testing.T) {
+		if _, _, err := readMergeFiles(dir, ""); err == nil {
+			t.Error("error should be returned")
+			return
+		}
+	})
+	t.Run("single file", func(t *testing.T) {
+		files, into, err := readMergeFiles(work, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 || into != files[0] {
+			t.Errorf("unexpected file to be appended: %+v", into)
+			return
+		}
+	})
+}
+
+func TestResolveMergeConflicts(t *testing.T) {
+	boss := gmail.Filter{
+		Criteria: gmail.FilterCriteria{From: gmail.Terms{"boss@example.com"}},
+		Action:   gmail.FilterAction{Star: true},
+	}
+	important := gmail.Filter{
+		Criteria: boss.Criteria,
+		Action:   gmail.FilterAction{Star: true, Important: "always"},
+	}
+	labeled := gmail.Filter{
+		Criteria: boss.Criteria,
+		Action:   gmail.FilterAction{AddLabel: "Boss"},
+	}
+
+	tests := []struct {
+		label   string
+		answers string
+		// asked is the number of the questions
+		asked     int
+		wantLocal []gmail.Filter
+		wantAdded []gmail.Filter
+	}{
+		{
+			label:     "rejected",
+			answers:   "r\n\n",
+			asked:     2,
+			wantLocal: []gmail.Filter{boss},
+		},
+		{
+			label:     "accepted filter is not offered again",
+			answers:   "a\n",
+			asked:     1,
+			wantLocal: []gmail.Filter{important},
+			wantAdded: []gmail.Filter{labeled},
+		},
+		{
+			label:     "appended",
+			answers:   "n\na\n",
+			asked:     2,
+			wantLocal: []gmail.Filter{labeled},
+			wantAdded: []gmail.Filter{important},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			defer func() { stdin = os.Stdin }()
+			stdin = strings.NewReader(tt.answers)
+
+			file := &mergeFile{path: "filters.yml", filters: []gmail.Filter{boss}}
+			conflicts := []mergeConflict{
+				{live: important, locations: []mergeLocation{{file: file, index: 0}}},
+				{live: labeled, locations: []mergeLocation{{file: file, index: 0}}},
+			}
+			var buf bytes.Buffer
+			added, err := resolveMergeConflicts(&buf, conflicts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if asked := strings.Count(buf.String(), "[a/R/n]"); asked != tt.asked {
+				t.Errorf("%d questions are asked, but %d expected", asked, tt.asked)
+				return
+			}
+			if !reflect.DeepEqual(file.filters, tt.wantLocal) {
+				t.Errorf("unexpected filters in the file:\n  got:  %+v\n  want: %+v", file.filters, tt.wantLocal)
+				return
+			}
+			if !reflect.DeepEqual(added, tt.wantAdded) {
+				t.Errorf("unexpected added filters:\n  got:  %+v\n  want: %+v", added, tt.wantAdded)
+				return
+			}
+		})
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	defer func() { stdin = os.Stdin }()
+	stdin = strings.NewReader("a\n")
+
+	source := `kind: Filter
+filters:
+  # from my boss
+  - criteria:
+      from: boss@example.com
+    action:
+      star: true # do not miss
+  - criteria:
+      from: news@example.com
+    action:
+      archive: true
+`
+	file := &mergeFile{
+		path:   "filters.yml",
+		source: []byte(source),
+		filters: []gmail.Filter{
+			{
+				Criteria: gmail.FilterCriteria{From: gmail.Terms{"boss@example.com"}},
+				Action:   gmail.FilterAction{Star: true},
+			},
+			{
+				Criteria: gmail.FilterCriteria{From: gmail.Terms{"news@example.com"}},
+				Action:   gmail.FilterAction{Archive: true},
+			},
+		},
+	}
+	conflicts := []mergeConflict{{
+		live: gmail.Filter{
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"boss@example.com"}},
+			Action:   gmail.FilterAction{Star: true, Important: "always"},
+		},
+		locations: []mergeLocation{{file: file, index: 0}},
+	}}
+
+	var buf bytes.Buffer
+	if _, err := resolveMergeConflicts(&buf, conflicts); err != nil {
+		t.Fatal(err)
+	}
+	wantPrompt := `Filter #1 in filters.yml has the same criteria as a filter in Gmail:
+- criteria:
+-   from: boss@example.com
+- action:
+-   star: true
++ criteria:
++   from: boss@example.com
++ action:
++   star: true
++   important: always
+Accept the filter in Gmail (a), reject it (r), or append it as a new filter (n)? [a/R/n]: `
+	if buf.String() != wantPrompt {
+		t.Errorf("unexpected prompt:\n%s", buf.String())
+		return
+	}
+	file.filters = append(file.filters, gmail.Filter{
+		Criteria: gmail.FilterCriteria{Subject: gmail.Terms{"invoice"}},
+		Action:   gmail.FilterAction{AddLabel: "Invoice"},
+	})
+
+	// comments and order are kept
+	got, err := formatFilters(file.source, file.filters, file.tests, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `kind: Filter
+filters:
+# from my boss
+- criteria:
+    from: boss@example.com
+  action:
+    star: true # do not miss
+    important: always
+- criteria:
+    from: news@example.com
+  action:
+    archive: true
+- criteria:
+    subject: invoice
+  action:
+    add_label: Invoice
+`
+	if string(got) != want {
+		t.Errorf("unexpected result:\n%s", got)
+		return
+	}
+	if !file.changed {
+		t.Error("file should be changed")
+		return
+	}
+}
@@ -0,0 +1,235 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/lint"
+)
+
+var lintCommand *flags.Command
+
+func init() {
+	lintCommand = must(parser.AddCommand("lint", "Check filters for common mistakes", "Check filters for common mistakes", &LintCommand{}))
+}
+
+type LintCommand struct {
+	Target    string   `short:"f" long:"filename" description:"resource file to be checked"`
+	Enable    []string `short:"e" long:"enable" description:"name of the rule disabled by default to be enabled. can be specified multiple times"`
+	Disable   []string `short:"d" long:"disable" description:"name of the rule to be disabled. can be specified multiple times"`
+	Strict    bool     `long:"strict" description:"exit with non-zero status also on warnings"`
+	Format    string   `long:"format" choice:"text" choice:"json" choice:"sarif" default:"text" description:"output format"`
+	ListRules bool     `long:"list-rules" description:"show available rules"`
+}
+
+func (cmd *LintCommand) Execute([]string) error {
+	if cmd.ListRules {
+		return writeLintRules(os.Stdout)
+	}
+	if cmd.Target == "" {
+		return fmt.Errorf("the required flag `-f, --filename' was not specified")
+	}
+
+	res, err := readResource(cmd.Target)
+	if err != nil {
+		return err
+	}
+	if res.Kind != gmail.ResourceTypeFilter {
+		return fmt.Errorf("unsupported resource kind: %s", res.Kind)
+	}
+	file, err := res.filterResource()
+	if err != nil {
+		return err
+	}
+	filters := file.Filters
+
+	problems, err := lint.Lint(filters, cmd.Enable, cmd.Disable)
+	if err != nil {
+		return err
+	}
+
+	srcmap := newSourceMap(res.source)
+	located := make([]locatedProblem, 0, len(problems))
+	for _, p := range problems {
+		path := []interface{}{"filters", p.Filter}
+		for _, elem := range p.Path {
+			if i, err := strconv.Atoi(elem); err == nil {
+				path = append(path, i)
+			} else {
+				path = append(path, elem)
+			}
+		}
+		line, column := srcmap.position(path...)
+		located = append(located, locatedProblem{
+			Problem: p,
+			File:    cmd.Target,
+			Line:    line,
+			Column:  column,
+		})
+	}
+
+	switch cmd.Format {
+	case "json":
+		err = writeLintJSON(os.Stdout, located)
+	case "sarif":
+		err = writeLintSARIF(os.Stdout, located)
+	default:
+		err = writeLintText(os.Stdout, located)
+	}
+	if err != nil {
+		return err
+	}
+	if n := countFailures(problems, cmd.Strict); n > 0 {
+		if cmd.Strict {
+			return fmt.Errorf("%d problems found", n)
+		}
+		return fmt.Errorf("%d errors found", n)
+	}
+	return nil
+}
+
+// countFailures returns the number of problems which make lint fail,
+// i.e. errors, and also warnings if strict is true.
+func countFailures(problems []lint.Problem, strict bool) int {
+	n := 0
+	for _, p := range problems {
+		if strict || p.Severity == lint.SeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+// locatedProblem is a lint problem with its location in the resource file.
+type locatedProblem struct {
+	lint.Problem
+
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+func writeLintRules(w io.Writer) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 2, 1, ' ', 0)
+	fmt.Fprint(tw, "NAME\tSEVERITY\tDEFAULT\tDESCRIPTION\n")
+	for _, rule := range lint.Rules() {
+		enabled := "enabled"
+		if rule.DisabledByDefault {
+			enabled = "disabled"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", rule.Name, rule.Severity, enabled, rule.Description)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func writeLintText(w io.Writer, problems []locatedProblem) error {
+	var buf bytes.Buffer
+	for _, p := range problems {
+		fmt.Fprintf(&buf, "%s:%d:%d: %s: filter #%d: %s (%s)\n", p.File, p.Line, p.Column, p.Severity, p.Filter+1, p.Message, p.Rule)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func writeLintJSON(w io.Writer, problems []locatedProblem) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if problems == nil {
+		problems = []locatedProblem{}
+	}
+	return enc.Encode(problems)
+}
+
+// writeLintSARIF writes problems in SARIF v2.1.0 format, which is
+// supported by code review tools to show annotations.
+func writeLintSARIF(w io.Writer, problems []locatedProblem) error {
+	type message struct {
+		Text string `json:"text"`
+	}
+	type rule struct {
+		ID               string  `json:"id"`
+		ShortDescription message `json:"shortDescription"`
+	}
+	type region struct {
+		StartLine   int `json:"startLine,omitempty"`
+		StartColumn int `json:"startColumn,omitempty"`
+	}
+	type artifactLocation struct {
+		URI string `json:"uri"`
+	}
+	type physicalLocation struct {
+		ArtifactLocation artifactLocation `json:"artifactLocation"`
+		Region           region           `json:"region"`
+	}
+	type location struct {
+		PhysicalLocation physicalLocation `json:"physicalLocation"`
+	}
+	type result struct {
+		RuleID    string     `json:"ruleId"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations"`
+	}
+	type driver struct {
+		Name           string `json:"name"`
+		InformationURI string `json:"informationUri"`
+		Version        string `json:"version,omitempty"`
+		Rules          []rule `json:"rules"`
+	}
+	type tool struct {
+		Driver driver `json:"driver"`
+	}
+	type run struct {
+		Tool    tool     `json:"tool"`
+		Results []result `json:"results"`
+	}
+	type log struct {
+		Schema  string `json:"$schema"`
+		Version string `json:"version"`
+		Runs    []run  `json:"runs"`
+	}
+
+	d := driver{
+		Name:           "gmac",
+		InformationURI: "https://github.com/nasa9084/gmac",
+		Version:        Version,
+	}
+	for _, r := range lint.Rules() {
+		d.Rules = append(d.Rules, rule{ID: r.Name, ShortDescription: message{Text: r.Description}})
+	}
+	results := make([]result, 0, len(problems))
+	for _, p := range problems {
+		results = append(results, result{
+			RuleID:  p.Rule,
+			Level:   string(p.Severity),
+			Message: message{Text: fmt.Sprintf("filter #%d: %s", p.Filter+1, p.Message)},
+			Locations: []location{{
+				PhysicalLocation: physicalLocation{
+					ArtifactLocation: artifactLocation{URI: p.File},
+					Region:           region{StartLine: p.Line, StartColumn: p.Column},
+				},
+			}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []run{{Tool: tool{Driver: d}, Results: results}},
+	})
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/nasa9084/gmac/lint"
+)
+
+func TestCountFailures(t *testing.T) {
+	problems := []lint.Problem{
+		{Rule: "forward-to", Severity: lint.SeverityWarning},
+		{Rule: "empty-criteria", Severity: lint.SeverityError},
+		{Rule: "empty-action", Severity: lint.SeverityWarning},
+	}
+	tests := []struct {
+		label    string
+		problems []lint.Problem
+		strict   bool
+		want     int
+	}{
+		{
+			label:    "no problems",
+			problems: nil,
+			want:     0,
+		},
+		{
+			label:    "only warnings",
+			problems: []lint.Problem{problems[0], problems[2]},
+			want:     0,
+		},
+		{
+			label:    "only warnings in strict mode",
+			problems: []lint.Problem{problems[0], problems[2]},
+			strict:   true,
+			want:     2,
+		},
+		{
+			label:    "errors and warnings",
+			problems: problems,
+			want:     1,
+		},
+		{
+			label:    "errors and warnings in strict mode",
+			problems: problems,
+			strict:   true,
+			want:     3,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			if got := countFailures(tt.problems, tt.strict); got != tt.want {
+				t.Errorf("%d != %d", got, tt.want)
+				return
+			}
+		})
+	}
+}
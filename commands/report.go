@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/log"
+	"github.com/nasa9084/gmac/report"
+)
+
+var reportCommand *flags.Command
+
+func init() {
+	reportCommand = must(parser.AddCommand("report", "Generate a report of filters", "Generate a Markdown or HTML document of filters grouped by destination label", &ReportCommand{}))
+}
+
+type ReportCommand struct {
+	Target string `short:"f" long:"filename" description:"resource file of filters. if not specified, the current filters are used"`
+	Format string `long:"format" choice:"markdown" choice:"html" default:"markdown" description:"format of the report"`
+	Title  string `long:"title" description:"title of the report" default:"Mail Filters"`
+	Counts bool   `long:"counts" description:"include the number of messages matching each filter"`
+	Exact  bool   `long:"exact" description:"count messages exactly by fetching all pages, instead of using the estimation"`
+	Labels bool   `long:"labels" description:"include the label tree with colors"`
+}
+
+func (cmd *ReportCommand) Execute([]string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := report.Report{Title: cmd.Title}
+
+	var c *gmail.Client
+	if cmd.Target == "" || cmd.Counts || cmd.Labels {
+		var err error
+		c, err = newGmailClient(ctx, cmd.CredentialsFilePath(), cmd.RefreshToken())
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmd.Target != "" {
+		res, err := readResource(cmd.Target)
+		if err != nil {
+			return err
+		}
+		if res.Kind != gmail.ResourceTypeFilter {
+			return fmt.Errorf("unsupported resource kind: %s", res.Kind)
+		}
+		file, err := res.filterResource()
+		if err != nil {
+			return err
+		}
+		r.Filters = file.Filters
+	} else {
+		filters, err := c.ListFilters(ctx)
+		if err != nil {
+			return err
+		}
+		r.Filters = filters
+	}
+
+	if cmd.Counts {
+		r.Counts = make([]int64, 0, len(r.Filters))
+		for _, filter := range r.Filters {
+			q := filter.Criteria.String()
+			log.Vprintf("count messages: %s", q)
+			count, err := c.CountMessages(ctx, q, cmd.Exact)
+			if err != nil {
+				return err
+			}
+			r.Counts = append(r.Counts, count)
+		}
+	}
+
+	if cmd.Labels {
+		labels, err := c.ListLabels(ctx)
+		if err != nil {
+			return err
+		}
+		r.Labels = labels
+	}
+
+	if cmd.Format == "html" {
+		return report.WriteHTML(os.Stdout, r)
+	}
+	return report.WriteMarkdown(os.Stdout, r)
+}
+
+func (*ReportCommand) CredentialsFilePath() string {
+	val := reportCommand.FindOptionByLongName("credentials-file").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
+
+func (*ReportCommand) RefreshToken() string {
+	val := reportCommand.FindOptionByLongName("refresh-token").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/goccy/go-yaml"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/schema"
+)
+
+var (
+	schemaCommand   *flags.Command
+	validateCommand *flags.Command
+)
+
+func init() {
+	schemaCommand = must(parser.AddCommand("schema", "Show JSON Schema of resource files", "Show JSON Schema of resource files, which can be used by editors", &SchemaCommand{}))
+	validateCommand = must(parser.AddCommand("validate", "Validate a resource file", "Validate a resource file against its schema", &ValidateCommand{}))
+}
+
+// filterResourceFile is the whole content of a Filter resource file.
+type filterResourceFile struct {
+	Kind    string             `yaml:"kind"`
+	Filters []gmail.Filter     `yaml:"filters"`
+	Tests   []gmail.FilterTest `yaml:"tests,omitempty"`
+}
+
+func (filterResourceFile) JSONSchemaExtend(s *schema.Schema) {
+	s.Title = "gmac Filter resource"
+	s.Properties["kind"].Const = gmail.ResourceTypeFilter
+}
+
+// resourceSchemas are the schemas of resource files by their kind.
+var resourceSchemas = map[string]*schema.Schema{
+	gmail.ResourceTypeFilter: schema.Generate(reflect.TypeOf(filterResourceFile{})),
+}
+
+type SchemaCommand struct {
+	Kind string `long:"kind" choice:"Filter" default:"Filter" description:"kind of the resource"`
+}
+
+func (cmd *SchemaCommand) Execute([]string) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(resourceSchemas[cmd.Kind])
+}
+
+type ValidateCommand struct {
+	Target string `short:"f" long:"filename" required:"true" description:"resource file to be validated"`
+}
+
+func (cmd *ValidateCommand) Execute([]string) error {
+	b, err := readSource(cmd.Target)
+	if err != nil {
+		return err
+	}
+	errs, err := validateResource(b)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Target, err)
+	}
+	for _, e := range errs {
+		fmt.Printf("%s:%d:%d: %s\n", cmd.Target, e.line, e.column, e.ValidationError)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d errors found", len(errs))
+	}
+	return nil
+}
+
+// locatedError is a validation error with its position in the source.
+type locatedError struct {
+	*schema.ValidationError
+
+	line, column int
+}
+
+// validateResource validates the source of a resource file against
+// the schema of its kind, then returns all errors sorted by position.
+// An error is returned if the source is not a valid YAML document.
+func validateResource(source []byte) ([]locatedError, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(source, &v); err != nil {
+		return nil, err
+	}
+
+	var errs []*schema.ValidationError
+	doc, ok := v.(map[string]interface{})
+	if !ok {
+		errs = append(errs, &schema.ValidationError{Message: "resource must be a mapping"})
+	} else if kind, ok := doc["kind"]; !ok {
+		errs = append(errs, &schema.ValidationError{Message: "kind is not found"})
+	} else if s, ok := resourceSchemas[fmt.Sprint(kind)]; !ok {
+		errs = append(errs, &schema.ValidationError{Path: []interface{}{"kind"}, Message: fmt.Sprintf("unsupported resource kind: %v", kind)})
+	} else {
+		errs = s.Validate(v)
+	}
+
+	srcmap := newSourceMap(source)
+	located := make([]locatedError, 0, len(errs))
+	for _, e := range errs {
+		line, column := srcmap.position(e.Path...)
+		located = append(located, locatedError{ValidationError: e, line: line, column: column})
+	}
+	sort.SliceStable(located, func(i, j int) bool {
+		if located[i].line != located[j].line {
+			return located[i].line < located[j].line
+		}
+		return located[i].column < located[j].column
+	})
+	return located, nil
+}
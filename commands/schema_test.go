@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestValidateResource(t *testing.T) {
+	const source = `kind: Filter
+filters:
+  - criteria:
+      from: foo@example.com
+    action:
+      never_mark_as_apam: true
+      important: sometimes
+  - action:
+      category: updates
+`
+	errs, err := validateResource([]byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, fmt.Sprintf("%d:%d: %s", e.line, e.column, e.ValidationError))
+	}
+	want := []string{
+		"6:7: filters.0.action.never_mark_as_apam: unknown key `never_mark_as_apam`",
+		"7:7: filters.0.action.important: unknown value sometimes, must be one of: always, never",
+		"8:5: filters.1: required key `criteria` not found",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%q != %q", got, want)
+		return
+	}
+}
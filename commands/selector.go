@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+// selector selects filters by their fields, e.g.
+// `action.add_label=Foo,criteria.from!=foo@example.com`.
+// The keys are the paths of the fields in the resource file, and all of
+// the requirements must be satisfied.
+type selector []requirement
+
+type requirement struct {
+	path  []string
+	equal bool
+	value string
+}
+
+func parseSelector(s string) (selector, error) {
+	var sel selector
+	for _, expr := range strings.Split(s, ",") {
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			continue
+		}
+		var req requirement
+		var key string
+		switch {
+		case strings.Contains(expr, "!="):
+			i := strings.Index(expr, "!=")
+			key, req.value = expr[:i], expr[i+2:]
+		case strings.Contains(expr, "=="):
+			i := strings.Index(expr, "==")
+			key, req.value, req.equal = expr[:i], expr[i+2:], true
+		case strings.Contains(expr, "="):
+			i := strings.Index(expr, "=")
+			key, req.value, req.equal = expr[:i], expr[i+1:], true
+		default:
+			return nil, fmt.Errorf("invalid selector %q: operator must be one of =, == or !=", expr)
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("invalid selector %q: key is empty", expr)
+		}
+		req.path = strings.Split(key, ".")
+		req.value = strings.TrimSpace(req.value)
+		sel = append(sel, req)
+	}
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("selector is empty")
+	}
+	return sel, nil
+}
+
+// matches reports whether the filter satisfies all the requirements.
+func (sel selector) matches(f gmail.Filter) (bool, error) {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return false, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return false, err
+	}
+	for _, req := range sel {
+		if req.matches(v) != req.equal {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matches reports whether the value at the path is equal to the value
+// of the requirement. If the value at the path is an array, any of the
+// elements must be equal.
+func (req requirement) matches(v interface{}) bool {
+	for _, key := range req.path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if v, ok = m[key]; !ok {
+			return req.value == "" || req.value == "false"
+		}
+	}
+	values := []interface{}{v}
+	if a, ok := v.([]interface{}); ok {
+		values = a
+	}
+	for _, value := range values {
+		var s string
+		switch value := value.(type) {
+		case string:
+			s = value
+		case float64:
+			s = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			s = fmt.Sprint(value)
+		}
+		if s == req.value {
+			return true
+		}
+	}
+	return false
+}
+
+// selectFilters returns the filters matching the selector.
+func selectFilters(filters []gmail.Filter, sel selector) ([]gmail.Filter, error) {
+	var selected []gmail.Filter
+	for _, f := range filters {
+		ok, err := sel.matches(f)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			selected = append(selected, f)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+func TestSelector(t *testing.T) {
+	filter := gmail.Filter{
+		ID: "id1",
+		Criteria: gmail.FilterCriteria{
+			From:       gmail.Terms{"foo@example.com", "bar@example.com"},
+			LargerThan: 1000,
+		},
+		Action: gmail.FilterAction{
+			AddLabel: "Foo",
+			Archive:  true,
+		},
+	}
+	tests := []struct {
+		label    string
+		selector string
+		want     bool
+	}{
+		{
+			label:    "id",
+			selector: "id=id1",
+			want:     true,
+		},
+		{
+			label:    "any of terms",
+			selector: "criteria.from == bar@example.com",
+			want:     true,
+		},
+		{
+			label:    "all requirements",
+			selector: "action.add_label=Foo,action.archive=true,criteria.larger_than=1000",
+			want:     true,
+		},
+		{
+			label:    "not equal",
+			selector: "action.add_label!=Foo",
+			want:     false,
+		},
+		{
+			label:    "not equal to missing field",
+			selector: "criteria.to!=foo@example.com",
+			want:     true,
+		},
+		{
+			label:    "missing bool field is false",
+			selector: "action.star=false",
+			want:     true,
+		},
+		{
+			label:    "unmatched",
+			selector: "action.add_label=Foo,criteria.from=baz@example.com",
+			want:     false,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			sel, err := parseSelector(tt.selector)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			got, err := sel.matches(filter)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("%t != %t", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestParseSelectorError(t *testing.T) {
+	tests := []struct {
+		label    string
+		selector string
+		want     string
+	}{
+		{
+			label:    "empty",
+			selector: " , ",
+			want:     "selector is empty",
+		},
+		{
+			label:    "no operator",
+			selector: "id",
+			want:     `invalid selector "id": operator must be one of =, == or !=`,
+		},
+		{
+			label:    "empty key",
+			selector: "=foo",
+			want:     `invalid selector "=foo": key is empty`,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			_, err := parseSelector(tt.selector)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("%v != %s", err, tt.want)
+				return
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"github.com/goccy/go-yaml/ast"
+	yamlparser "github.com/goccy/go-yaml/parser"
+	"github.com/goccy/go-yaml/token"
+)
+
+// sourceMap maps paths in a YAML document to positions in its source.
+type sourceMap struct {
+	root ast.Node
+}
+
+func newSourceMap(source []byte) *sourceMap {
+	f, err := yamlparser.ParseBytes(source, 0)
+	if err != nil || len(f.Docs) == 0 {
+		return &sourceMap{}
+	}
+	return &sourceMap{root: f.Docs[0].Body}
+}
+
+// position returns the line and the column of the node at given path.
+// each element of the path is a mapping key (string) or a sequence
+// index (int). If the path is not found, position returns the position
+// of the deepest node found.
+func (m *sourceMap) position(path ...interface{}) (line, column int) {
+	node := m.root
+	if node == nil {
+		return 0, 0
+	}
+	pos := startPosition(node)
+	for _, elem := range path {
+		next, key := lookupNode(node, elem)
+		if next == nil {
+			break
+		}
+		node = next
+		if key != nil {
+			pos = key.GetToken().Position
+		} else {
+			pos = startPosition(next)
+		}
+	}
+	return pos.Line, pos.Column
+}
+
+// pathAt returns the path of the mapping key or the sequence item which
+// starts at given position, or nil if not found. If a sequence item
+// starts with a mapping key, the path of the key is returned.
+func (m *sourceMap) pathAt(line, column int) []interface{} {
+	var found []interface{}
+	walkNodes(m.root, nil, func(path []interface{}, pos *token.Position) {
+		if pos.Line == line && pos.Column == column {
+			found = path
+		}
+	})
+	return found
+}
+
+// lookupNode returns the child node of given path element.
+// for mapping, the key node is also returned.
+func lookupNode(node ast.Node, elem interface{}) (value ast.Node, key ast.Node) {
+	switch n := node.(type) {
+	case *ast.AnchorNode:
+		return lookupNode(n.Value, elem)
+	case *ast.MappingValueNode:
+		if name, ok := elem.(string); ok && n.Key.String() == name {
+			return n.Value, n.Key
+		}
+	case *ast.MappingNode:
+		name, ok := elem.(string)
+		if !ok {
+			return nil, nil
+		}
+		for _, v := range n.Values {
+			if v.Key.String() == name {
+				return v.Value, v.Key
+			}
+		}
+	case *ast.SequenceNode:
+		i, ok := elem.(int)
+		if !ok || i < 0 || i >= len(n.Values) {
+			return nil, nil
+		}
+		return n.Values[i], nil
+	}
+	return nil, nil
+}
+
+// startPosition returns the position where given node starts.
+// for block mapping, it is the position of the first key.
+func startPosition(node ast.Node) *token.Position {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		if !n.IsFlowStyle && len(n.Values) > 0 {
+			return startPosition(n.Values[0])
+		}
+	case *ast.MappingValueNode:
+		return n.Key.GetToken().Position
+	}
+	return node.GetToken().Position
+}
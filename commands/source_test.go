@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSourceMapPosition(t *testing.T) {
+	const source = `kind: Filter
+filters:
+  - criteria:
+      from: foo@example.com
+    action:
+      add_label: foo
+  - criteria:
+      any_of:
+        - query: bar
+        - query: baz
+    action:
+      star: true
+`
+	m := newSourceMap([]byte(source))
+	tests := []struct {
+		label  string
+		path   []interface{}
+		line   int
+		column int
+	}{
+		{
+			label:  "top level key",
+			path:   []interface{}{"filters"},
+			line:   2,
+			column: 1,
+		},
+		{
+			label:  "sequence item",
+			path:   []interface{}{"filters", 1, "action"},
+			line:   11,
+			column: 5,
+		},
+		{
+			label:  "nested",
+			path:   []interface{}{"filters", 1, "criteria", "any_of", 1, "query"},
+			line:   10,
+			column: 11,
+		},
+		{
+			label:  "not found",
+			path:   []interface{}{"filters", 0, "criteria", "subject"},
+			line:   3,
+			column: 5,
+		},
+	}
+	for i, tt := range tests {
+		line, column := m.position(tt.path...)
+		if line != tt.line || column != tt.column {
+			t.Errorf("%s: %d:%d != %d:%d", strconv.Itoa(i)+"."+tt.label, line, column, tt.line, tt.column)
+			return
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/log"
+)
+
+var statsCommand *flags.Command
+
+func init() {
+	statsCommand = must(parser.AddCommand("stats", "Show the number of messages matching each filter", "Show the number of existing messages matching each filter", &StatsCommand{}))
+}
+
+type StatsCommand struct {
+	Target string `short:"f" long:"filename" description:"resource file of filters. if not specified, the current filters are used"`
+	Exact  bool   `long:"exact" description:"count messages exactly by fetching all pages, instead of using the estimation"`
+	Sort   bool   `long:"sort" description:"sort filters by the number of matching messages in descending order"`
+}
+
+func (cmd *StatsCommand) Execute([]string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := newGmailClient(ctx, cmd.CredentialsFilePath(), cmd.RefreshToken())
+	if err != nil {
+		return err
+	}
+
+	var filters []gmail.Filter
+	if cmd.Target != "" {
+		res, err := readResource(cmd.Target)
+		if err != nil {
+			return err
+		}
+		if res.Kind != gmail.ResourceTypeFilter {
+			return fmt.Errorf("unsupported resource kind: %s", res.Kind)
+		}
+		file, err := res.filterResource()
+		if err != nil {
+			return err
+		}
+		filters = file.Filters
+	} else {
+		filters, err = c.ListFilters(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	stats := make([]filterStat, 0, len(filters))
+	for i, filter := range filters {
+		q := filter.Criteria.String()
+		log.Vprintf("count messages: %s", q)
+		count, err := c.CountMessages(ctx, q, cmd.Exact)
+		if err != nil {
+			return err
+		}
+		stats = append(stats, filterStat{index: i, filter: filter, count: count})
+	}
+	if cmd.Sort {
+		sort.SliceStable(stats, func(i, j int) bool { return stats[i].count > stats[j].count })
+	}
+
+	return cmd.write(stats)
+}
+
+type filterStat struct {
+	index  int
+	filter gmail.Filter
+	count  int64
+}
+
+func (cmd *StatsCommand) write(stats []filterStat) error {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 2, 1, ' ', 0)
+
+	header := "ESTIMATED"
+	if cmd.Exact {
+		header = "MATCHES"
+	}
+	fmt.Fprintf(w, "FILTER\t%s\tCRITERIA\tACTION\n", header)
+
+	var zero []string
+	for _, stat := range stats {
+		fmt.Fprintf(w, "#%d\t%d\t%s\t%s\n", stat.index+1, stat.count, stat.filter.Criteria.String(), stat.filter.Action.String())
+		if stat.count == 0 {
+			zero = append(zero, fmt.Sprintf("#%d", stat.index+1))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(zero) > 0 {
+		fmt.Fprintf(&buf, "\n%d of %d filters match no messages: %s\n", len(zero), len(stats), strings.Join(zero, ", "))
+	}
+
+	_, err := buf.WriteTo(os.Stdout)
+	return err
+}
+
+func (*StatsCommand) CredentialsFilePath() string {
+	val := statsCommand.FindOptionByLongName("credentials-file").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
+
+func (*StatsCommand) RefreshToken() string {
+	val := statsCommand.FindOptionByLongName("refresh-token").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
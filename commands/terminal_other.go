@@ -0,0 +1,12 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package commands
+
+import "os"
+
+// terminalWidth always returns false, as the terminal width cannot be
+// got on this platform.
+func terminalWidth(f *os.File) (int, bool) {
+	return 0, false
+}
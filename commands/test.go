@@ -0,0 +1,208 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/log"
+	"github.com/nasa9084/gmac/message"
+)
+
+var testCommand *flags.Command
+
+func init() {
+	testCommand = must(parser.AddCommand("test", "Test filters against local messages", "Test filters against local messages without Gmail access", &TestCommand{}))
+}
+
+type TestCommand struct {
+	Target   string   `short:"f" long:"filename" required:"yes"`
+	Messages []string `short:"m" long:"messages" description:"path to messages: RFC822 message file, mbox file, Maildir or directory. if not specified, test cases in the resource file are run"`
+	Format   string   `long:"format" choice:"tap" choice:"junit" default:"tap" description:"output format of test cases"`
+}
+
+func (cmd *TestCommand) Execute([]string) error {
+	res, err := readResource(cmd.Target)
+	if err != nil {
+		return err
+	}
+	if res.Kind != gmail.ResourceTypeFilter {
+		return fmt.Errorf("unsupported resource kind: %s", res.Kind)
+	}
+	file, err := res.filterResource()
+	if err != nil {
+		return err
+	}
+	filters := file.Filters
+
+	if len(cmd.Messages) == 0 {
+		return cmd.runTests(filters, file.Tests)
+	}
+
+	var msgs []*message.Message
+	for _, path := range cmd.Messages {
+		m, err := message.Load(path)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, m...)
+	}
+
+	results := make([]*evaluation, 0, len(msgs))
+	for _, m := range msgs {
+		result, err := evaluateFilters(filters, m)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+
+	return writeEvaluations(os.Stdout, filters, results)
+}
+
+// evaluation is a result of evaluating filters against a message.
+type evaluation struct {
+	message *message.Message
+
+	// matched and unknown are indices of filters which match the message,
+	// and which cannot be evaluated locally.
+	matched []int
+	unknown []int
+
+	// labels and systemLabels are the resulting user labels and the IDs
+	// of the resulting system labels of the message. They are kept
+	// separately as a user label may have the same name as the ID of a
+	// system label, e.g. "IMPORTANT".
+	labels       []string
+	systemLabels []string
+
+	// actions are the actions of matched filters.
+	actions []gmail.FilterAction
+}
+
+// defaultLabels are the labels of a message which is just received.
+var defaultLabels = []string{"INBOX", "UNREAD"}
+
+func evaluateFilters(filters []gmail.Filter, m *message.Message) (*evaluation, error) {
+	result := evaluation{message: m}
+	labels := map[string]bool{}
+	systemLabels := map[string]bool{}
+	for _, label := range defaultLabels {
+		systemLabels[label] = true
+	}
+	for i, filter := range filters {
+		ok, err := message.Match(filter.Criteria.Node(), m)
+		if err != nil {
+			if _, unsupported := err.(*message.UnsupportedError); !unsupported {
+				return nil, fmt.Errorf("filter #%d: %w", i+1, err)
+			}
+			log.Vprintf("filter #%d: %s: %v", i+1, m.Name, err)
+			result.unknown = append(result.unknown, i)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		result.matched = append(result.matched, i)
+		result.actions = append(result.actions, filter.Action)
+		add, remove, err := filter.Action.SystemLabelChanges()
+		if err != nil {
+			return nil, fmt.Errorf("filter #%d: %w", i+1, err)
+		}
+		if filter.Action.AddLabel != "" {
+			labels[filter.Action.AddLabel] = true
+		}
+		for _, label := range add {
+			systemLabels[label] = true
+		}
+		for _, label := range remove {
+			delete(systemLabels, label)
+		}
+	}
+	result.labels = sortedKeys(labels)
+	result.systemLabels = sortedKeys(systemLabels)
+	return &result, nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// allLabels returns the user labels followed by the system labels.
+func (ev *evaluation) allLabels() []string {
+	all := make([]string, 0, len(ev.labels)+len(ev.systemLabels))
+	all = append(all, ev.labels...)
+	return append(all, ev.systemLabels...)
+}
+
+func writeEvaluations(w io.Writer, filters []gmail.Filter, results []*evaluation) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 2, 1, ' ', 0)
+
+	counts := make([]int, len(filters))
+	hasUnknown := false
+	for _, result := range results {
+		for _, i := range result.matched {
+			counts[i]++
+		}
+		if len(result.unknown) > 0 {
+			hasUnknown = true
+		}
+	}
+	fmt.Fprint(tw, "FILTER\tMATCHES\tCRITERIA\tACTION\n")
+	for i, filter := range filters {
+		fmt.Fprintf(tw, "#%d\t%d\t%s\t%s\n", i+1, counts[i], filter.Criteria.String(), filter.Action.String())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	buf.WriteString("\n")
+
+	tw = tabwriter.NewWriter(&buf, 0, 2, 1, ' ', 0)
+	fmt.Fprint(tw, "MESSAGE\tSUBJECT\tFILTERS\tLABELS\tSYSTEM LABELS\n")
+	for _, result := range results {
+		refs := make([]string, 0, len(result.matched)+len(result.unknown))
+		for _, i := range result.matched {
+			refs = append(refs, "#"+strconv.Itoa(i+1))
+		}
+		for _, i := range result.unknown {
+			refs = append(refs, "#"+strconv.Itoa(i+1)+"?")
+		}
+		if len(refs) == 0 {
+			refs = append(refs, "-")
+		}
+		userLabels := strings.Join(result.labels, ",")
+		if userLabels == "" {
+			userLabels = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			result.message.Name,
+			result.message.Subject(),
+			strings.Join(refs, ","),
+			userLabels,
+			strings.Join(result.systemLabels, ","),
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if hasUnknown {
+		buf.WriteString("\n(?: the filter uses operators which cannot be evaluated locally, e.g. label: or is:)\n")
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/message"
+)
+
+func TestEvaluateFilters(t *testing.T) {
+	const msg = "From: ci@example.com\r\n" +
+		"To: team@example.com\r\n" +
+		"Subject: [FAILED] build #12\r\n" +
+		"\r\n" +
+		"build failed\r\n"
+	m, err := message.Read("msg", strings.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filters := []gmail.Filter{
+		{
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"ci@example.com"}},
+			Action:   gmail.FilterAction{AddLabel: "CI", Archive: true},
+		},
+		{
+			Criteria: gmail.FilterCriteria{Subject: gmail.Terms{"[FAILED]"}},
+			Action:   gmail.FilterAction{Star: true},
+		},
+		{
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"nobody@example.com"}},
+			Action:   gmail.FilterAction{Delete: true},
+		},
+		{
+			Criteria: gmail.FilterCriteria{Query: "label:foo"},
+			Action:   gmail.FilterAction{MarkAsRead: true},
+		},
+		{
+			// user label with the same name as a system label
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"ci@example.com"}},
+			Action:   gmail.FilterAction{AddLabel: "INBOX"},
+		},
+	}
+
+	got, err := evaluateFilters(filters, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{0, 1, 4}; !reflect.DeepEqual(got.matched, want) {
+		t.Errorf("unexpected matched filters: %v != %v", got.matched, want)
+		return
+	}
+	if want := []int{3}; !reflect.DeepEqual(got.unknown, want) {
+		t.Errorf("unexpected unknown filters: %v != %v", got.unknown, want)
+		return
+	}
+	if want := []string{"CI", "INBOX"}; !reflect.DeepEqual(got.labels, want) {
+		t.Errorf("unexpected labels: %v != %v", got.labels, want)
+		return
+	}
+	if want := []string{"STARRED", "UNREAD"}; !reflect.DeepEqual(got.systemLabels, want) {
+		t.Errorf("unexpected system labels: %v != %v", got.systemLabels, want)
+		return
+	}
+}
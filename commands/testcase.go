@@ -0,0 +1,269 @@
+package commands
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/message"
+)
+
+// testResult is a result of a test case defined in the resource file.
+type testResult struct {
+	name     string
+	failures []string
+	// notes are diagnostic messages which do not make the test fail.
+	notes []string
+}
+
+func (cmd *TestCommand) runTests(filters []gmail.Filter, tests []gmail.FilterTest) error {
+	if len(tests) == 0 {
+		return errors.New("no test cases: add `tests` to the resource file, or specify messages via --messages")
+	}
+
+	results := make([]testResult, 0, len(tests))
+	failed := 0
+	for i, tt := range tests {
+		result, err := runTest(filters, tt)
+		if err != nil {
+			return fmt.Errorf("test #%d: %w", i+1, err)
+		}
+		if result.name == "" {
+			result.name = "test #" + strconv.Itoa(i+1)
+		}
+		if len(result.failures) > 0 {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	var err error
+	switch cmd.Format {
+	case "junit":
+		err = writeJUnit(os.Stdout, cmd.Target, results)
+	default:
+		err = writeTAP(os.Stdout, results)
+	}
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tests failed", failed, len(results))
+	}
+	return nil
+}
+
+func runTest(filters []gmail.Filter, tt gmail.FilterTest) (testResult, error) {
+	result := testResult{name: tt.Name}
+	ev, err := evaluateFilters(filters, newTestMessage(tt.Name, tt.Message))
+	if err != nil {
+		return result, err
+	}
+	for _, i := range ev.unknown {
+		result.notes = append(result.notes, fmt.Sprintf("filter #%d cannot be evaluated locally, treated as not matched", i+1))
+	}
+	failures, err := checkExpectation(tt.Expect, ev)
+	if err != nil {
+		return result, err
+	}
+	result.failures = failures
+	return result, nil
+}
+
+// newTestMessage builds a message from synthetic message definition.
+func newTestMessage(name string, tm gmail.TestMessage) *message.Message {
+	header := mail.Header{}
+	for k, v := range tm.Headers {
+		header[textproto.CanonicalMIMEHeaderKey(k)] = []string{v}
+	}
+	for k, v := range map[string]string{"From": tm.From, "To": tm.To, "Cc": tm.Cc, "Subject": tm.Subject} {
+		if v != "" {
+			header[k] = []string{v}
+		}
+	}
+	m := &message.Message{
+		Name:        name,
+		Header:      header,
+		Body:        tm.Body,
+		Attachments: tm.Attachments,
+		Size:        tm.Size,
+	}
+	if m.Size == 0 {
+		for k, values := range header {
+			for _, v := range values {
+				m.Size += int64(len(k) + len(": ") + len(v) + len("\r\n"))
+			}
+		}
+		m.Size += int64(len("\r\n") + len(tm.Body))
+	}
+	return m
+}
+
+func checkExpectation(exp gmail.FilterExpectation, ev *evaluation) ([]string, error) {
+	var failures []string
+	// expected labels may be either user labels or IDs of system
+	// labels, while the actions are checked only with system labels
+	labels := map[string]bool{}
+	for _, label := range ev.allLabels() {
+		labels[label] = true
+	}
+	system := map[string]bool{}
+	for _, label := range ev.systemLabels {
+		system[label] = true
+	}
+	for _, label := range exp.Labels {
+		if !labels[label] {
+			failures = append(failures, fmt.Sprintf("expected label %q, but labels are [%s]", label, strings.Join(ev.allLabels(), ", ")))
+		}
+	}
+	for _, label := range exp.NotLabels {
+		if labels[label] {
+			failures = append(failures, fmt.Sprintf("unexpected label %q", label))
+		}
+	}
+
+	checkBool := func(name string, want *bool, got bool) {
+		if want != nil && *want != got {
+			failures = append(failures, fmt.Sprintf("expected %s to be %t, but %t", name, *want, got))
+		}
+	}
+	checkBool("archive", exp.Archive, !system["INBOX"])
+	checkBool("mark_as_read", exp.MarkAsRead, !system["UNREAD"])
+	checkBool("star", exp.Star, system["STARRED"])
+	checkBool("delete", exp.Delete, system["TRASH"])
+	neverSpam := false
+	important := gmail.FilterActionImportant("")
+	var forwards []string
+	for _, action := range ev.actions {
+		if action.NeverMarkAsSpam {
+			neverSpam = true
+		}
+		if action.Important != "" {
+			important = action.Important
+		}
+		if action.ForwardTo != "" {
+			forwards = append(forwards, action.ForwardTo)
+		}
+	}
+	checkBool("never_mark_as_spam", exp.NeverMarkAsSpam, neverSpam)
+
+	if exp.Important != "" && exp.Important != important {
+		failures = append(failures, fmt.Sprintf("expected important to be %q, but %q", exp.Important, important))
+	}
+	if exp.Category != "" {
+		add, _, err := gmail.FilterAction{Category: exp.Category}.SystemLabelChanges()
+		if err != nil {
+			return nil, err
+		}
+		if !system[add[0]] {
+			failures = append(failures, fmt.Sprintf("expected category %q, but labels are [%s]", exp.Category, strings.Join(ev.allLabels(), ", ")))
+		}
+	}
+	if exp.ForwardTo != "" {
+		found := false
+		for _, addr := range forwards {
+			if addr == exp.ForwardTo {
+				found = true
+			}
+		}
+		if !found {
+			failures = append(failures, fmt.Sprintf("expected to be forwarded to %s, but forwarded to [%s]", exp.ForwardTo, strings.Join(forwards, ", ")))
+		}
+	}
+	if exp.Matches != nil && *exp.Matches != len(ev.matched) {
+		failures = append(failures, fmt.Sprintf("expected %d filters to match, but %d filters matched", *exp.Matches, len(ev.matched)))
+	}
+	sort.Strings(failures)
+	return failures, nil
+}
+
+// writeTAP writes test results in Test Anything Protocol version 13.
+func writeTAP(w io.Writer, results []testResult) error {
+	var b strings.Builder
+	b.WriteString("TAP version 13\n")
+	fmt.Fprintf(&b, "1..%d\n", len(results))
+	for i, result := range results {
+		status := "ok"
+		if len(result.failures) > 0 {
+			status = "not ok"
+		}
+		fmt.Fprintf(&b, "%s %d - %s\n", status, i+1, strings.Replace(result.name, "#", `\#`, -1))
+		for _, note := range result.notes {
+			fmt.Fprintf(&b, "# %s\n", note)
+		}
+		if len(result.failures) > 0 {
+			b.WriteString("  ---\n  failures:\n")
+			for _, failure := range result.failures {
+				fmt.Fprintf(&b, "    - %s\n", strconv.Quote(failure))
+			}
+			b.WriteString("  ...\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+// writeJUnit writes test results in JUnit XML format.
+func writeJUnit(w io.Writer, name string, results []testResult) error {
+	suite := junitTestSuite{
+		Name:  name,
+		Tests: len(results),
+	}
+	for _, result := range results {
+		tc := junitTestCase{
+			Name:      result.name,
+			ClassName: name,
+			SystemOut: strings.Join(result.notes, "\n"),
+		}
+		if len(result.failures) > 0 {
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: result.failures[0],
+				Content: strings.Join(result.failures, "\n"),
+			}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
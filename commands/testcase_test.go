@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+func TestRunTest(t *testing.T) {
+	const resource = `
+- criteria:
+    from: ci@ourco.com
+    subject: "[FAILED]"
+  action:
+    add_label: CI/Failures
+    star: true
+- criteria:
+    from: newsletter@example.com
+  action:
+    archive: true
+    category: promotions
+`
+	var filters []gmail.Filter
+	if err := yaml.Unmarshal([]byte(resource), &filters); err != nil {
+		t.Fatal(err)
+	}
+	yes, no, one := true, false, 1
+
+	tests := []struct {
+		label string
+		input gmail.FilterTest
+		want  int // number of failures
+	}{
+		{
+			label: "labels and actions",
+			input: gmail.FilterTest{
+				Message: gmail.TestMessage{From: "ci@ourco.com", Subject: "[FAILED] build #12"},
+				Expect: gmail.FilterExpectation{
+					Labels:  []string{"CI/Failures", "INBOX"},
+					Star:    &yes,
+					Archive: &no,
+				},
+			},
+			want: 0,
+		},
+		{
+			label: "category alias and matches",
+			input: gmail.FilterTest{
+				Message: gmail.TestMessage{Headers: map[string]string{"from": "News <newsletter@example.com>"}},
+				Expect: gmail.FilterExpectation{
+					Archive:  &yes,
+					Category: "promotion",
+					Matches:  &one,
+				},
+			},
+			want: 0,
+		},
+		{
+			label: "failures",
+			input: gmail.FilterTest{
+				Message: gmail.TestMessage{From: "ci@ourco.com", Subject: "[PASSED] build #13"},
+				Expect: gmail.FilterExpectation{
+					Labels:    []string{"CI/Failures"},
+					NotLabels: []string{"INBOX"},
+					Star:      &yes,
+				},
+			},
+			want: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			got, err := runTest(filters, tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.failures) != tt.want {
+				t.Errorf("unexpected failures: %v", got.failures)
+				return
+			}
+		})
+	}
+}
+
+func TestWriteTAP(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeTAP(&buf, []testResult{
+		{name: "passed #1"},
+		{name: "failed", failures: []string{"expected label \"foo\""}, notes: []string{"note"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"TAP version 13",
+		"1..2",
+		`ok 1 - passed \#1`,
+		"not ok 2 - failed",
+		"# note",
+		"  ---",
+		"  failures:",
+		`    - "expected label \"foo\""`,
+		"  ...",
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%s", buf.String())
+		return
+	}
+}
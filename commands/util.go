@@ -1,27 +1,36 @@
 package commands
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/goccy/go-yaml"
 	"github.com/spf13/afero"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
-	"google.golang.org/api/gmail/v1"
+	gmailapi "google.golang.org/api/gmail/v1"
 
+	"github.com/nasa9084/gmac/gmail"
 	"github.com/nasa9084/gmac/log"
 )
 
 var oauthScope = []string{
-	gmail.GmailLabelsScope,
-	gmail.GmailModifyScope,
-	gmail.GmailSettingsBasicScope,
+	gmailapi.GmailLabelsScope,
+	gmailapi.GmailModifyScope,
+	gmailapi.GmailSettingsBasicScope,
 }
 
 var fs = afero.NewOsFs()
@@ -37,6 +46,188 @@ func (r *raw) UnmarshalYAML(data []byte) error {
 	return nil
 }
 
+// resource is a resource file which is decoded partially.
+// the rest of the resource is decoded according to the kind.
+type resource struct {
+	Kind string         `yaml:"kind"`
+	Rest map[string]raw `yaml:",inline"`
+
+	// name is the path to the resource file.
+	name string
+	// source is the whole content of the resource file.
+	source []byte
+}
+
+// readResource reads a resource file from given path.
+// if the path is "-", the resource is read from stdin.
+func readResource(target string) (*resource, error) {
+	b, err := readSource(target)
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource{name: target, source: b}
+	if err := yaml.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	if res.Kind == "" {
+		return nil, errors.New("kind is not found")
+	}
+	return &res, nil
+}
+
+// readFilterResource reads the filters from the resource file of given
+// path.
+func readFilterResource(target string) ([]gmail.Filter, error) {
+	res, err := readResource(target)
+	if err != nil {
+		return nil, err
+	}
+	if res.Kind != gmail.ResourceTypeFilter {
+		return nil, fmt.Errorf("unknown resource kind: %s", res.Kind)
+	}
+	file, err := res.filterResource()
+	if err != nil {
+		return nil, err
+	}
+	return file.Filters, nil
+}
+
+// readSource reads the content of a file from given path.
+// if the path is "-", the content is read from stdin.
+func readSource(target string) ([]byte, error) {
+	var r io.Reader
+	switch target {
+	case "-":
+		r = stdin
+	default:
+		f, err := os.Open(target)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		r = f
+	}
+	return ioutil.ReadAll(r)
+}
+
+// decode decodes the whole resource file into v strictly. Unknown keys
+// are reported with their position, and the closest known key if any.
+func (r *resource) decode(v interface{}) error {
+	dec := yaml.NewDecoder(bytes.NewReader(r.source), yaml.DisallowUnknownField())
+	if err := dec.Decode(v); err != nil {
+		if m := unknownFieldPattern.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			column, _ := strconv.Atoi(m[2])
+			msg := fmt.Sprintf("unknown key `%s`", m[3])
+			if suggestion := r.suggestKey(line, column, m[3]); suggestion != "" {
+				msg += fmt.Sprintf(", did you mean `%s`?", suggestion)
+			}
+			return fmt.Errorf("%s:%d:%d: %s", r.name, line, column, msg)
+		}
+		return fmt.Errorf("%s: %w", r.name, err)
+	}
+	return nil
+}
+
+var unknownFieldPattern = regexp.MustCompile(`^\[(\d+):(\d+)\] unknown field "(.*)"`)
+
+// suggestKey returns the known key which is the closest to the unknown
+// key at given position.
+func (r *resource) suggestKey(line, column int, key string) string {
+	s, ok := resourceSchemas[r.Kind]
+	if !ok {
+		return ""
+	}
+	path := newSourceMap(r.source).pathAt(line, column)
+	if len(path) == 0 {
+		return ""
+	}
+	parent := s.Lookup(path[:len(path)-1]...)
+	if parent == nil {
+		return ""
+	}
+	candidates := make([]string, 0, len(parent.Properties))
+	for name := range parent.Properties {
+		candidates = append(candidates, name)
+	}
+	return closest(key, candidates)
+}
+
+// closest returns the candidate which is the closest to s in edit
+// distance, or empty string if no candidate is close enough.
+func closest(s string, candidates []string) string {
+	sort.Strings(candidates)
+	threshold := len(s) / 3
+	if threshold < 2 {
+		threshold = 2
+	}
+	var found string
+	for _, c := range candidates {
+		if d := editDistance(s, c); d <= threshold {
+			found, threshold = c, d-1
+		}
+	}
+	return found
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if d := prev[j] + 1; d < cur[j] {
+				cur[j] = d
+			}
+			if d := cur[j-1] + 1; d < cur[j] {
+				cur[j] = d
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// filterResource decodes the resource as a Filter resource.
+func (r *resource) filterResource() (*filterResourceFile, error) {
+	var file filterResourceFile
+	if err := r.decode(&file); err != nil {
+		return nil, err
+	}
+	if len(r.Rest["filters"]) == 0 {
+		return nil, errors.New("required key `filters` not found")
+	}
+	return &file, nil
+}
+
+// newGmailClient creates a new Gmail client with OAuth config and token
+// read from given credentials file and refresh token.
+func newGmailClient(ctx context.Context, credentialsFilePath, refreshToken string) (*gmail.Client, error) {
+	oauthConfig, err := getOAuthConfig(credentialsFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := getToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return gmail.New(ctx, oauthConfig, token)
+}
+
 func getOAuthConfig(credentialsFilepath string) (*oauth2.Config, error) {
 	defaultCredentialsFilepath := filepath.Join(configDir, "credentials.json")
 
@@ -120,3 +311,40 @@ func mustWriteString(w io.Writer, s string) {
 		panic(err)
 	}
 }
+
+// confirm asks the user a yes/no question via stdin, then returns true
+// if the answer is yes. The default answer is no.
+func confirm(w io.Writer, question string) (bool, error) {
+	fmt.Fprintf(w, "%s [y/N]: ", question)
+	answer, err := readLine(stdin)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// readLine reads a line from r. r is read byte by byte without
+// buffering, so following reads can read the next line.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF {
+			return string(line), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
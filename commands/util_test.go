@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/spf13/afero"
@@ -117,3 +119,111 @@ func TestGetOAuthConfig(t *testing.T) {
 		}
 	})
 }
+
+func TestResourceDecode(t *testing.T) {
+	tests := []struct {
+		label  string
+		source string
+		want   string
+	}{
+		{
+			label: "valid",
+			source: `kind: Filter
+filters:
+  - criteria:
+      from: foo@example.com
+    action:
+      never_mark_as_spam: true
+`,
+		},
+		{
+			label: "typo in action",
+			source: `kind: Filter
+filters:
+  - criteria:
+      from: foo@example.com
+    action:
+      never_mark_as_apam: true
+`,
+			want: "filters.yml:6:7: unknown key `never_mark_as_apam`, did you mean `never_mark_as_spam`?",
+		},
+		{
+			label: "typo in top level",
+			source: `kind: Filter
+filter:
+  - criteria:
+      from: foo@example.com
+`,
+			want: "filters.yml:2:1: unknown key `filter`, did you mean `filters`?",
+		},
+		{
+			label: "unknown key in nested criteria",
+			source: `kind: Filter
+filters:
+  - criteria:
+      any_of:
+        - from: foo@example.com
+        - hoge: bar
+    action:
+      star: true
+`,
+			want: "filters.yml:6:11: unknown key `hoge`",
+		},
+	}
+	for i, tt := range tests {
+		res := resource{Kind: "Filter", name: "filters.yml", source: []byte(tt.source)}
+		var file filterResourceFile
+		err := res.decode(&file)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %+v", strconv.Itoa(i)+"."+tt.label, err)
+				return
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: %v != %s", strconv.Itoa(i)+"."+tt.label, err, tt.want)
+			return
+		}
+	}
+}
+
+func TestClosest(t *testing.T) {
+	candidates := []string{"archive", "mark_as_read", "star", "never_mark_as_spam"}
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "archve", want: "archive"},
+		{input: "stars", want: "star"},
+		{input: "mark_read", want: "mark_as_read"},
+		{input: "forward", want: ""},
+	}
+	for _, tt := range tests {
+		if got := closest(tt.input, candidates); got != tt.want {
+			t.Errorf("%s: %s != %s", tt.input, got, tt.want)
+			return
+		}
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	defer func() { stdin = os.Stdin }()
+	stdin = strings.NewReader("y\nNo\n\nYES")
+
+	for i, want := range []bool{true, false, false, true} {
+		var buf bytes.Buffer
+		got, err := confirm(&buf, "Delete?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("#%d: %t != %t", i, got, want)
+			return
+		}
+		if buf.String() != "Delete? [y/N]: " {
+			t.Errorf("unexpected prompt: %q", buf.String())
+			return
+		}
+	}
+}
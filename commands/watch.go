@@ -0,0 +1,352 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/log"
+	"github.com/nasa9084/gmac/state"
+)
+
+var watchCommand *flags.Command
+
+func init() {
+	watchCommand = must(parser.AddCommand("watch", "Watch drift of filters", "Compare the filters in Gmail with the resource file periodically, and report the drift", &WatchCommand{}))
+}
+
+type WatchCommand struct {
+	Target         string        `short:"f" long:"filename" required:"yes"`
+	Interval       time.Duration `long:"interval" default:"10m" description:"interval of checks"`
+	Exec           string        `long:"exec" description:"command to be run on drift events via sh -c (cmd /C on Windows), which receives the event in JSON from stdin"`
+	EventFile      string        `long:"event-file" description:"file to append drift events in JSON Lines"`
+	AutoCorrect    bool          `long:"auto-correct" description:"apply the resource file when drift is detected"`
+	PruneUnmanaged bool          `long:"prune-unmanaged" description:"treat the filters not managed by gmac as to be deleted, on detecting and correcting drift"`
+	State          string        `long:"state" description:"state recording the filters managed by gmac: path to the state file, file://<path>, dir://<directory>[?name=<name>] or URL of custom backend (default: ~/.gmac/state.yml)"`
+	LockTimeout    time.Duration `long:"lock-timeout" default:"1m" description:"duration to retry acquiring the state lock on correcting drift, 0 to fail at once if it is locked"`
+}
+
+// driftEvent is the event emitted when the drift between the filters in
+// Gmail and the resource file is changed.
+type driftEvent struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Drifted bool      `json:"drifted"`
+	// Missing is the filters in the resource file which do not exist
+	// in Gmail.
+	Missing []gmail.Filter `json:"missing"`
+	// Extra is the filters in Gmail which are to be deleted by apply,
+	// e.g. the managed filters removed from the resource file.
+	Extra []gmail.Filter `json:"extra"`
+	// Unmanaged is the filters in Gmail which are not managed by gmac,
+	// e.g. added in Gmail UI.
+	Unmanaged []gmail.Filter `json:"unmanaged"`
+	// Corrected is true if the drift is corrected by applying the
+	// resource file. Error is the error occurred on correcting.
+	Corrected bool   `json:"corrected"`
+	Error     string `json:"error,omitempty"`
+}
+
+// detectDrift compares the filters in Gmail with the filters in the
+// resource file as apply does.
+func detectDrift(live, filters []gmail.Filter, st *state.State, same func(live, filter gmail.Filter) (bool, error), pruneUnmanaged bool) (driftEvent, error) {
+	plan, err := planApply(live, filters, st, same, pruneUnmanaged)
+	if err != nil {
+		return driftEvent{}, err
+	}
+	e := driftEvent{
+		Missing:   nonNilFilters(plan.create),
+		Extra:     nonNilFilters(plan.delete),
+		Unmanaged: nonNilFilters(plan.unmanaged),
+	}
+	e.Drifted = len(e.Missing) > 0 || len(e.Extra) > 0 || len(e.Unmanaged) > 0
+	return e, nil
+}
+
+func nonNilFilters(filters []gmail.Filter) []gmail.Filter {
+	if filters == nil {
+		return []gmail.Filter{}
+	}
+	return filters
+}
+
+// correctable reports whether the drift can be corrected by apply.
+func (e driftEvent) correctable() bool {
+	return len(e.Missing) > 0 || len(e.Extra) > 0
+}
+
+// fingerprint returns the string identifying the drift, which is used to
+// emit events only when the drift is changed.
+func (e driftEvent) fingerprint() string {
+	var b strings.Builder
+	for _, f := range e.Missing {
+		fmt.Fprintf(&b, "+%s\n", f.String())
+	}
+	for _, f := range e.Extra {
+		fmt.Fprintf(&b, "-%s\n", f.ID)
+	}
+	for _, f := range e.Unmanaged {
+		fmt.Fprintf(&b, "?%s\n", f.ID)
+	}
+	return b.String()
+}
+
+// driftHook is called when the drift is changed.
+type driftHook func(ctx context.Context, e driftEvent) error
+
+// execHook returns driftHook which runs the command with the event in
+// JSON given from stdin. The command is run via the shell, so that it
+// can be written with quotes, pipes and so on.
+func execHook(command string) driftHook {
+	return func(ctx context.Context, e driftEvent) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		cmd := shellCommand(ctx, command)
+		cmd.Stdin = bytes.NewReader(b)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(), fmt.Sprintf("GMAC_DRIFTED=%t", e.Drifted))
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("exec %s: %w", command, err)
+		}
+		return nil
+	}
+}
+
+// shellCommand returns the command which runs given command line via
+// sh, or cmd on Windows.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// eventFileHook returns driftHook which appends the event to the file in
+// JSON Lines.
+func eventFileHook(path string) driftHook {
+	return func(_ context.Context, e driftEvent) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(append(b, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// watcher checks the drift periodically, and calls the hooks when the
+// drift is changed.
+type watcher struct {
+	interval time.Duration
+	check    func(ctx context.Context) (driftEvent, error)
+	hooks    []driftHook
+
+	checked bool
+	last    string
+}
+
+// run checks the drift until stop is closed or ctx is done. The check
+// in progress is completed when stop is closed, while it is aborted when
+// ctx is done.
+func (w *watcher) run(ctx context.Context, stop <-chan struct{}) error {
+	for {
+		w.tick(ctx)
+		select {
+		case <-stop:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(w.interval):
+		}
+	}
+}
+
+func (w *watcher) tick(ctx context.Context) {
+	e, err := w.check(ctx)
+	if err != nil {
+		// errors are usually temporary, e.g. network errors
+		log.Printf("failed to check drift: %v", err)
+		return
+	}
+
+	fingerprint := e.fingerprint()
+	first := !w.checked
+	changed := first || fingerprint != w.last
+	w.checked = true
+	w.last = fingerprint
+	if e.Corrected {
+		w.last = driftEvent{}.fingerprint()
+	}
+
+	switch {
+	case !changed:
+		log.Vprintf("drift is not changed")
+		return
+	case !e.Drifted && first:
+		log.Printf("No drift.")
+		return
+	case !e.Drifted:
+		log.Printf("Drift is resolved.")
+	default:
+		log.Printf("Drift detected: %d missing, %d extra and %d unmanaged filters", len(e.Missing), len(e.Extra), len(e.Unmanaged))
+		for _, f := range e.Missing {
+			log.Printf("  missing: %s", f.String())
+		}
+		for _, f := range e.Extra {
+			log.Printf("  extra: %s (id: %s)", f.String(), f.ID)
+		}
+		for _, f := range e.Unmanaged {
+			log.Printf("  unmanaged: %s (id: %s)", f.String(), f.ID)
+		}
+		switch {
+		case e.Corrected:
+			log.Printf("Drift is corrected.")
+		case e.Error != "":
+			log.Printf("failed to correct drift: %s", e.Error)
+		}
+	}
+
+	for _, hook := range w.hooks {
+		if err := hook(ctx, e); err != nil {
+			log.Printf("hook failed: %v", err)
+		}
+	}
+}
+
+func (cmd *WatchCommand) Execute([]string) error {
+	if cmd.Interval <= 0 {
+		return errors.New("--interval must be positive")
+	}
+	if cmd.Exec != "" && strings.TrimSpace(cmd.Exec) == "" {
+		return errors.New("--exec is empty")
+	}
+	// check the resource file before starting
+	filters, err := readFilterResource(cmd.Target)
+	if err != nil {
+		return err
+	}
+	if cmd.AutoCorrect {
+		warnFilterIDs(cmd.Target, filters)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := newGmailClient(ctx, cmd.CredentialsFilePath(), cmd.RefreshToken())
+	if err != nil {
+		return err
+	}
+	backend, err := openStateBackend(cmd.State)
+	if err != nil {
+		return err
+	}
+
+	w := &watcher{
+		interval: cmd.Interval,
+		check: func(ctx context.Context) (driftEvent, error) {
+			return cmd.check(ctx, c, backend)
+		},
+	}
+	if cmd.Exec != "" {
+		w.hooks = append(w.hooks, execHook(cmd.Exec))
+	}
+	if cmd.EventFile != "" {
+		w.hooks = append(w.hooks, eventFileHook(cmd.EventFile))
+	}
+
+	// the first signal stops watching after the check in progress, and
+	// the second one aborts it
+	stop := make(chan struct{})
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		s := <-sig
+		log.Printf("Received %s, stopping...", s)
+		close(stop)
+		<-sig
+		cancel()
+	}()
+
+	log.Printf("Watching drift of %s every %s", cmd.Target, cmd.Interval)
+	if err := w.run(ctx, stop); err != nil && err != context.Canceled {
+		return err
+	}
+	return nil
+}
+
+// check compares the filters in Gmail with the resource file, which is
+// read every time as it may be updated while watching.
+func (cmd *WatchCommand) check(ctx context.Context, c *gmail.Client, backend state.StateBackend) (driftEvent, error) {
+	filters, err := readFilterResource(cmd.Target)
+	if err != nil {
+		return driftEvent{}, err
+	}
+	st, err := backend.Load(ctx)
+	if err != nil {
+		return driftEvent{}, err
+	}
+	live, err := c.ListFilters(ctx)
+	if err != nil {
+		return driftEvent{}, err
+	}
+	e, err := detectDrift(live, filters, st, c.IsSameFilter, cmd.PruneUnmanaged)
+	if err != nil {
+		return driftEvent{}, err
+	}
+	e.Time = time.Now().UTC()
+	e.Source = cmd.Target
+
+	if cmd.AutoCorrect && e.correctable() {
+		log.Printf("Correct drift by applying %s", cmd.Target)
+		err := applyFilters(ctx, c, backend, cmd.Target, filters, applyOptions{
+			operation:      "watch",
+			lockTimeout:    cmd.LockTimeout,
+			pruneUnmanaged: cmd.PruneUnmanaged,
+		})
+		if err != nil {
+			e.Error = err.Error()
+		} else {
+			e.Corrected = true
+		}
+	}
+	return e, nil
+}
+
+func (*WatchCommand) CredentialsFilePath() string {
+	val := watchCommand.FindOptionByLongName("credentials-file").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
+
+func (*WatchCommand) RefreshToken() string {
+	val := watchCommand.FindOptionByLongName("refresh-token").Value()
+	if val == nil {
+		return ""
+	}
+	return val.(string)
+}
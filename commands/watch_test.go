@@ -0,0 +1,285 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/state"
+)
+
+func TestDetectDrift(t *testing.T) {
+	fooFilter := gmail.Filter{
+		Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+		Action:   gmail.FilterAction{AddLabel: "Foo"},
+	}
+	barFilter := gmail.Filter{
+		Criteria: gmail.FilterCriteria{From: gmail.Terms{"bar@example.com"}},
+		Action:   gmail.FilterAction{Archive: true},
+	}
+	withID := func(f gmail.Filter, id string) gmail.Filter {
+		f.ID = id
+		return f
+	}
+	same := func(live, filter gmail.Filter) (bool, error) {
+		return reflect.DeepEqual(live.Criteria, filter.Criteria) && reflect.DeepEqual(live.Action, filter.Action), nil
+	}
+
+	tests := []struct {
+		label          string
+		live           []gmail.Filter
+		filters        []gmail.Filter
+		state          []state.Entry
+		pruneUnmanaged bool
+		want           driftEvent
+	}{
+		{
+			label:   "no drift",
+			live:    []gmail.Filter{withID(fooFilter, "id1")},
+			filters: []gmail.Filter{fooFilter},
+			state:   []state.Entry{{Filter: withID(fooFilter, "id1")}},
+			want: driftEvent{
+				Missing:   []gmail.Filter{},
+				Extra:     []gmail.Filter{},
+				Unmanaged: []gmail.Filter{},
+			},
+		},
+		{
+			label:   "filter removed in Gmail",
+			filters: []gmail.Filter{fooFilter},
+			state:   []state.Entry{{Filter: withID(fooFilter, "id1")}},
+			want: driftEvent{
+				Drifted:   true,
+				Missing:   []gmail.Filter{fooFilter},
+				Extra:     []gmail.Filter{},
+				Unmanaged: []gmail.Filter{},
+			},
+		},
+		{
+			label:   "filter removed in resource file",
+			live:    []gmail.Filter{withID(fooFilter, "id1")},
+			filters: nil,
+			state:   []state.Entry{{Filter: withID(fooFilter, "id1")}},
+			want: driftEvent{
+				Drifted:   true,
+				Missing:   []gmail.Filter{},
+				Extra:     []gmail.Filter{withID(fooFilter, "id1")},
+				Unmanaged: []gmail.Filter{},
+			},
+		},
+		{
+			label:   "filter added in Gmail",
+			live:    []gmail.Filter{withID(fooFilter, "id1"), withID(barFilter, "id2")},
+			filters: []gmail.Filter{fooFilter},
+			state:   []state.Entry{{Filter: withID(fooFilter, "id1")}},
+			want: driftEvent{
+				Drifted:   true,
+				Missing:   []gmail.Filter{},
+				Extra:     []gmail.Filter{},
+				Unmanaged: []gmail.Filter{withID(barFilter, "id2")},
+			},
+		},
+		{
+			label:          "filter added in Gmail with prune",
+			live:           []gmail.Filter{withID(fooFilter, "id1"), withID(barFilter, "id2")},
+			filters:        []gmail.Filter{fooFilter},
+			state:          []state.Entry{{Filter: withID(fooFilter, "id1")}},
+			pruneUnmanaged: true,
+			want: driftEvent{
+				Drifted:   true,
+				Missing:   []gmail.Filter{},
+				Extra:     []gmail.Filter{withID(barFilter, "id2")},
+				Unmanaged: []gmail.Filter{},
+			},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			got, err := detectDrift(tt.live, tt.filters, &state.State{Version: state.Version, Filters: tt.state}, same, tt.pruneUnmanaged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected drift:\n  got:  %+v\n  want: %+v", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestWatcherTick(t *testing.T) {
+	missing := driftEvent{
+		Drifted: true,
+		Missing: []gmail.Filter{{Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}}}},
+	}
+	extra := driftEvent{
+		Drifted: true,
+		Extra:   []gmail.Filter{{ID: "id1"}},
+	}
+	corrected := missing
+	corrected.Corrected = true
+	errCheck := errors.New("network error")
+
+	tests := []struct {
+		label  string
+		checks []driftEvent
+		errs   []error
+		// want is the indices of checks which call the hooks
+		want []int
+	}{
+		{
+			label:  "no drift",
+			checks: []driftEvent{{}, {}},
+			want:   nil,
+		},
+		{
+			label:  "drift on first check",
+			checks: []driftEvent{missing, missing},
+			want:   []int{0},
+		},
+		{
+			label:  "drift changed and resolved",
+			checks: []driftEvent{{}, missing, extra, extra, {}},
+			want:   []int{1, 2, 4},
+		},
+		{
+			label:  "errors are skipped",
+			checks: []driftEvent{missing, {}, missing},
+			errs:   []error{nil, errCheck, nil},
+			want:   []int{0},
+		},
+		{
+			label:  "corrected drift",
+			checks: []driftEvent{corrected, {}, corrected},
+			want:   []int{0, 2},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			n := 0
+			var got []int
+			w := &watcher{
+				check: func(context.Context) (driftEvent, error) {
+					if tt.errs != nil && tt.errs[n] != nil {
+						return driftEvent{}, tt.errs[n]
+					}
+					return tt.checks[n], nil
+				},
+				hooks: []driftHook{
+					func(context.Context, driftEvent) error {
+						got = append(got, n)
+						return nil
+					},
+				},
+			}
+			for n = range tt.checks {
+				w.tick(context.Background())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hooks are called on unexpected checks: %v != %v", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestWatcherRunStop(t *testing.T) {
+	stop := make(chan struct{})
+	checks := 0
+	w := &watcher{
+		interval: time.Hour,
+		check: func(context.Context) (driftEvent, error) {
+			checks++
+			// stopping while checking does not abort the check
+			close(stop)
+			return driftEvent{}, nil
+		},
+	}
+	if err := w.run(context.Background(), stop); err != nil {
+		t.Fatal(err)
+	}
+	if checks != 1 {
+		t.Errorf("unexpected number of checks: %d", checks)
+		return
+	}
+}
+
+func TestEventFileHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gmac-watch-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.jsonl")
+
+	hook := eventFileHook(path)
+	events := []driftEvent{
+		{Source: "filters.yml", Drifted: true, Extra: []gmail.Filter{{ID: "id1"}}},
+		{Source: "filters.yml"},
+	}
+	for _, e := range events {
+		if err := hook(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) != len(events) {
+		t.Fatalf("unexpected number of events: %d", len(lines))
+	}
+	for i, line := range lines {
+		var got driftEvent
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, events[i]) {
+			t.Errorf("unexpected event:\n  got:  %+v\n  want: %+v", got, events[i])
+			return
+		}
+	}
+}
+
+func TestExecHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available")
+	}
+	dir, err := ioutil.TempDir("", "gmac-watch-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "drift event.json")
+
+	e := driftEvent{Source: "filters.yml", Drifted: true, Unmanaged: []gmail.Filter{{ID: "id1"}}}
+	// the command is run via the shell with quotes and redirection
+	if err := execHook(`cat > "`+path+`"`)(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got driftEvent
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("unexpected event:\n  got:  %+v\n  want: %+v", got, e)
+		return
+	}
+}
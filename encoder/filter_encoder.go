@@ -2,81 +2,232 @@ package encoder
 
 import (
 	"bytes"
-	"fmt"
 	"io"
-	"regexp"
-	"text/tabwriter"
+	"sort"
+	"strings"
+	"text/template"
 
 	"github.com/goccy/go-yaml"
 	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/sieve"
 )
 
-var nonParen = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
-
 // FilterEncoder is an interface which encodes Filter object into string.
 type FilterEncoder interface {
 	Encode([]gmail.Filter) error
 }
 
-func NewFilterEncoder(w io.Writer, format string) FilterEncoder {
-	switch format {
-	case "yaml":
+func init() {
+	RegisterFilterEncoder("table", func(w io.Writer, _ string) (FilterEncoder, error) {
+		return NewTableFilterEncoder(w, TableOptions{}), nil
+	})
+	RegisterFilterEncoder("wide", func(w io.Writer, _ string) (FilterEncoder, error) {
+		return NewTableFilterEncoder(w, TableOptions{Wide: true}), nil
+	})
+	RegisterFilterEncoder("yaml", func(w io.Writer, _ string) (FilterEncoder, error) {
 		return &yamlFilterEncoder{
 			enc: yaml.NewEncoder(w),
+		}, nil
+	})
+	RegisterFilterEncoder("json", func(w io.Writer, _ string) (FilterEncoder, error) {
+		return &jsonFilterEncoder{
+			w: w,
+		}, nil
+	})
+	RegisterFilterEncoder("jsonpath", func(w io.Writer, arg string) (FilterEncoder, error) {
+		tmpl, err := parseJSONPath(arg)
+		if err != nil {
+			return nil, err
 		}
-	case "wide":
-		return &defaultFilterEncoder{
-			w:      w,
-			isWide: true,
+		return &jsonPathFilterEncoder{
+			w:    w,
+			tmpl: tmpl,
+		}, nil
+	})
+	RegisterFilterEncoder("go-template", func(w io.Writer, arg string) (FilterEncoder, error) {
+		tmpl, err := template.New("go-template").Parse(arg)
+		if err != nil {
+			return nil, err
 		}
-	default:
-		return &defaultFilterEncoder{
+		return &templateFilterEncoder{
+			w:    w,
+			tmpl: tmpl,
+		}, nil
+	})
+	RegisterFilterEncoder("template", newTemplateFileFilterEncoder)
+	RegisterFilterEncoder("xml", func(w io.Writer, _ string) (FilterEncoder, error) {
+		return &xmlFilterEncoder{
 			w: w,
-		}
+		}, nil
+	})
+	RegisterFilterEncoder("sieve", func(w io.Writer, _ string) (FilterEncoder, error) {
+		return &sieveFilterEncoder{
+			w: w,
+		}, nil
+	})
+}
+
+// defaultColumnWidth is the maximum display width of the criteria and
+// action columns in the table, if the maximum width of the table is
+// not specified.
+const defaultColumnWidth = 40
+
+// minColumnWidth is the minimum display width of truncated columns.
+const minColumnWidth = 10
+
+// TableOptions is the options of the table output format.
+type TableOptions struct {
+	// Wide adds the ID column. The criteria and action are not truncated
+	// in the wide table unless MaxWidth is specified.
+	Wide bool
+	// MaxWidth is the maximum display width of the table lines, e.g. the
+	// width of the terminal. The criteria and action are truncated to fit
+	// within it. If MaxWidth is zero, each of them is truncated to
+	// 40 columns.
+	MaxWidth int
+	// NoTruncate disables the truncation.
+	NoTruncate bool
+	// IsManaged reports whether the filter of given ID is managed by
+	// gmac. If it is not nil, the table has the STATE column, which is
+	// "managed" or "unmanaged".
+	IsManaged func(id string) bool
+}
+
+// NewTableFilterEncoder returns FilterEncoder of the table format with
+// given options.
+func NewTableFilterEncoder(w io.Writer, opts TableOptions) FilterEncoder {
+	return &defaultFilterEncoder{
+		w:          w,
+		isWide:     opts.Wide,
+		maxWidth:   opts.MaxWidth,
+		noTruncate: opts.NoTruncate,
+		isManaged:  opts.IsManaged,
 	}
 }
 
 // defaultFilterEncoder encodes Filter object into string by
 // FilterCriteria.String() and FilterAction.String() methods.
-// the criteria and action are abbreviated if isWide is false.
+// the criteria and action are truncated at grapheme boundaries to fit
+// within maxWidth, or 40 columns each if maxWidth is zero and isWide is
+// false.
 type defaultFilterEncoder struct {
 	w io.Writer
 
-	isWide bool
+	isWide     bool
+	maxWidth   int
+	noTruncate bool
+	isManaged  func(id string) bool
 }
 
 func (e *defaultFilterEncoder) Encode(filters []gmail.Filter) error {
-	var buf bytes.Buffer
-
-	w := tabwriter.NewWriter(&buf, 0, 2, 1, ' ', 0)
-
-	fmt.Fprint(w, "MATCHES\tACTION\n")
-
+	var header []string
+	if e.isWide {
+		header = append(header, "ID")
+	}
+	if e.isManaged != nil {
+		header = append(header, "STATE")
+	}
+	header = append(header, "MATCHES", "ACTION")
+	rows := [][]string{header}
 	for _, filter := range filters {
-		fmt.Fprintf(w, "%s\t%s\n", e.encodeCriteria(filter.Criteria), e.encodeAction(filter.Action))
-
+		var row []string
+		if e.isWide {
+			row = append(row, orNone(filter.ID))
+		}
+		if e.isManaged != nil {
+			row = append(row, managedState(e.isManaged(filter.ID)))
+		}
+		row = append(row, filter.Criteria.String(), filter.Action.String())
+		rows = append(rows, row)
 	}
 
-	if err := w.Flush(); err != nil {
-		return err
+	// the criteria and action are the last two columns
+	truncated := len(header) - 2
+	switch {
+	case e.noTruncate:
+	case e.maxWidth > 0:
+		fixed := 0
+		for _, w := range columnWidths(rows)[:truncated] {
+			fixed += w + 1
+		}
+		widths := fitWidths(columnWidths(rows)[truncated:], e.maxWidth-fixed-1)
+		truncateColumns(rows[1:], truncated, widths)
+	case !e.isWide:
+		truncateColumns(rows[1:], truncated, []int{defaultColumnWidth, defaultColumnWidth})
 	}
 
+	var buf bytes.Buffer
+	writeTable(&buf, rows)
 	_, err := buf.WriteTo(e.w)
 	return err
 }
 
-func (e *defaultFilterEncoder) encodeCriteria(criteria gmail.FilterCriteria) string {
-	if e.isWide {
-		return criteria.String()
+// columnWidths returns the maximum display width of each column.
+func columnWidths(rows [][]string) []int {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if w := stringWidth(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
 	}
-	return abbr(criteria.String(), 40)
+	return widths
 }
 
-func (e *defaultFilterEncoder) encodeAction(action gmail.FilterAction) string {
-	if e.isWide {
-		return action.String()
+// fitWidths shrinks the widths of columns so that the total is at most
+// given width. Narrow columns are kept as they are, and the rest of the
+// width is shared equally by the wider columns. Each column is at least
+// minColumnWidth even if the total exceeds the width.
+func fitWidths(widths []int, total int) []int {
+	fitted := make([]int, len(widths))
+	copy(fitted, widths)
+	order := make([]int, len(widths))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return widths[order[i]] < widths[order[j]] })
+
+	rest := total
+	for n, i := range order {
+		share := rest / (len(order) - n)
+		if share < minColumnWidth {
+			share = minColumnWidth
+		}
+		if fitted[i] > share {
+			fitted[i] = share
+		}
+		rest -= fitted[i]
+	}
+	return fitted
+}
+
+// truncateColumns truncates the cells from given column so that they fit
+// within the widths.
+func truncateColumns(rows [][]string, from int, widths []int) {
+	for _, row := range rows {
+		for i, w := range widths {
+			row[from+i] = truncate(row[from+i], w)
+		}
+	}
+}
+
+// writeTable writes rows as a table whose columns are aligned by the
+// display width of cells, separated by a space.
+func writeTable(buf *bytes.Buffer, rows [][]string) {
+	widths := columnWidths(rows)
+	for _, row := range rows {
+		for i, cell := range row {
+			buf.WriteString(cell)
+			if i < len(row)-1 {
+				buf.WriteString(strings.Repeat(" ", widths[i]-stringWidth(cell)+1))
+			}
+		}
+		buf.WriteString("\n")
 	}
-	return abbr(action.String(), 40)
 }
 
 // yamlFilterEncoder encodes Filter object by marshaling to YAML format.
@@ -94,9 +245,35 @@ func (e *yamlFilterEncoder) Encode(filters []gmail.Filter) error {
 	})
 }
 
-func abbr(s string, maxLength int) string {
-	if len(s) < maxLength {
-		return s
+// xmlFilterEncoder encodes Filter object into mailFilters.xml format,
+// which can be imported from Gmail settings.
+type xmlFilterEncoder struct {
+	w io.Writer
+}
+
+func (e *xmlFilterEncoder) Encode(filters []gmail.Filter) error {
+	return gmail.WriteFiltersXML(e.w, filters)
+}
+
+// sieveFilterEncoder encodes Filter object into Sieve script.
+type sieveFilterEncoder struct {
+	w io.Writer
+}
+
+func (e *sieveFilterEncoder) Encode(filters []gmail.Filter) error {
+	return sieve.Write(e.w, filters)
+}
+
+func managedState(managed bool) string {
+	if managed {
+		return "managed"
+	}
+	return "unmanaged"
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
 	}
-	return s[:maxLength-4] + "..."
+	return s
 }
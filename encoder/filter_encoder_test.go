@@ -0,0 +1,172 @@
+package encoder
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+func TestDefaultFilterEncoder(t *testing.T) {
+	tests := []struct {
+		label  string
+		format string
+		want   string
+	}{
+		{
+			label:  "table",
+			format: "",
+			want: `MATCHES              ACTION
+from:foo@example.com Skip Inbox, Apply label "foo"
+subject:{bar baz}    Star it
+`,
+		},
+		{
+			label:  "wide",
+			format: "wide",
+			want: `ID  MATCHES              ACTION
+id1 from:foo@example.com Skip Inbox, Apply label "foo"
+id2 subject:{bar baz}    Star it
+`,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc, err := NewFilterEncoder(&buf, tt.format)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if err := enc.Encode(testFilters); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("unexpected output:\n%s\nwant:\n%s", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestTableFilterEncoder(t *testing.T) {
+	filters := []gmail.Filter{
+		{
+			ID:       "id1",
+			Criteria: gmail.FilterCriteria{Subject: gmail.Terms{"週次レポートの自動送信について"}},
+			Action:   gmail.FilterAction{AddLabel: "仕事/レポート"},
+		},
+		{
+			ID:       "id2",
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"notifications@github.example.com"}},
+			Action:   gmail.FilterAction{AddLabel: "Work/CI", Archive: true, MarkAsRead: true},
+		},
+	}
+	tests := []struct {
+		label string
+		opts  TableOptions
+		want  string
+	}{
+		{
+			label: "default",
+			opts:  TableOptions{},
+			want: `MATCHES                                ACTION
+subject:週次レポートの自動送信について Apply label "仕事/レポート"
+from:notifications@github.example.com  Skip Inbox, Mark as read, Apply label...
+`,
+		},
+		{
+			label: "max width",
+			opts:  TableOptions{MaxWidth: 50},
+			want: `MATCHES                  ACTION
+subject:週次レポート...  Apply label "仕事/レポ...
+from:notifications@gi... Skip Inbox, Mark as re...
+`,
+		},
+		{
+			label: "no truncate",
+			opts:  TableOptions{MaxWidth: 50, NoTruncate: true},
+			want: `MATCHES                                ACTION
+subject:週次レポートの自動送信について Apply label "仕事/レポート"
+from:notifications@github.example.com  Skip Inbox, Mark as read, Apply label "Work/CI"
+`,
+		},
+		{
+			label: "managed state",
+			opts:  TableOptions{Wide: true, NoTruncate: true, IsManaged: func(id string) bool { return id == "id2" }},
+			want: `ID  STATE     MATCHES                                ACTION
+id1 unmanaged subject:週次レポートの自動送信について Apply label "仕事/レポート"
+id2 managed   from:notifications@github.example.com  Skip Inbox, Mark as read, Apply label "Work/CI"
+`,
+		},
+		{
+			label: "wide with max width",
+			opts:  TableOptions{Wide: true, MaxWidth: 50},
+			want: `ID  MATCHES                ACTION
+id1 subject:週次レポー...  Apply label "仕事/レ...
+id2 from:notifications@... Skip Inbox, Mark as ...
+`,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := NewTableFilterEncoder(&buf, tt.opts).Encode(filters); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("unexpected output:\n%s\nwant:\n%s", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestYAMLFilterEncoder(t *testing.T) {
+	want := `kind: Filter
+filters:
+- id: id1
+  criteria:
+    from: foo@example.com
+  action:
+    archive: true
+    add_label: foo
+- id: id2
+  criteria:
+    subject:
+    - bar
+    - baz
+  action:
+    star: true
+`
+	var buf bytes.Buffer
+	enc, err := NewFilterEncoder(&buf, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(testFilters); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, want)
+		return
+	}
+}
+
+func TestRawFilterEncoderError(t *testing.T) {
+	if _, err := NewRawFilterEncoder(&bytes.Buffer{}, "wide"); err == nil || err.Error() != "unsupported output format for raw filters: wide" {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	enc, err := NewRawFilterEncoder(&bytes.Buffer{}, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode([]gmail.Filter{{}}); err == nil || err.Error() != "filter #1 is not got from Gmail" {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+}
@@ -0,0 +1,121 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+// jsonDocument is the document encoded by jsonFilterEncoder, which is
+// also the data given to jsonpath and go-template. The keys are the
+// same as the resource file, so expressions can be written in the
+// same way for both.
+type jsonDocument struct {
+	Kind    string       `json:"kind"`
+	Filters []jsonFilter `json:"filters"`
+}
+
+type jsonFilter struct {
+	gmail.Filter
+	LabelIDs *jsonLabelIDs `json:"label_ids,omitempty"`
+}
+
+// jsonLabelIDs is the raw label IDs of the filter in Gmail.
+type jsonLabelIDs struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+func newJSONDocument(filters []gmail.Filter) jsonDocument {
+	doc := jsonDocument{
+		Kind:    gmail.ResourceTypeFilter,
+		Filters: make([]jsonFilter, 0, len(filters)),
+	}
+	for _, f := range filters {
+		jf := jsonFilter{Filter: f}
+		if add, remove := f.LabelIDs(); add != nil || remove != nil {
+			jf.LabelIDs = &jsonLabelIDs{
+				Add:    nonNil(add),
+				Remove: nonNil(remove),
+			}
+		}
+		doc.Filters = append(doc.Filters, jf)
+	}
+	return doc
+}
+
+func nonNil(ss []string) []string {
+	if ss == nil {
+		return []string{}
+	}
+	return ss
+}
+
+// genericJSON returns the JSON document as generic values, e.g.
+// map[string]interface{}, which jsonpath and go-template are evaluated
+// against.
+func genericJSON(filters []gmail.Filter) (interface{}, error) {
+	b, err := json.Marshal(newJSONDocument(filters))
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// jsonFilterEncoder encodes Filter object into JSON format, including
+// filter IDs and raw label IDs.
+type jsonFilterEncoder struct {
+	w io.Writer
+}
+
+func (e *jsonFilterEncoder) Encode(filters []gmail.Filter) error {
+	enc := json.NewEncoder(e.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newJSONDocument(filters))
+}
+
+// jsonPathFilterEncoder encodes Filter object with JSONPath template.
+type jsonPathFilterEncoder struct {
+	w    io.Writer
+	tmpl *jsonPath
+}
+
+func (e *jsonPathFilterEncoder) Encode(filters []gmail.Filter) error {
+	v, err := genericJSON(filters)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := e.tmpl.execute(&buf, v); err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(e.w)
+	return err
+}
+
+// templateFilterEncoder encodes Filter object with Go template.
+type templateFilterEncoder struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func (e *templateFilterEncoder) Encode(filters []gmail.Filter) error {
+	v, err := genericJSON(filters)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := e.tmpl.Execute(&buf, v); err != nil {
+		return fmt.Errorf("go-template: %w", err)
+	}
+	_, err = buf.WriteTo(e.w)
+	return err
+}
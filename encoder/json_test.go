@@ -0,0 +1,176 @@
+package encoder
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+var testFilters = []gmail.Filter{
+	{
+		ID: "id1",
+		Criteria: gmail.FilterCriteria{
+			From: gmail.Terms{"foo@example.com"},
+		},
+		Action: gmail.FilterAction{
+			AddLabel: "foo",
+			Archive:  true,
+		},
+	},
+	{
+		ID: "id2",
+		Criteria: gmail.FilterCriteria{
+			Subject: gmail.Terms{"bar", "baz"},
+		},
+		Action: gmail.FilterAction{
+			Star: true,
+		},
+	},
+}
+
+func TestJSONFilterEncoder(t *testing.T) {
+	want := `{
+  "kind": "Filter",
+  "filters": [
+    {
+      "id": "id1",
+      "criteria": {
+        "from": [
+          "foo@example.com"
+        ]
+      },
+      "action": {
+        "archive": true,
+        "add_label": "foo"
+      }
+    },
+    {
+      "id": "id2",
+      "criteria": {
+        "subject": [
+          "bar",
+          "baz"
+        ]
+      },
+      "action": {
+        "star": true
+      }
+    }
+  ]
+}
+`
+	var buf bytes.Buffer
+	enc, err := NewFilterEncoder(&buf, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(testFilters); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, want)
+		return
+	}
+}
+
+func TestTemplateFilterEncoder(t *testing.T) {
+	tests := []struct {
+		label  string
+		format string
+		want   string
+	}{
+		{
+			label:  "jsonpath field",
+			format: "jsonpath={.filters[0].id}",
+			want:   "id1",
+		},
+		{
+			label:  "jsonpath wildcard",
+			format: "jsonpath={.filters[*].id}",
+			want:   "id1 id2",
+		},
+		{
+			label:  "jsonpath range",
+			format: `jsonpath={range .filters[*]}{.id}{"\t"}{.action.add_label}{"\n"}{end}`,
+			want:   "id1\tfoo\nid2\t\n",
+		},
+		{
+			label:  "jsonpath filter",
+			format: `jsonpath={.filters[?(@.action.star == true)].id}`,
+			want:   "id2",
+		},
+		{
+			label:  "jsonpath filter by existence",
+			format: `jsonpath={$.filters[?(@.action.add_label)].criteria.from[-1]}`,
+			want:   "foo@example.com",
+		},
+		{
+			label:  "jsonpath object",
+			format: `jsonpath=id: {.filters[1]['id']}, subject: {.filters[1].criteria.subject}`,
+			want:   `id: id2, subject: ["bar","baz"]`,
+		},
+		{
+			label:  "go-template",
+			format: `go-template={{range .filters}}{{.id}}:{{.action.add_label}}{{"\n"}}{{end}}`,
+			want:   "id1:foo\nid2:<no value>\n",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc, err := NewFilterEncoder(&buf, tt.format)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if err := enc.Encode(testFilters); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("%q != %q", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestNewFilterEncoderError(t *testing.T) {
+	tests := []struct {
+		label  string
+		format string
+		want   string
+	}{
+		{
+			label:  "unknown format",
+			format: "csv",
+			want:   "unknown output format: csv",
+		},
+		{
+			label:  "unclosed action",
+			format: "jsonpath={.filters",
+			want:   "jsonpath: unclosed action at offset 0",
+		},
+		{
+			label:  "range without end",
+			format: "jsonpath={range .filters[*]}{.id}",
+			want:   "jsonpath: {end} is not found for {range}",
+		},
+		{
+			label:  "invalid index",
+			format: "jsonpath={.filters[x]}",
+			want:   `jsonpath: invalid index [x] in ".filters[x]"`,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			_, err := NewFilterEncoder(&bytes.Buffer{}, tt.format)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("%v != %s", err, tt.want)
+				return
+			}
+		})
+	}
+}
@@ -0,0 +1,444 @@
+package encoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a template of JSONPath in the syntax of kubectl, e.g.
+// `{range .filters[*]}{.id}{"\t"}{.action.add_label}{"\n"}{end}`.
+// Supported expressions are:
+//
+//   - fields: `.name` and `['name']`
+//   - array indices: `[0]` and `[-1]`
+//   - wildcards: `[*]` and `.*`
+//   - filters: `[?(@.name)]`, `[?(@.name == "value")]` and `[?(@.name != "value")]`
+//   - the root and the current objects: `$` and `@`
+//   - string literals: `{"\n"}`
+//   - range: `{range .filters[*]}...{end}`
+//
+// Fields which do not exist are evaluated as empty.
+type jsonPath struct {
+	nodes []jsonPathNode
+}
+
+type jsonPathNode interface{}
+
+type textNode string
+
+type rangeNode struct {
+	path  pathExpr
+	nodes []jsonPathNode
+}
+
+// pathExpr is a path to values, e.g. `.filters[*].id`.
+type pathExpr struct {
+	// fromRoot is true if the path starts with `$`, otherwise the path
+	// is evaluated from the current object.
+	fromRoot bool
+	steps    []pathStep
+}
+
+type pathStep struct {
+	kind  stepKind
+	name  string
+	index int
+	// cond is the condition of filter step.
+	cond *condition
+}
+
+type stepKind int
+
+const (
+	stepField stepKind = iota
+	stepIndex
+	stepWildcard
+	stepFilter
+)
+
+type condition struct {
+	path pathExpr
+	// op is "" if the condition tests only the existence of the path.
+	op    string
+	value interface{}
+}
+
+func parseJSONPath(s string) (*jsonPath, error) {
+	p := jsonPathParser{src: s}
+	nodes, err := p.parseNodes(false)
+	if err != nil {
+		return nil, fmt.Errorf("jsonpath: %w", err)
+	}
+	return &jsonPath{nodes: nodes}, nil
+}
+
+type jsonPathParser struct {
+	src string
+	pos int
+}
+
+// parseNodes parses nodes until the end of template, or `{end}` if
+// inRange is true.
+func (p *jsonPathParser) parseNodes(inRange bool) ([]jsonPathNode, error) {
+	var nodes []jsonPathNode
+	for p.pos < len(p.src) {
+		i := strings.IndexByte(p.src[p.pos:], '{')
+		if i < 0 {
+			nodes = append(nodes, textNode(p.src[p.pos:]))
+			p.pos = len(p.src)
+			break
+		}
+		if i > 0 {
+			nodes = append(nodes, textNode(p.src[p.pos:p.pos+i]))
+		}
+		start := p.pos + i
+		action, err := p.action(start)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case action == "end":
+			if !inRange {
+				return nil, fmt.Errorf("unexpected {end} at offset %d", start)
+			}
+			return nodes, nil
+		case strings.HasPrefix(action, "range "):
+			path, err := parsePathExpr(strings.TrimSpace(strings.TrimPrefix(action, "range ")))
+			if err != nil {
+				return nil, err
+			}
+			body, err := p.parseNodes(true)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, rangeNode{path: path, nodes: body})
+		case strings.HasPrefix(action, `"`):
+			s, err := strconv.Unquote(action)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s at offset %d", action, start)
+			}
+			nodes = append(nodes, textNode(s))
+		default:
+			path, err := parsePathExpr(action)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, path)
+		}
+	}
+	if inRange {
+		return nil, fmt.Errorf("{end} is not found for {range}")
+	}
+	return nodes, nil
+}
+
+// action returns the content of the braces which starts at given offset,
+// and moves the position to the next of the closing brace.
+func (p *jsonPathParser) action(start int) (string, error) {
+	var quote byte
+	for i := start + 1; i < len(p.src); i++ {
+		c := p.src[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}':
+			p.pos = i + 1
+			return strings.TrimSpace(p.src[start+1 : i]), nil
+		}
+	}
+	return "", fmt.Errorf("unclosed action at offset %d", start)
+}
+
+func parsePathExpr(s string) (pathExpr, error) {
+	var path pathExpr
+	rest := s
+	switch {
+	case strings.HasPrefix(rest, "$"):
+		path.fromRoot = true
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "@"):
+		rest = rest[1:]
+	}
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			if strings.HasPrefix(rest, "*") {
+				path.steps = append(path.steps, pathStep{kind: stepWildcard})
+				rest = rest[1:]
+				continue
+			}
+			i := strings.IndexAny(rest, ".[")
+			if i < 0 {
+				i = len(rest)
+			}
+			if i > 0 {
+				path.steps = append(path.steps, pathStep{kind: stepField, name: rest[:i]})
+			}
+			rest = rest[i:]
+		case '[':
+			end := closingBracket(rest)
+			if end < 0 {
+				return path, fmt.Errorf("unclosed bracket in %q", s)
+			}
+			step, err := parseBracket(rest[1:end])
+			if err != nil {
+				return path, fmt.Errorf("%v in %q", err, s)
+			}
+			path.steps = append(path.steps, step)
+			rest = rest[end+1:]
+		default:
+			return path, fmt.Errorf("invalid path %q", s)
+		}
+	}
+	return path, nil
+}
+
+// closingBracket returns the offset of the bracket which closes the
+// bracket at the beginning of s, or -1 if it is not closed.
+func closingBracket(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseBracket(s string) (pathStep, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "*":
+		return pathStep{kind: stepWildcard}, nil
+	case strings.HasPrefix(s, "'") || strings.HasPrefix(s, `"`):
+		name, err := unquote(s)
+		if err != nil {
+			return pathStep{}, err
+		}
+		return pathStep{kind: stepField, name: name}, nil
+	case strings.HasPrefix(s, "?(") && strings.HasSuffix(s, ")"):
+		cond, err := parseCondition(strings.TrimSpace(s[2 : len(s)-1]))
+		if err != nil {
+			return pathStep{}, err
+		}
+		return pathStep{kind: stepFilter, cond: cond}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return pathStep{}, fmt.Errorf("invalid index [%s]", s)
+	}
+	return pathStep{kind: stepIndex, index: n}, nil
+}
+
+func parseCondition(s string) (*condition, error) {
+	for _, op := range []string{"==", "!="} {
+		i := strings.Index(s, op)
+		if i < 0 {
+			continue
+		}
+		path, err := parsePathExpr(strings.TrimSpace(s[:i]))
+		if err != nil {
+			return nil, err
+		}
+		value, err := parseLiteral(strings.TrimSpace(s[i+len(op):]))
+		if err != nil {
+			return nil, err
+		}
+		return &condition{path: path, op: op, value: value}, nil
+	}
+	path, err := parsePathExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	return &condition{path: path}, nil
+}
+
+func parseLiteral(s string) (interface{}, error) {
+	if strings.HasPrefix(s, "'") || strings.HasPrefix(s, `"`) {
+		return unquote(s)
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, fmt.Errorf("invalid value %s", s)
+	}
+	return v, nil
+}
+
+// unquote unquotes a string literal quoted with single or double quotes.
+func unquote(s string) (string, error) {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		s = `"` + strings.NewReplacer(`"`, `\"`, `\'`, `'`).Replace(s[1:len(s)-1]) + `"`
+	}
+	v, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	return v, nil
+}
+
+func (t *jsonPath) execute(w io.Writer, data interface{}) error {
+	return executeNodes(w, t.nodes, data, data)
+}
+
+func executeNodes(w io.Writer, nodes []jsonPathNode, root, cur interface{}) error {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case textNode:
+			if _, err := io.WriteString(w, string(n)); err != nil {
+				return err
+			}
+		case pathExpr:
+			values := n.eval(root, cur)
+			s := make([]string, 0, len(values))
+			for _, v := range values {
+				s = append(s, formatValue(v))
+			}
+			if _, err := io.WriteString(w, strings.Join(s, " ")); err != nil {
+				return err
+			}
+		case rangeNode:
+			values := n.path.eval(root, cur)
+			if len(values) == 1 {
+				if a, ok := values[0].([]interface{}); ok {
+					values = a
+				}
+			}
+			for _, v := range values {
+				if err := executeNodes(w, n.nodes, root, v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (path pathExpr) eval(root, cur interface{}) []interface{} {
+	values := []interface{}{cur}
+	if path.fromRoot {
+		values = []interface{}{root}
+	}
+	for _, step := range path.steps {
+		var next []interface{}
+		for _, v := range values {
+			next = append(next, step.eval(root, v)...)
+		}
+		values = next
+	}
+	return values
+}
+
+func (step pathStep) eval(root, v interface{}) []interface{} {
+	switch step.kind {
+	case stepField:
+		if m, ok := v.(map[string]interface{}); ok {
+			if child, ok := m[step.name]; ok {
+				return []interface{}{child}
+			}
+		}
+	case stepIndex:
+		if a, ok := v.([]interface{}); ok {
+			i := step.index
+			if i < 0 {
+				i += len(a)
+			}
+			if 0 <= i && i < len(a) {
+				return []interface{}{a[i]}
+			}
+		}
+	case stepWildcard:
+		switch v := v.(type) {
+		case []interface{}:
+			return v
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			values := make([]interface{}, 0, len(v))
+			for _, key := range keys {
+				values = append(values, v[key])
+			}
+			return values
+		}
+	case stepFilter:
+		if a, ok := v.([]interface{}); ok {
+			var values []interface{}
+			for _, elem := range a {
+				if step.cond.matches(root, elem) {
+					values = append(values, elem)
+				}
+			}
+			return values
+		}
+	}
+	return nil
+}
+
+func (c *condition) matches(root, v interface{}) bool {
+	values := c.path.eval(root, v)
+	if c.op == "" {
+		return len(values) > 0
+	}
+	found := false
+	for _, value := range values {
+		if equalValue(value, c.value) {
+			found = true
+			break
+		}
+	}
+	if c.op == "!=" {
+		return !found
+	}
+	return found
+}
+
+func equalValue(a, b interface{}) bool {
+	return formatValue(a) == formatValue(b)
+}
+
+// formatValue formats a value in the output of JSONPath. Strings are
+// written as-is, and objects and arrays are written in JSON.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return "null"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,52 @@
+package encoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/goccy/go-yaml"
+	gmailapi "google.golang.org/api/gmail/v1"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+// NewRawFilterEncoder returns FilterEncoder which encodes the filters as
+// they are got from Gmail, before translation. It is useful to debug
+// problems of translation. format is one of "" (yaml), "yaml" and "json".
+func NewRawFilterEncoder(w io.Writer, format string) (FilterEncoder, error) {
+	switch format {
+	case "", "yaml", "json":
+		return &rawFilterEncoder{
+			w:      w,
+			isJSON: format == "json",
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported output format for raw filters: %s", format)
+}
+
+// rawFilterEncoder encodes the filters got from Gmail API into YAML or
+// JSON, with the field names of Gmail API.
+type rawFilterEncoder struct {
+	w      io.Writer
+	isJSON bool
+}
+
+func (e *rawFilterEncoder) Encode(filters []gmail.Filter) error {
+	raws := make([]*gmailapi.Filter, 0, len(filters))
+	for i, f := range filters {
+		raw := f.Raw()
+		if raw == nil {
+			return fmt.Errorf("filter #%d is not got from Gmail", i+1)
+		}
+		raws = append(raws, raw)
+	}
+	if e.isJSON {
+		enc := json.NewEncoder(e.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(raws)
+	}
+	// the structs of Gmail API have only json tags, which are used
+	// by the YAML encoder too
+	return yaml.NewEncoder(e.w).Encode(raws)
+}
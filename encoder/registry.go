@@ -0,0 +1,74 @@
+package encoder
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FilterEncoderFunc returns FilterEncoder which writes to w. arg is the
+// argument of the output format given after "=", e.g. "<template>" of
+// "go-template=<template>", which is empty if not given.
+type FilterEncoderFunc func(w io.Writer, arg string) (FilterEncoder, error)
+
+var (
+	filterEncodersMu sync.RWMutex
+	filterEncoders   = map[string]FilterEncoderFunc{}
+)
+
+// RegisterFilterEncoder makes FilterEncoder available by given output
+// format name, so that NewFilterEncoder returns it for the name, or
+// "<name>=<arg>". Library users can add their own output formats with
+// it. RegisterFilterEncoder panics if the name is empty, contains "=",
+// or is already registered.
+func RegisterFilterEncoder(name string, newEncoder FilterEncoderFunc) {
+	filterEncodersMu.Lock()
+	defer filterEncodersMu.Unlock()
+	if name == "" || strings.Contains(name, "=") {
+		panic(fmt.Sprintf("encoder: invalid output format name %q", name))
+	}
+	if newEncoder == nil {
+		panic("encoder: RegisterFilterEncoder function is nil")
+	}
+	if _, dup := filterEncoders[name]; dup {
+		panic("encoder: RegisterFilterEncoder called twice for " + name)
+	}
+	filterEncoders[name] = newEncoder
+}
+
+// FilterEncoderNames returns the sorted list of registered output
+// format names.
+func FilterEncoderNames() []string {
+	filterEncodersMu.RLock()
+	defer filterEncodersMu.RUnlock()
+	names := make([]string, 0, len(filterEncoders))
+	for name := range filterEncoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewFilterEncoder returns FilterEncoder of given output format, which
+// is "<name>" or "<name>=<arg>" of the registered name. "" is the same
+// as "table". Built-in formats are "table", "wide", "yaml", "json",
+// "xml", "sieve", "jsonpath=<template>", "go-template=<template>" and
+// "template=<file>".
+func NewFilterEncoder(w io.Writer, format string) (FilterEncoder, error) {
+	name, arg := format, ""
+	if i := strings.IndexByte(format, '='); i >= 0 {
+		name, arg = format[:i], format[i+1:]
+	}
+	if name == "" {
+		name = "table"
+	}
+	filterEncodersMu.RLock()
+	newEncoder, ok := filterEncoders[name]
+	filterEncodersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown output format: %s", format)
+	}
+	return newEncoder(w, arg)
+}
@@ -0,0 +1,109 @@
+package encoder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+type countFilterEncoder struct {
+	w      io.Writer
+	prefix string
+}
+
+func (e *countFilterEncoder) Encode(filters []gmail.Filter) error {
+	_, err := fmt.Fprintf(e.w, "%s%d\n", e.prefix, len(filters))
+	return err
+}
+
+func TestRegisterFilterEncoder(t *testing.T) {
+	RegisterFilterEncoder("test-count", func(w io.Writer, arg string) (FilterEncoder, error) {
+		return &countFilterEncoder{w: w, prefix: arg}, nil
+	})
+	defer func() {
+		filterEncodersMu.Lock()
+		delete(filterEncoders, "test-count")
+		filterEncodersMu.Unlock()
+	}()
+
+	tests := []struct {
+		label  string
+		format string
+		want   string
+	}{
+		{
+			label:  "without arg",
+			format: "test-count",
+			want:   "2\n",
+		},
+		{
+			label:  "with arg",
+			format: "test-count=filters: ",
+			want:   "filters: 2\n",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc, err := NewFilterEncoder(&buf, tt.format)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if err := enc.Encode(testFilters); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("%q != %q", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestRegisterFilterEncoderPanic(t *testing.T) {
+	newEncoder := func(w io.Writer, _ string) (FilterEncoder, error) {
+		return &countFilterEncoder{w: w}, nil
+	}
+	tests := []struct {
+		label      string
+		name       string
+		newEncoder FilterEncoderFunc
+	}{
+		{
+			label:      "empty name",
+			name:       "",
+			newEncoder: newEncoder,
+		},
+		{
+			label:      "name with =",
+			name:       "a=b",
+			newEncoder: newEncoder,
+		},
+		{
+			label:      "nil function",
+			name:       "test-nil",
+			newEncoder: nil,
+		},
+		{
+			label:      "duplicate",
+			name:       "yaml",
+			newEncoder: newEncoder,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			RegisterFilterEncoder(tt.name, tt.newEncoder)
+		})
+	}
+}
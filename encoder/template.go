@@ -0,0 +1,105 @@
+package encoder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+// templateFuncs is the functions available in template files.
+var templateFuncs = template.FuncMap{
+	// join joins the elements of a list, or returns a string as it is,
+	// e.g. {{join ", " .criteria.from}}.
+	"join": func(sep string, v interface{}) string {
+		list, ok := v.([]interface{})
+		if !ok {
+			if v == nil {
+				return ""
+			}
+			return formatValue(v)
+		}
+		s := make([]string, 0, len(list))
+		for _, elem := range list {
+			s = append(s, formatValue(elem))
+		}
+		return strings.Join(s, sep)
+	},
+	// markdown escapes a string to be written in a cell of Markdown
+	// table.
+	"markdown": func(v interface{}) string {
+		if v == nil {
+			return ""
+		}
+		return markdownEscaper.Replace(formatValue(v))
+	},
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"|", `\|`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"<", "&lt;",
+	"\r\n", "<br>",
+	"\n", "<br>",
+)
+
+// newTemplateFileFilterEncoder returns FilterEncoder which encodes
+// Filter object with the Go template file of given path.
+func newTemplateFileFilterEncoder(w io.Writer, path string) (FilterEncoder, error) {
+	if path == "" {
+		return nil, fmt.Errorf("template file is not specified, use template=<file>")
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+	return &templateFileFilterEncoder{
+		w:    w,
+		tmpl: tmpl,
+	}, nil
+}
+
+// templateFileFilterEncoder encodes Filter object with Go template file.
+// The data is the same as go-template, and each filter has also "query",
+// the search query of the criteria, and "description", the description
+// of the action which is shown in the table.
+type templateFileFilterEncoder struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func (e *templateFileFilterEncoder) Encode(filters []gmail.Filter) error {
+	v, err := genericJSON(filters)
+	if err != nil {
+		return err
+	}
+	if doc, ok := v.(map[string]interface{}); ok {
+		if list, ok := doc["filters"].([]interface{}); ok {
+			for i, elem := range list {
+				if f, ok := elem.(map[string]interface{}); ok {
+					f["query"] = filters[i].Criteria.String()
+					f["description"] = filters[i].Action.String()
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := e.tmpl.Execute(&buf, v); err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(e.w)
+	return err
+}
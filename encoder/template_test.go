@@ -0,0 +1,58 @@
+package encoder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplateFileFilterEncoder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gmac-encoder-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "filters.md.tmpl")
+	tmpl := `| ID | From | Query | Action |
+| --- | --- | --- | --- |
+{{- range .filters}}
+| {{.id}} | {{join ", " .criteria.from}} | {{markdown .query}} | {{markdown .description}} |
+{{- end}}
+`
+	if err := ioutil.WriteFile(path, []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	enc, err := NewFilterEncoder(&buf, "template="+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(testFilters); err != nil {
+		t.Fatal(err)
+	}
+	want := `| ID | From | Query | Action |
+| --- | --- | --- | --- |
+| id1 | foo@example.com | from:foo@example.com | Skip Inbox, Apply label "foo" |
+| id2 |  | subject:{bar baz} | Star it |
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, want)
+		return
+	}
+}
+
+func TestTemplateFileFilterEncoderError(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := NewFilterEncoder(&buf, "template"); err == nil {
+		t.Errorf("error should be occurred without file")
+		return
+	}
+	if _, err := NewFilterEncoder(&buf, "template="+filepath.Join("testdata", "not-exist.tmpl")); err == nil {
+		t.Errorf("error should be occurred with non-existent file")
+		return
+	}
+}
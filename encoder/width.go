@@ -0,0 +1,133 @@
+package encoder
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+const (
+	zeroWidthJoiner        = '\u200d'
+	variationSelectorEmoji = '\ufe0f'
+
+	ellipsis = "..."
+)
+
+// graphemes splits s into grapheme clusters, which are displayed as
+// single characters. This is a practical subset of the rules of UAX #29:
+// combining marks, variation selectors, emoji modifiers, emoji ZWJ
+// sequences, flags (pairs of regional indicators) and Hangul jamo are
+// clustered with the preceding character.
+func graphemes(s string) []string {
+	var clusters []string
+	start := 0
+	var prev rune = -1
+	regionalIndicators := 0
+	for i, r := range s {
+		if i > start && !continuesCluster(prev, r, regionalIndicators) {
+			clusters = append(clusters, s[start:i])
+			start = i
+			regionalIndicators = 0
+		}
+		if isRegionalIndicator(r) {
+			regionalIndicators++
+		}
+		prev = r
+	}
+	if start < len(s) {
+		clusters = append(clusters, s[start:])
+	}
+	return clusters
+}
+
+// continuesCluster reports whether r belongs to the cluster whose last
+// rune is prev. regionalIndicators is the number of regional indicators
+// in the cluster.
+func continuesCluster(prev, r rune, regionalIndicators int) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return true
+	case prev == '\r' || prev == '\n' || r == '\r' || r == '\n':
+		return false
+	case prev == zeroWidthJoiner:
+		return true
+	case isRegionalIndicator(r):
+		return isRegionalIndicator(prev) && regionalIndicators%2 == 1
+	}
+	return isExtend(r)
+}
+
+// isExtend reports whether r extends the preceding character.
+func isExtend(r rune) bool {
+	switch {
+	case r == zeroWidthJoiner,
+		'\U0001f3fb' <= r && r <= '\U0001f3ff', // emoji modifiers
+		'\u1160' <= r && r <= '\u11ff',         // Hangul jamo vowels and trailing consonants
+		'\ud7b0' <= r && r <= '\ud7ff',         // Hangul jamo extended-B
+		'\U000e0020' <= r && r <= '\U000e007f', // tags
+		'\U000e0100' <= r && r <= '\U000e01ef': // variation selectors supplement
+		return true
+	}
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc, unicode.Variation_Selector)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return '\U0001f1e6' <= r && r <= '\U0001f1ff'
+}
+
+// stringWidth returns the display width of s in terminals, where East
+// Asian wide characters and emoji occupy two columns.
+func stringWidth(s string) int {
+	w := 0
+	for _, g := range graphemes(s) {
+		w += graphemeWidth(g)
+	}
+	return w
+}
+
+func graphemeWidth(g string) int {
+	r, _ := utf8.DecodeRuneInString(g)
+	switch {
+	case isRegionalIndicator(r):
+		return 2
+	case unicode.IsControl(r) || isExtend(r):
+		return 0
+	case strings.ContainsRune(g, variationSelectorEmoji):
+		return 2
+	}
+	return runeWidth(r)
+}
+
+func runeWidth(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// truncate truncates s at a grapheme boundary so that the display width
+// is at most maxWidth, appending an ellipsis if s is truncated.
+func truncate(s string, maxWidth int) string {
+	if stringWidth(s) <= maxWidth {
+		return s
+	}
+	limit := maxWidth - len(ellipsis)
+	if limit < 0 {
+		return ellipsis[:maxWidth]
+	}
+	var b strings.Builder
+	w := 0
+	for _, g := range graphemes(s) {
+		gw := graphemeWidth(g)
+		if w+gw > limit {
+			break
+		}
+		b.WriteString(g)
+		w += gw
+	}
+	b.WriteString(ellipsis)
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package encoder
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		label string
+		input string
+		want  []string
+	}{
+		{
+			label: "ascii",
+			input: "abc",
+			want:  []string{"a", "b", "c"},
+		},
+		{
+			label: "combining mark",
+			input: "e\u0301x",
+			want:  []string{"e\u0301", "x"},
+		},
+		{
+			label: "emoji zwj sequence",
+			input: "\U0001f468\u200d\U0001f469\u200d\U0001f467a",
+			want:  []string{"\U0001f468\u200d\U0001f469\u200d\U0001f467", "a"},
+		},
+		{
+			label: "emoji modifier",
+			input: "\U0001f44d\U0001f3fd",
+			want:  []string{"\U0001f44d\U0001f3fd"},
+		},
+		{
+			label: "flags",
+			input: "\U0001f1ef\U0001f1f5\U0001f1fa\U0001f1f8",
+			want:  []string{"\U0001f1ef\U0001f1f5", "\U0001f1fa\U0001f1f8"},
+		},
+		{
+			label: "hangul jamo",
+			input: "\u1100\u1161\u11a8",
+			want:  []string{"\u1100\u1161\u11a8"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			if got := graphemes(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%q != %q", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		label string
+		input string
+		want  int
+	}{
+		{
+			label: "ascii",
+			input: "hello",
+			want:  5,
+		},
+		{
+			label: "japanese",
+			input: "日本語のラベル",
+			want:  14,
+		},
+		{
+			label: "halfwidth katakana",
+			input: "ｶﾀｶﾅ",
+			want:  4,
+		},
+		{
+			label: "combining mark",
+			input: "cafe\u0301",
+			want:  4,
+		},
+		{
+			label: "emoji",
+			input: "\U0001f468\u200d\U0001f469\u200d\U0001f467!",
+			want:  3,
+		},
+		{
+			label: "emoji presentation",
+			input: "\u2764\ufe0f",
+			want:  2,
+		},
+		{
+			label: "flag",
+			input: "\U0001f1ef\U0001f1f5",
+			want:  2,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			if got := stringWidth(tt.input); got != tt.want {
+				t.Errorf("%d != %d", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		label    string
+		input    string
+		maxWidth int
+		want     string
+	}{
+		{
+			label:    "fits",
+			input:    "hello",
+			maxWidth: 5,
+			want:     "hello",
+		},
+		{
+			label:    "ascii",
+			input:    "hello world",
+			maxWidth: 8,
+			want:     "hello...",
+		},
+		{
+			label:    "wide characters",
+			input:    "日本語のラベル",
+			maxWidth: 8,
+			want:     "日本...",
+		},
+		{
+			label:    "combining mark is not split",
+			input:    "cafe\u0301 au lait",
+			maxWidth: 7,
+			want:     "cafe\u0301...",
+		},
+		{
+			label:    "zwj sequence is not split",
+			input:    "a\U0001f468\u200d\U0001f469\u200d\U0001f467bcdef",
+			maxWidth: 6,
+			want:     "a\U0001f468\u200d\U0001f469\u200d\U0001f467...",
+		},
+		{
+			label:    "narrower than ellipsis",
+			input:    "hello",
+			maxWidth: 2,
+			want:     "..",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			got := truncate(tt.input, tt.maxWidth)
+			if got != tt.want {
+				t.Errorf("%q != %q", got, tt.want)
+				return
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("invalid UTF-8: %q", got)
+				return
+			}
+			if w := stringWidth(got); w > tt.maxWidth {
+				t.Errorf("width %d exceeds %d", w, tt.maxWidth)
+				return
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package gmail
+
+// FilterTest is a test case of filters, which describes a message
+// and the expected result of applying filters to the message.
+type FilterTest struct {
+	Name    string            `yaml:"name,omitempty"`
+	Message TestMessage       `yaml:"message"`
+	Expect  FilterExpectation `yaml:"expect"`
+}
+
+// TestMessage is a synthetic message to test filters.
+type TestMessage struct {
+	From        string            `yaml:"from,omitempty"`
+	To          string            `yaml:"to,omitempty"`
+	Cc          string            `yaml:"cc,omitempty"`
+	Subject     string            `yaml:"subject,omitempty"`
+	Headers     map[string]string `yaml:"headers,omitempty"`
+	Body        string            `yaml:"body,omitempty"`
+	Attachments []string          `yaml:"attachments,omitempty"`
+	// Size is the size of the message in bytes.
+	// If zero, the size is calculated from the headers and the body.
+	Size int64 `yaml:"size,omitempty"`
+}
+
+// FilterExpectation is the expected result of applying filters.
+// Unspecified fields are not checked.
+type FilterExpectation struct {
+	// Labels are the labels which the message must have, and
+	// NotLabels are the labels which the message must not have.
+	// System labels are specified by their IDs, e.g. "INBOX" or "STARRED".
+	Labels    []string `yaml:"labels,omitempty"`
+	NotLabels []string `yaml:"not_labels,omitempty"`
+
+	Archive         *bool                 `yaml:"archive,omitempty"`
+	MarkAsRead      *bool                 `yaml:"mark_as_read,omitempty"`
+	Star            *bool                 `yaml:"star,omitempty"`
+	Delete          *bool                 `yaml:"delete,omitempty"`
+	NeverMarkAsSpam *bool                 `yaml:"never_mark_as_spam,omitempty"`
+	Important       FilterActionImportant `yaml:"important,omitempty"`
+	Category        string                `yaml:"category,omitempty"`
+	ForwardTo       string                `yaml:"forward_to,omitempty"`
+
+	// Matches is the expected number of filters matching the message.
+	Matches *int `yaml:"matches,omitempty"`
+}
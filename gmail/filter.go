@@ -4,49 +4,62 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"regexp"
+	"reflect"
 	"strconv"
 	"strings"
 
 	"github.com/nasa9084/go-pageloop"
 	"google.golang.org/api/gmail/v1"
+
+	"github.com/nasa9084/gmac/query"
 )
 
 const ResourceTypeFilter = "Filter"
 
 type FilterResource struct {
-	Filters []Filter `yaml:"filters"`
+	Filters []Filter     `yaml:"filters"`
+	Tests   []FilterTest `yaml:"tests,omitempty"`
 }
 
 type Filter struct {
-	id string `yaml:"-"`
-
-	Criteria FilterCriteria `yaml:"criteria"`
-	Action   FilterAction   `yaml:"action"`
+	// ID is the ID of the filter in Gmail, which is set only on the
+	// filters got from Gmail. It is ignored on creating filters.
+	ID string `yaml:"id,omitempty" json:"id,omitempty"`
+	// raw is the filter got from Gmail, before translation.
+	raw *gmail.Filter
+
+	Criteria FilterCriteria `yaml:"criteria" json:"criteria"`
+	Action   FilterAction   `yaml:"action" json:"action"`
 }
 
 type FilterCriteria struct {
-	From          string `yaml:"from,omitempty"`
-	To            string `yaml:"to,omitempty"`
-	Subject       string `yaml:"subject,omitempty"`
-	Query         string `yaml:"query,omitempty"`
-	NegatedQuery  string `yaml:"negated_query,omitempty"`
-	LargerThan    int64  `yaml:"larger_than,omitempty"`
-	SmallerThan   int64  `yaml:"smaller_than,omitempty"`
-	HasAttachment bool   `yaml:"has_attachment,omitempty"`
-	ExcludeChats  bool   `yaml:"exclude_chats,omitempty"`
+	From          Terms  `yaml:"from,omitempty" json:"from,omitempty"`
+	To            Terms  `yaml:"to,omitempty" json:"to,omitempty"`
+	Subject       Terms  `yaml:"subject,omitempty" json:"subject,omitempty"`
+	Query         string `yaml:"query,omitempty" json:"query,omitempty"`
+	NegatedQuery  string `yaml:"negated_query,omitempty" json:"negated_query,omitempty"`
+	LargerThan    int64  `yaml:"larger_than,omitempty" json:"larger_than,omitempty"`
+	SmallerThan   int64  `yaml:"smaller_than,omitempty" json:"smaller_than,omitempty"`
+	HasAttachment bool   `yaml:"has_attachment,omitempty" json:"has_attachment,omitempty"`
+	ExcludeChats  bool   `yaml:"exclude_chats,omitempty" json:"exclude_chats,omitempty"`
+
+	// AnyOf, AllOf and NoneOf are nested criteria which are compiled
+	// into the search query, combined with OR, AND and NOT (OR).
+	AnyOf  []FilterCriteria `yaml:"any_of,omitempty" json:"any_of,omitempty"`
+	AllOf  []FilterCriteria `yaml:"all_of,omitempty" json:"all_of,omitempty"`
+	NoneOf []FilterCriteria `yaml:"none_of,omitempty" json:"none_of,omitempty"`
 }
 
 type FilterAction struct {
-	Archive         bool                  `yaml:"archive,omitempty"`
-	MarkAsRead      bool                  `yaml:"mark_as_read,omitempty"`
-	Star            bool                  `yaml:"star,omitempty"`
-	AddLabel        string                `yaml:"add_label,omitempty"`
-	ForwardTo       string                `yaml:"forward_to,omitempty"`
-	Delete          bool                  `yaml:"delete,omitempty"`
-	NeverMarkAsSpam bool                  `yaml:"never_mark_as_spam,omitempty"`
-	Important       FilterActionImportant `yaml:"important,omitempty"`
-	Category        string                `yaml:"category,omitempty"`
+	Archive         bool                  `yaml:"archive,omitempty" json:"archive,omitempty"`
+	MarkAsRead      bool                  `yaml:"mark_as_read,omitempty" json:"mark_as_read,omitempty"`
+	Star            bool                  `yaml:"star,omitempty" json:"star,omitempty"`
+	AddLabel        string                `yaml:"add_label,omitempty" json:"add_label,omitempty"`
+	ForwardTo       string                `yaml:"forward_to,omitempty" json:"forward_to,omitempty"`
+	Delete          bool                  `yaml:"delete,omitempty" json:"delete,omitempty"`
+	NeverMarkAsSpam bool                  `yaml:"never_mark_as_spam,omitempty" json:"never_mark_as_spam,omitempty"`
+	Important       FilterActionImportant `yaml:"important,omitempty" json:"important,omitempty"`
+	Category        string                `yaml:"category,omitempty" json:"category,omitempty"`
 }
 
 type FilterActionImportant string
@@ -68,21 +81,36 @@ func (c *Client) ListFilters(ctx context.Context) ([]Filter, error) {
 	return filters, nil
 }
 
-func (c *Client) CreateFilter(ctx context.Context, filter Filter) error {
+// GetFilter returns the filter of given ID.
+func (c *Client) GetFilter(ctx context.Context, id string) (Filter, error) {
+	if id == "" {
+		return Filter{}, errors.New("id must be non-empty")
+	}
+	gf, err := c.svc.Users.Settings.Filters.Get("me", id).Context(ctx).Do()
+	if err != nil {
+		return Filter{}, err
+	}
+	return c.convertFilterFromGmail(gf), nil
+}
+
+// CreateFilter creates given filter, then returns the ID of the created
+// filter. The label to be added is created if it does not exist.
+func (c *Client) CreateFilter(ctx context.Context, filter Filter) (string, error) {
 	if filter.Action.AddLabel != "" {
 		if err := c.CreateLabel(ctx, filter.Action.AddLabel); err != nil {
-			return err
+			return "", err
 		}
 	}
 
 	gf, err := c.convertFilterToGmail(filter)
 	if err != nil {
-		return err
+		return "", err
 	}
-	if _, err := c.svc.Users.Settings.Filters.Create("me", gf).Context(ctx).Do(); err != nil {
-		return err
+	created, err := c.svc.Users.Settings.Filters.Create("me", gf).Context(ctx).Do()
+	if err != nil {
+		return "", err
 	}
-	return nil
+	return created.Id, nil
 }
 
 func (c *Client) DeleteAllFilter(ctx context.Context) error {
@@ -92,7 +120,7 @@ func (c *Client) DeleteAllFilter(ctx context.Context) error {
 	}
 
 	for _, filter := range filters {
-		if err := c.DeleteFilterByID(ctx, filter.id); err != nil {
+		if err := c.DeleteFilterByID(ctx, filter.ID); err != nil {
 			return err
 		}
 	}
@@ -120,12 +148,6 @@ func (c *Client) ApplyLabelToExistingEmail(ctx context.Context, filter Filter) e
 	if err := c.svc.Users.Messages.List("me").Q(filter.Criteria.String()).MaxResults(999).Pages(
 		ctx,
 		func(msgsResp *gmail.ListMessagesResponse) error {
-			if len(msgsResp.Messages) == 0 {
-				return nil
-			}
-
-			messageIDs := make([]string, 0, len(msgsResp.Messages))
-
 			for _, msg := range msgsResp.Messages {
 				messageIDs = append(messageIDs, msg.Id)
 			}
@@ -158,14 +180,78 @@ func (c *Client) ApplyLabelToExistingEmail(ctx context.Context, filter Filter) e
 	return nil
 }
 
+// IsSameFilter reports whether the filter in Gmail is the same as given
+// filter. They are compared in the form of Gmail API, so the differences
+// of notation, e.g. the category name "social" and "CATEGORY_SOCIAL",
+// are ignored.
+func (c *Client) IsSameFilter(live, filter Filter) (bool, error) {
+	a := live.raw
+	if a == nil {
+		var err error
+		a, err = c.convertFilterToGmail(live)
+		if err != nil {
+			return false, err
+		}
+	}
+	b, err := c.convertFilterToGmail(filter)
+	if err != nil {
+		return false, err
+	}
+	return sameGmailFilter(a, b), nil
+}
+
+func sameGmailFilter(a, b *gmail.Filter) bool {
+	var ca, cb gmail.FilterCriteria
+	if a.Criteria != nil {
+		ca = *a.Criteria
+	}
+	if b.Criteria != nil {
+		cb = *b.Criteria
+	}
+	ca.ForceSendFields, ca.NullFields = nil, nil
+	cb.ForceSendFields, cb.NullFields = nil, nil
+	if !reflect.DeepEqual(ca, cb) {
+		return false
+	}
+
+	var aa, ab gmail.FilterAction
+	if a.Action != nil {
+		aa = *a.Action
+	}
+	if b.Action != nil {
+		ab = *b.Action
+	}
+	return aa.Forward == ab.Forward &&
+		sameStringSet(aa.AddLabelIds, ab.AddLabelIds) &&
+		sameStringSet(aa.RemoveLabelIds, ab.RemoveLabelIds)
+}
+
+func sameStringSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := map[string]int{}
+	for _, s := range a {
+		count[s]++
+	}
+	for _, s := range b {
+		if count[s] == 0 {
+			return false
+		}
+		count[s]--
+	}
+	return true
+}
+
 func (c *Client) convertFilterFromGmail(gf *gmail.Filter) Filter {
 	var f Filter
-	f.id = gf.Id
+	f.ID = gf.Id
+	f.raw = gf
 	// criteria
 	f.Criteria = FilterCriteria{
-		From:          gf.Criteria.From,
-		To:            gf.Criteria.To,
-		Subject:       gf.Criteria.Subject,
+		From:          termsOf(gf.Criteria.From),
+		To:            termsOf(gf.Criteria.To),
+		Subject:       termsOf(gf.Criteria.Subject),
 		Query:         gf.Criteria.Query,
 		NegatedQuery:  gf.Criteria.NegatedQuery,
 		HasAttachment: gf.Criteria.HasAttachment,
@@ -186,16 +272,8 @@ func (c *Client) convertFilterFromGmail(gf *gmail.Filter) Filter {
 			f.Action.Important = FilterActionImportantAlways
 		case "STARRED":
 			f.Action.Star = true
-		case "CATEGORY_PERSONAL":
-			f.Action.Category = "primary"
-		case "CATEGORY_SOCIAL":
-			f.Action.Category = "social"
-		case "CATEGORY_UPDATES":
-			f.Action.Category = "updates"
-		case "CATEGORY_FORUMS":
-			f.Action.Category = "forums"
-		case "CATEGORY_PROMOTIONS":
-			f.Action.Category = "promotions"
+		case "CATEGORY_PERSONAL", "CATEGORY_SOCIAL", "CATEGORY_UPDATES", "CATEGORY_FORUMS", "CATEGORY_PROMOTIONS":
+			f.Action.Category = categoryByLabelID(id)
 		default:
 			f.Action.AddLabel = c.labelmap.getNameByID(id)
 		}
@@ -215,13 +293,29 @@ func (c *Client) convertFilterFromGmail(gf *gmail.Filter) Filter {
 	return f
 }
 
+// Raw returns the filter got from Gmail before translation, or nil if
+// the filter is not got from Gmail.
+func (f Filter) Raw() *gmail.Filter {
+	return f.raw
+}
+
+// LabelIDs returns the IDs of labels which are added and removed by the
+// filter, as they are got from Gmail. nil is returned if the filter is
+// not got from Gmail.
+func (f Filter) LabelIDs() (add, remove []string) {
+	if f.raw == nil || f.raw.Action == nil {
+		return nil, nil
+	}
+	return f.raw.Action.AddLabelIds, f.raw.Action.RemoveLabelIds
+}
+
 func (c *Client) convertFilterToGmail(filter Filter) (*gmail.Filter, error) {
 	gf := &gmail.Filter{
 		Criteria: &gmail.FilterCriteria{
-			From:          filter.Criteria.From,
-			To:            filter.Criteria.To,
-			Subject:       filter.Criteria.Subject,
-			Query:         filter.Criteria.Query,
+			From:          filter.Criteria.From.Query(),
+			To:            filter.Criteria.To.Query(),
+			Subject:       filter.Criteria.Subject.Query(),
+			Query:         filter.Criteria.gmailQuery(),
 			NegatedQuery:  filter.Criteria.NegatedQuery,
 			HasAttachment: filter.Criteria.HasAttachment,
 			ExcludeChats:  filter.Criteria.ExcludeChats,
@@ -239,99 +333,178 @@ func (c *Client) convertFilterToGmail(filter Filter) (*gmail.Filter, error) {
 	if filter.Action.AddLabel != "" {
 		gf.Action.AddLabelIds = append(gf.Action.AddLabelIds, c.labelmap.getIDByName(filter.Action.AddLabel))
 	}
-	if filter.Action.Delete {
-		gf.Action.AddLabelIds = append(gf.Action.AddLabelIds, "TRASH")
+	add, remove, err := filter.Action.SystemLabelChanges()
+	if err != nil {
+		return nil, err
+	}
+	gf.Action.AddLabelIds = append(gf.Action.AddLabelIds, add...)
+	gf.Action.RemoveLabelIds = remove
+	return gf, nil
+}
+
+// SystemLabelChanges returns the IDs of system labels to be added and
+// removed by the action, e.g. "INBOX" or "CATEGORY_SOCIAL". The user
+// label added by the action is not included, which is AddLabel.
+func (action FilterAction) SystemLabelChanges() (add, remove []string, err error) {
+	if action.Delete {
+		add = append(add, "TRASH")
 	}
-	switch filter.Action.Important {
+	switch action.Important {
 	case "": // nothing to do
 	case FilterActionImportantAlways:
-		gf.Action.AddLabelIds = append(gf.Action.AddLabelIds, "IMPORTANT")
+		add = append(add, "IMPORTANT")
 	case FilterActionImportantNever:
-		gf.Action.RemoveLabelIds = append(gf.Action.RemoveLabelIds, "IMPORTANT")
+		remove = append(remove, "IMPORTANT")
 	default:
-		return nil, fmt.Errorf("unknown action.important value: %s", filter.Action.Important)
+		return nil, nil, fmt.Errorf("unknown action.important value: %s", action.Important)
 	}
-	if filter.Action.Star {
-		gf.Action.AddLabelIds = append(gf.Action.AddLabelIds, "STARRED")
+	if action.Star {
+		add = append(add, "STARRED")
 	}
-	switch filter.Action.Category {
-	case "": // nothing to do
-	case "primary", "personal", "main":
-		gf.Action.AddLabelIds = append(gf.Action.AddLabelIds, "CATEGORY_PERSONAL")
-	case "social":
-		gf.Action.AddLabelIds = append(gf.Action.AddLabelIds, "CATEGORY_SOCIAL")
-	case "update", "updates", "new":
-		gf.Action.AddLabelIds = append(gf.Action.AddLabelIds, "CATEGORY_UPDATES")
-	case "forum", "forums":
-		gf.Action.AddLabelIds = append(gf.Action.AddLabelIds, "CATEGORY_FORUMS")
-	case "promotion", "promotions":
-		gf.Action.AddLabelIds = append(gf.Action.AddLabelIds, "CATEGORY_PROMOTIONS")
-	default:
-		return nil, fmt.Errorf("unknown action.category value: %s", filter.Action.Category)
+	if action.Category != "" {
+		id, ok := categoryLabelIDs[CanonicalCategory(action.Category)]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown action.category value: %s", action.Category)
+		}
+		add = append(add, id)
 	}
-	if filter.Action.Archive {
-		gf.Action.RemoveLabelIds = append(gf.Action.RemoveLabelIds, "INBOX")
+	if action.Archive {
+		remove = append(remove, "INBOX")
 	}
-	if filter.Action.MarkAsRead {
-		gf.Action.RemoveLabelIds = append(gf.Action.RemoveLabelIds, "UNREAD")
+	if action.MarkAsRead {
+		remove = append(remove, "UNREAD")
 	}
-	if filter.Action.NeverMarkAsSpam {
-		gf.Action.RemoveLabelIds = append(gf.Action.RemoveLabelIds, "SPAM")
+	if action.NeverMarkAsSpam {
+		remove = append(remove, "SPAM")
 	}
-	return gf, nil
+	return add, remove, nil
 }
 
-func (f Filter) String() string {
-	return f.Criteria.String() + " => " + f.Action.String()
+// categoryLabelIDs maps canonical category names to their label IDs.
+var categoryLabelIDs = map[string]string{
+	"primary":    "CATEGORY_PERSONAL",
+	"social":     "CATEGORY_SOCIAL",
+	"updates":    "CATEGORY_UPDATES",
+	"forums":     "CATEGORY_FORUMS",
+	"promotions": "CATEGORY_PROMOTIONS",
 }
 
-var nonParen = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+var categoryAliases = map[string]string{
+	"personal":  "primary",
+	"main":      "primary",
+	"update":    "updates",
+	"new":       "updates",
+	"forum":     "forums",
+	"promotion": "promotions",
+}
 
-func (criteria FilterCriteria) String() string {
-	var cq []string
-	if criteria.From != "" {
-		if nonParen.MatchString(criteria.From) {
-			cq = append(cq, "from:"+criteria.From)
-		} else {
-			cq = append(cq, fmt.Sprintf("from:(%s)", criteria.From))
-		}
+// CanonicalCategory returns the canonical name of given category,
+// e.g. "updates" for "update" or "new". Unknown category is returned
+// as is.
+func CanonicalCategory(category string) string {
+	if canonical, ok := categoryAliases[category]; ok {
+		return canonical
 	}
-	if criteria.To != "" {
-		if nonParen.MatchString(criteria.To) {
-			cq = append(cq, "to:"+criteria.To)
-		} else {
-			cq = append(cq, fmt.Sprintf("to:(%s)", criteria.To))
+	return category
+}
+
+func categoryByLabelID(id string) string {
+	for name, labelID := range categoryLabelIDs {
+		if labelID == id {
+			return name
 		}
 	}
-	if criteria.Subject != "" {
-		if nonParen.MatchString(criteria.Subject) {
-			cq = append(cq, "subject:"+criteria.Subject)
-		} else {
-			cq = append(cq, fmt.Sprintf("subject:(%s)", criteria.Subject))
-		}
+	return ""
+}
+
+func (f Filter) String() string {
+	return f.Criteria.String() + " => " + f.Action.String()
+}
+
+func (criteria FilterCriteria) String() string {
+	return criteria.Node().String()
+}
+
+// Node returns the syntax tree of the search query which is
+// equivalent to the criteria.
+func (criteria FilterCriteria) Node() query.Node {
+	var nodes []query.Node
+	if from := criteria.From.Node(); from != nil {
+		nodes = append(nodes, query.Field{Name: "from", Value: from})
 	}
-	if criteria.Query != "" {
-		cq = append(cq, criteria.Query)
+	if to := criteria.To.Node(); to != nil {
+		nodes = append(nodes, query.Field{Name: "to", Value: to})
 	}
-	if criteria.NegatedQuery != "" {
-		if nonParen.MatchString(criteria.NegatedQuery) {
-			cq = append(cq, "-"+criteria.NegatedQuery)
-		} else {
-			cq = append(cq, fmt.Sprintf("-{%s}", criteria.NegatedQuery))
-		}
+	if subject := criteria.Subject.Node(); subject != nil {
+		nodes = append(nodes, query.Field{Name: "subject", Value: subject})
+	}
+	nodes = append(nodes, criteria.queryNode())
+	if negated := criteria.negatedQueryNode(); negated != nil {
+		nodes = append(nodes, negated)
 	}
 	if criteria.HasAttachment {
-		cq = append(cq, "has:attachment")
+		nodes = append(nodes, query.Field{Name: "has", Value: query.Word{Value: "attachment"}})
 	}
 	if criteria.ExcludeChats {
-		cq = append(cq, "-in:chats")
+		nodes = append(nodes, query.Not{Node: query.Field{Name: "in", Value: query.Word{Value: "chats"}}})
 	}
 	if criteria.LargerThan > 0 {
-		cq = append(cq, "larger:"+strconv.FormatInt(criteria.LargerThan, 10))
+		nodes = append(nodes, query.Field{Name: "larger", Value: query.Word{Value: strconv.FormatInt(criteria.LargerThan, 10)}})
 	} else if criteria.SmallerThan > 0 {
-		cq = append(cq, "smaller:"+strconv.FormatInt(criteria.SmallerThan, 10))
+		nodes = append(nodes, query.Field{Name: "smaller", Value: query.Word{Value: strconv.FormatInt(criteria.SmallerThan, 10)}})
+	}
+	return query.NewAnd(nodes...)
+}
+
+// negatedQueryNode returns the syntax tree of NegatedQuery, or nil if it
+// is empty. Like "Doesn't have" in Gmail, messages must have none of
+// the terms, so `foo bar` means `-{foo bar}` rather than `-(foo bar)`.
+func (criteria FilterCriteria) negatedQueryNode() query.Node {
+	negated := parseQuery(criteria.NegatedQuery)
+	if negated == nil {
+		return nil
+	}
+	if and, ok := negated.(query.And); ok {
+		negated = query.NewOr(and.Nodes...)
+	}
+	return query.Not{Node: negated}
+}
+
+// gmailQuery returns the query to be sent to Gmail. Query is sent as it
+// is written unless any_of, all_of or none_of blocks need to be
+// compiled into it, so that the criteria stored in Gmail are not
+// changed by printing the parsed query.
+func (criteria FilterCriteria) gmailQuery() string {
+	if len(criteria.AnyOf) == 0 && len(criteria.AllOf) == 0 && len(criteria.NoneOf) == 0 {
+		return criteria.Query
+	}
+	return criteria.queryNode().String()
+}
+
+// queryNode returns the syntax tree of Query combined with
+// any_of, all_of and none_of blocks.
+func (criteria FilterCriteria) queryNode() query.Node {
+	nodes := []query.Node{parseQuery(criteria.Query)}
+	for _, c := range criteria.AllOf {
+		nodes = append(nodes, c.Node())
+	}
+	if len(criteria.AnyOf) > 0 {
+		anyOf := make([]query.Node, 0, len(criteria.AnyOf))
+		for _, c := range criteria.AnyOf {
+			anyOf = append(anyOf, c.Node())
+		}
+		nodes = append(nodes, query.NewOr(anyOf...))
+	}
+	if len(criteria.NoneOf) > 0 {
+		noneOf := make([]query.Node, 0, len(criteria.NoneOf))
+		for _, c := range criteria.NoneOf {
+			noneOf = append(noneOf, c.Node())
+		}
+		if n := query.NewOr(noneOf...); !query.IsEmpty(n) {
+			nodes = append(nodes, query.Not{Node: n})
+		}
 	}
-	return strings.Join(cq, " ")
+	return query.NewAnd(nodes...)
 }
 
 func (action FilterAction) String() string {
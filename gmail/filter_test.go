@@ -1,12 +1,18 @@
 package gmail
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 	"testing"
 
 	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
 )
 
 func TestConvertFilterFromGmail(t *testing.T) {
@@ -29,9 +35,9 @@ func TestConvertFilterFromGmail(t *testing.T) {
 			},
 			want: Filter{
 				Criteria: FilterCriteria{
-					From:         "fromAddrFoo",
-					To:           "toAddrFoo",
-					Subject:      "subjectStringFoo",
+					From:         Terms{"fromAddrFoo"},
+					To:           Terms{"toAddrFoo"},
+					Subject:      Terms{"subjectStringFoo"},
 					Query:        "queryStringFoo",
 					NegatedQuery: "negatedQueryStringFoo",
 				},
@@ -293,22 +299,22 @@ func TestConvertFilterFromGmail(t *testing.T) {
 		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
 			got := c.convertFilterFromGmail(tt.input)
 			// check Criteria
-			if got.Criteria.From != tt.want.Criteria.From {
-				t.Errorf("unexpected Criteria.From: %s != %s",
+			if !reflect.DeepEqual(got.Criteria.From, tt.want.Criteria.From) {
+				t.Errorf("unexpected Criteria.From: %v != %v",
 					got.Criteria.From,
 					tt.want.Criteria.From,
 				)
 				return
 			}
-			if got.Criteria.To != tt.want.Criteria.To {
-				t.Errorf("unexpected Criteria.To: %s != %s",
+			if !reflect.DeepEqual(got.Criteria.To, tt.want.Criteria.To) {
+				t.Errorf("unexpected Criteria.To: %v != %v",
 					got.Criteria.To,
 					tt.want.Criteria.To,
 				)
 				return
 			}
-			if got.Criteria.Subject != tt.want.Criteria.Subject {
-				t.Errorf("unexpected Criteria.Subject: %s != %s",
+			if !reflect.DeepEqual(got.Criteria.Subject, tt.want.Criteria.Subject) {
+				t.Errorf("unexpected Criteria.Subject: %v != %v",
 					got.Criteria.Subject,
 					tt.want.Criteria.Subject,
 				)
@@ -445,14 +451,63 @@ func TestConvertFilterToGmail(t *testing.T) {
 			},
 			input: Filter{
 				Criteria: FilterCriteria{
-					From:         "fromAddrFoo",
-					To:           "toAddrFoo",
-					Subject:      "subjectStringFoo",
+					From:         Terms{"fromAddrFoo"},
+					To:           Terms{"toAddrFoo"},
+					Subject:      Terms{"subjectStringFoo"},
 					Query:        "queryStringFoo",
 					NegatedQuery: "negatedQueryStringFoo",
 				},
 			},
 		},
+		{
+			label: "user label named like system label",
+			want: &gmail.Filter{
+				Criteria: &gmail.FilterCriteria{},
+				Action: &gmail.FilterAction{
+					AddLabelIds: []string{"Label_11", "IMPORTANT"},
+				},
+			},
+			input: Filter{
+				Action: FilterAction{
+					AddLabel:  "IMPORTANT",
+					Important: FilterActionImportantAlways,
+				},
+			},
+		},
+		{
+			label: "query is sent as it is written",
+			want: &gmail.Filter{
+				Criteria: &gmail.FilterCriteria{
+					Query: "from:foo  OR from:bar",
+				},
+				Action: &gmail.FilterAction{},
+			},
+			input: Filter{
+				Criteria: FilterCriteria{
+					Query: "from:foo  OR from:bar",
+				},
+			},
+		},
+		{
+			label: "check list-based criteria",
+			want: &gmail.Filter{
+				Criteria: &gmail.FilterCriteria{
+					From:  `foo@example.com OR "John Doe"`,
+					Query: `label:work {from:bar@example.com subject:baz}`,
+				},
+				Action: &gmail.FilterAction{},
+			},
+			input: Filter{
+				Criteria: FilterCriteria{
+					From:  Terms{"foo@example.com", "John Doe"},
+					Query: "label:work",
+					AnyOf: []FilterCriteria{
+						{From: Terms{"bar@example.com"}},
+						{Subject: Terms{"baz"}},
+					},
+				},
+			},
+		},
 		{
 			label: "check smaller-than criteria",
 			want: &gmail.Filter{
@@ -700,7 +755,8 @@ func TestConvertFilterToGmail(t *testing.T) {
 	c := &Client{
 		labelmap: &labelmap{
 			name2id: map[string]string{
-				"LabelFoo": "Label_10",
+				"LabelFoo":  "Label_10",
+				"IMPORTANT": "Label_11",
 			},
 		},
 	}
@@ -799,3 +855,287 @@ func TestConvertFilterToGmail(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterCriteriaString(t *testing.T) {
+	tests := []struct {
+		label string
+		input FilterCriteria
+		want  string
+	}{
+		{
+			label: "single terms",
+			input: FilterCriteria{
+				From:    Terms{"foo"},
+				Subject: Terms{"hello world"},
+			},
+			want: "from:foo subject:(hello world)",
+		},
+		{
+			label: "negated query",
+			input: FilterCriteria{
+				NegatedQuery: "foo bar",
+				ExcludeChats: true,
+				LargerThan:   1000,
+			},
+			want: "-{foo bar} -in:chats larger:1000",
+		},
+		{
+			label: "list terms",
+			input: FilterCriteria{
+				From: Terms{"foo@example.com", "John Doe"},
+			},
+			want: `from:{foo@example.com "John Doe"}`,
+		},
+		{
+			label: "any_of block",
+			input: FilterCriteria{
+				AnyOf: []FilterCriteria{
+					{From: Terms{"foo@example.com"}},
+					{Subject: Terms{"alert"}, HasAttachment: true},
+				},
+			},
+			want: "{from:foo@example.com (subject:alert has:attachment)}",
+		},
+		{
+			label: "all_of and none_of blocks",
+			input: FilterCriteria{
+				Query: "label:work",
+				AllOf: []FilterCriteria{
+					{To: Terms{"team@example.com"}},
+				},
+				NoneOf: []FilterCriteria{
+					{From: Terms{"bot@example.com"}},
+					{Query: "is:chat"},
+				},
+			},
+			want: "label:work to:team@example.com -{from:bot@example.com is:chat}",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			if got := tt.input.String(); got != tt.want {
+				t.Errorf("unexpected string:\n  got:  %s\n  want: %s", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestFilterLabelIDs(t *testing.T) {
+	c := &Client{labelmap: &labelmap{id2name: map[string]string{"Label_10": "LabelFoo"}}}
+	f := c.convertFilterFromGmail(&gmail.Filter{
+		Id:       "ANe1Bmh",
+		Criteria: &gmail.FilterCriteria{From: "foo@example.com"},
+		Action: &gmail.FilterAction{
+			AddLabelIds:    []string{"Label_10", "STARRED"},
+			RemoveLabelIds: []string{"INBOX"},
+		},
+	})
+	if f.ID != "ANe1Bmh" {
+		t.Errorf("unexpected ID: %s", f.ID)
+		return
+	}
+	add, remove := f.LabelIDs()
+	if !reflect.DeepEqual(add, []string{"Label_10", "STARRED"}) || !reflect.DeepEqual(remove, []string{"INBOX"}) {
+		t.Errorf("unexpected label IDs: %v, %v", add, remove)
+		return
+	}
+	if add, remove := (Filter{}).LabelIDs(); add != nil || remove != nil {
+		t.Errorf("label IDs of local filter must be nil: %v, %v", add, remove)
+		return
+	}
+}
+
+func TestGetAndCreateFilter(t *testing.T) {
+	oauthSrv, oauthCfg, oauthToken := testOAuth(t)
+	defer oauthSrv.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/labels"):
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(labelListResponseBody))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/settings/filters/filter1"):
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id": "filter1", "criteria": {"from": "foo@example.com"}, "action": {"addLabelIds": ["Label_10"], "removeLabelIds": ["INBOX"]}}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/settings/filters"):
+			var gf gmail.Filter
+			if err := json.NewDecoder(r.Body).Decode(&gf); err != nil {
+				t.Fatal(err)
+			}
+			if gf.Criteria.From != "bar@example.com" || !reflect.DeepEqual(gf.Action.AddLabelIds, []string{"Label_11"}) {
+				t.Errorf("unexpected filter in create request: %+v, %+v", gf.Criteria, gf.Action)
+			}
+			gf.Id = "filter2"
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(gf)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	defer func(orig func(context.Context, ...option.ClientOption) (*gmail.Service, error)) {
+		newGmailService = orig
+	}(newGmailService)
+	newGmailService = func(ctx context.Context, opts ...option.ClientOption) (*gmail.Service, error) {
+		opts = append(opts, option.WithEndpoint(srv.URL))
+		return gmail.NewService(ctx, opts...)
+	}
+
+	ctx := context.Background()
+	c, err := New(ctx, oauthCfg, oauthToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		got, err := c.GetFilter(ctx, "filter1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != "filter1" || got.String() != `from:foo@example.com => Skip Inbox, Apply label "Foo"` {
+			t.Errorf("unexpected filter: %s %s", got.ID, got)
+			return
+		}
+	})
+	t.Run("create", func(t *testing.T) {
+		id, err := c.CreateFilter(ctx, Filter{
+			Criteria: FilterCriteria{From: Terms{"bar@example.com"}},
+			Action:   FilterAction{AddLabel: "Bar"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != "filter2" {
+			t.Errorf("unexpected ID: %s != filter2", id)
+			return
+		}
+	})
+}
+
+func TestIsSameFilter(t *testing.T) {
+	c := &Client{
+		labelmap: &labelmap{
+			id2name: map[string]string{"Label_1": "Foo"},
+			name2id: map[string]string{"Foo": "Label_1"},
+		},
+	}
+	live := c.convertFilterFromGmail(&gmail.Filter{
+		Id: "id1",
+		Criteria: &gmail.FilterCriteria{
+			From: "foo@example.com",
+		},
+		Action: &gmail.FilterAction{
+			AddLabelIds:    []string{"STARRED", "CATEGORY_SOCIAL", "Label_1"},
+			RemoveLabelIds: []string{"INBOX"},
+		},
+	})
+	tests := []struct {
+		label  string
+		filter Filter
+		want   bool
+	}{
+		{
+			label: "same",
+			filter: Filter{
+				Criteria: FilterCriteria{From: Terms{"foo@example.com"}},
+				Action:   FilterAction{AddLabel: "Foo", Star: true, Archive: true, Category: "social"},
+			},
+			want: true,
+		},
+		{
+			label: "different criteria",
+			filter: Filter{
+				Criteria: FilterCriteria{From: Terms{"bar@example.com"}},
+				Action:   FilterAction{AddLabel: "Foo", Star: true, Archive: true, Category: "social"},
+			},
+			want: false,
+		},
+		{
+			label: "different action",
+			filter: Filter{
+				Criteria: FilterCriteria{From: Terms{"foo@example.com"}},
+				Action:   FilterAction{AddLabel: "Foo", Star: true, Category: "social"},
+			},
+			want: false,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			got, err := c.IsSameFilter(live, tt.filter)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("%t != %t", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestApplyLabelToExistingEmail(t *testing.T) {
+	oauthSrv, oauthCfg, oauthToken := testOAuth(t)
+	defer oauthSrv.Close()
+
+	var modified []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/labels"):
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(labelListResponseBody))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/messages"):
+			if q := r.URL.Query().Get("q"); q != "from:foo@example.com" {
+				t.Errorf("unexpected query: %s", q)
+			}
+			w.WriteHeader(http.StatusOK)
+			if r.URL.Query().Get("pageToken") == "" {
+				w.Write([]byte(`{"messages": [{"id": "msg1"}, {"id": "msg2"}], "nextPageToken": "page2"}`))
+				return
+			}
+			w.Write([]byte(`{"messages": [{"id": "msg3"}]}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/messages/batchModify"):
+			var req gmail.BatchModifyMessagesRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(req.AddLabelIds, []string{"Label_10"}) || !reflect.DeepEqual(req.RemoveLabelIds, []string{"INBOX"}) {
+				t.Errorf("unexpected labels in batch modify request: %v, %v", req.AddLabelIds, req.RemoveLabelIds)
+			}
+			modified = append(modified, req.Ids...)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	defer func(orig func(context.Context, ...option.ClientOption) (*gmail.Service, error)) {
+		newGmailService = orig
+	}(newGmailService)
+	newGmailService = func(ctx context.Context, opts ...option.ClientOption) (*gmail.Service, error) {
+		opts = append(opts, option.WithEndpoint(srv.URL))
+		return gmail.NewService(ctx, opts...)
+	}
+
+	ctx := context.Background()
+	c, err := New(ctx, oauthCfg, oauthToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ApplyLabelToExistingEmail(ctx, Filter{
+		Criteria: FilterCriteria{From: Terms{"foo@example.com"}},
+		Action:   FilterAction{AddLabel: "Foo", Archive: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"msg1", "msg2", "msg3"}; !reflect.DeepEqual(modified, want) {
+		t.Errorf("unexpected modified messages: %v != %v", modified, want)
+		return
+	}
+}
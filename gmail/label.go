@@ -2,6 +2,7 @@ package gmail
 
 import (
 	"context"
+	"sort"
 
 	"google.golang.org/api/gmail/v1"
 )
@@ -24,3 +25,38 @@ func (c *Client) CreateLabel(ctx context.Context, label string) error {
 
 	return nil
 }
+
+// Label is a label in Gmail.
+type Label struct {
+	ID   string
+	Name string
+	// Type is "system" for the labels created by Gmail, or "user".
+	Type string
+	// BackgroundColor and TextColor are the colors of the label in hex
+	// format like "#16a766", which are empty if the label has no color.
+	BackgroundColor string
+	TextColor       string
+}
+
+// ListLabels returns the labels in Gmail sorted by their names.
+func (c *Client) ListLabels(ctx context.Context) ([]Label, error) {
+	resp, err := c.svc.Users.Labels.List("me").Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	labels := make([]Label, 0, len(resp.Labels))
+	for _, l := range resp.Labels {
+		label := Label{
+			ID:   l.Id,
+			Name: l.Name,
+			Type: l.Type,
+		}
+		if l.Color != nil {
+			label.BackgroundColor = l.Color.BackgroundColor
+			label.TextColor = l.Color.TextColor
+		}
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels, nil
+}
@@ -125,3 +125,47 @@ func TestCreateLabel(t *testing.T) {
 		return
 	}
 }
+
+func TestListLabels(t *testing.T) {
+	oauthSrv, oauthCfg, oauthToken := testOAuth(t)
+	defer oauthSrv.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{
+ "labels": [
+  {"id": "INBOX", "name": "INBOX", "type": "system"},
+  {"id": "Label_10", "name": "Work/CI", "type": "user", "color": {"backgroundColor": "#16a766", "textColor": "#ffffff"}},
+  {"id": "Label_11", "name": "Bar", "type": "user"}
+ ]
+}`))
+	}))
+	defer srv.Close()
+
+	defer func(orig func(context.Context, ...option.ClientOption) (*gmail.Service, error)) {
+		newGmailService = orig
+	}(newGmailService)
+	newGmailService = func(ctx context.Context, opts ...option.ClientOption) (*gmail.Service, error) {
+		opts = append(opts, option.WithEndpoint(srv.URL))
+		return gmail.NewService(ctx, opts...)
+	}
+
+	ctx := context.Background()
+	c, err := New(ctx, oauthCfg, oauthToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.ListLabels(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Label{
+		{ID: "Label_11", Name: "Bar", Type: "user"},
+		{ID: "INBOX", Name: "INBOX", Type: "system"},
+		{ID: "Label_10", Name: "Work/CI", Type: "user", BackgroundColor: "#16a766", TextColor: "#ffffff"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected labels:\n  got:  %#v\n  want: %#v", got, want)
+		return
+	}
+}
@@ -0,0 +1,110 @@
+package gmail
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nasa9084/gmac/query"
+)
+
+// Normalize returns the normalized syntax tree of the criteria, which
+// is used to compare criteria regardless of how they are written.
+func (criteria FilterCriteria) Normalize() query.Node {
+	return query.Normalize(criteria.Node())
+}
+
+// Normalize returns the action whose category is canonicalized.
+func (action FilterAction) Normalize() FilterAction {
+	action.Category = CanonicalCategory(action.Category)
+	return action
+}
+
+// Conflicts returns the names of the fields which have different values
+// in the action and other, and cannot be applied together by one filter,
+// e.g. "important" for the actions marking important and never marking
+// important.
+func (action FilterAction) Conflicts(other FilterAction) []string {
+	action, other = action.Normalize(), other.Normalize()
+	var conflicts []string
+	if action.AddLabel != "" && other.AddLabel != "" && action.AddLabel != other.AddLabel {
+		conflicts = append(conflicts, "add_label")
+	}
+	if action.ForwardTo != "" && other.ForwardTo != "" && action.ForwardTo != other.ForwardTo {
+		conflicts = append(conflicts, "forward_to")
+	}
+	if action.Important != "" && other.Important != "" && action.Important != other.Important {
+		conflicts = append(conflicts, "important")
+	}
+	if action.Category != "" && other.Category != "" && action.Category != other.Category {
+		conflicts = append(conflicts, "category")
+	}
+	return conflicts
+}
+
+// Includes reports whether the action does everything other does.
+func (action FilterAction) Includes(other FilterAction) bool {
+	merged, err := action.Merge(other)
+	return err == nil && merged == action.Normalize()
+}
+
+// Merge returns an action which does everything both of the action and
+// other do. An error is returned if the actions conflict.
+func (action FilterAction) Merge(other FilterAction) (FilterAction, error) {
+	if conflicts := action.Conflicts(other); len(conflicts) > 0 {
+		return FilterAction{}, fmt.Errorf("conflicting actions: %s", strings.Join(conflicts, ", "))
+	}
+	action, other = action.Normalize(), other.Normalize()
+	merged := FilterAction{
+		Archive:         action.Archive || other.Archive,
+		MarkAsRead:      action.MarkAsRead || other.MarkAsRead,
+		Star:            action.Star || other.Star,
+		AddLabel:        action.AddLabel,
+		ForwardTo:       action.ForwardTo,
+		Delete:          action.Delete || other.Delete,
+		NeverMarkAsSpam: action.NeverMarkAsSpam || other.NeverMarkAsSpam,
+		Important:       action.Important,
+		Category:        action.Category,
+	}
+	if merged.AddLabel == "" {
+		merged.AddLabel = other.AddLabel
+	}
+	if merged.ForwardTo == "" {
+		merged.ForwardTo = other.ForwardTo
+	}
+	if merged.Important == "" {
+		merged.Important = other.Important
+	}
+	if merged.Category == "" {
+		merged.Category = other.Category
+	}
+	return merged, nil
+}
+
+// MergeFilters consolidates filters which have the same criteria and
+// compatible actions into one filter. The merged filter is placed at
+// the position of the first one, and the order of other filters is kept.
+func MergeFilters(filters []Filter) []Filter {
+	var merged []Filter
+	var keys []string
+	for _, f := range filters {
+		key := f.Criteria.Normalize().String()
+		found := false
+		for i := range merged {
+			if keys[i] != key {
+				continue
+			}
+			action, err := merged[i].Action.Merge(f.Action)
+			if err != nil {
+				continue
+			}
+			merged[i].Action = action
+			found = true
+			break
+		}
+		if !found {
+			merged = append(merged, f)
+			keys = append(keys, key)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,104 @@
+package gmail
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestFilterActionMerge(t *testing.T) {
+	tests := []struct {
+		label string
+		a, b  FilterAction
+		want  FilterAction
+		err   bool
+	}{
+		{
+			label: "compatible",
+			a:     FilterAction{AddLabel: "foo", Archive: true},
+			b:     FilterAction{Star: true, Category: "update"},
+			want:  FilterAction{AddLabel: "foo", Archive: true, Star: true, Category: "updates"},
+		},
+		{
+			label: "same label",
+			a:     FilterAction{AddLabel: "foo"},
+			b:     FilterAction{AddLabel: "foo", MarkAsRead: true},
+			want:  FilterAction{AddLabel: "foo", MarkAsRead: true},
+		},
+		{
+			label: "category alias",
+			a:     FilterAction{Category: "new"},
+			b:     FilterAction{Category: "updates"},
+			want:  FilterAction{Category: "updates"},
+		},
+		{
+			label: "different labels",
+			a:     FilterAction{AddLabel: "foo"},
+			b:     FilterAction{AddLabel: "bar"},
+			err:   true,
+		},
+		{
+			label: "important conflict",
+			a:     FilterAction{Important: FilterActionImportantAlways},
+			b:     FilterAction{Important: FilterActionImportantNever},
+			err:   true,
+		},
+	}
+	for i, tt := range tests {
+		got, err := tt.a.Merge(tt.b)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: error should be returned", strconv.Itoa(i)+"."+tt.label)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %+v", strconv.Itoa(i)+"."+tt.label, err)
+			return
+		}
+		if got != tt.want {
+			t.Errorf("%s: %+v != %+v", strconv.Itoa(i)+"."+tt.label, got, tt.want)
+			return
+		}
+	}
+}
+
+func TestMergeFilters(t *testing.T) {
+	filters := []Filter{
+		{
+			Criteria: FilterCriteria{From: Terms{"foo@example.com"}},
+			Action:   FilterAction{AddLabel: "foo"},
+		},
+		{
+			Criteria: FilterCriteria{Subject: Terms{"bar"}},
+			Action:   FilterAction{Star: true},
+		},
+		{
+			Criteria: FilterCriteria{Query: "from:Foo@example.com"},
+			Action:   FilterAction{Archive: true},
+		},
+		{
+			Criteria: FilterCriteria{From: Terms{"foo@example.com"}},
+			Action:   FilterAction{AddLabel: "baz"},
+		},
+	}
+	want := []Filter{
+		{
+			Criteria: FilterCriteria{From: Terms{"foo@example.com"}},
+			Action:   FilterAction{AddLabel: "foo", Archive: true},
+		},
+		{
+			Criteria: FilterCriteria{Subject: Terms{"bar"}},
+			Action:   FilterAction{Star: true},
+		},
+		{
+			Criteria: FilterCriteria{From: Terms{"foo@example.com"}},
+			Action:   FilterAction{AddLabel: "baz"},
+		},
+	}
+	got := MergeFilters(filters)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%+v != %+v", got, want)
+		return
+	}
+}
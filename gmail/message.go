@@ -0,0 +1,34 @@
+package gmail
+
+import (
+	"context"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// CountMessages returns the number of messages matching given query.
+// If exact is false, the count is the estimation returned by Gmail API,
+// which needs only one API call. Otherwise, all pages of the result
+// are fetched to count messages exactly.
+func (c *Client) CountMessages(ctx context.Context, q string, exact bool) (int64, error) {
+	call := c.svc.Users.Messages.List("me").Q(q)
+	if !exact {
+		resp, err := call.MaxResults(1).Fields("resultSizeEstimate").Context(ctx).Do()
+		if err != nil {
+			return 0, err
+		}
+		return resp.ResultSizeEstimate, nil
+	}
+
+	var count int64
+	if err := call.MaxResults(500).Fields("messages/id", "nextPageToken").Pages(
+		ctx,
+		func(resp *gmail.ListMessagesResponse) error {
+			count += int64(len(resp.Messages))
+			return nil
+		},
+	); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,75 @@
+package gmail
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+func TestCountMessages(t *testing.T) {
+	const query = "from:foo@example.com"
+
+	oauthSrv, oauthCfg, oauthToken := testOAuth(t)
+	defer oauthSrv.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/labels") {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(labelListResponseBody))
+			return
+		}
+		if got := r.FormValue("q"); got != query {
+			t.Errorf("unexpected query: %s != %s", got, query)
+		}
+		w.WriteHeader(http.StatusOK)
+		switch r.FormValue("pageToken") {
+		case "":
+			w.Write([]byte(`{"messages": [{"id": "1"}, {"id": "2"}], "nextPageToken": "next", "resultSizeEstimate": 201}`))
+		case "next":
+			w.Write([]byte(`{"messages": [{"id": "3"}], "resultSizeEstimate": 201}`))
+		default:
+			t.Errorf("unexpected page token: %s", r.FormValue("pageToken"))
+		}
+	}))
+	defer srv.Close()
+
+	defer func(orig func(context.Context, ...option.ClientOption) (*gmail.Service, error)) {
+		newGmailService = orig
+	}(newGmailService)
+	newGmailService = func(ctx context.Context, opts ...option.ClientOption) (*gmail.Service, error) {
+		opts = append(opts, option.WithEndpoint(srv.URL))
+		return gmail.NewService(ctx, opts...)
+	}
+
+	ctx := context.Background()
+	c, err := New(ctx, oauthCfg, oauthToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("estimated", func(t *testing.T) {
+		got, err := c.CountMessages(ctx, query, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != 201 {
+			t.Errorf("unexpected count: %d != %d", got, 201)
+			return
+		}
+	})
+	t.Run("exact", func(t *testing.T) {
+		got, err := c.CountMessages(ctx, query, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != 3 {
+			t.Errorf("unexpected count: %d != %d", got, 3)
+			return
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package gmail
+
+import (
+	"sort"
+
+	"github.com/nasa9084/gmac/schema"
+)
+
+// Categories returns the names of categories which can be used in
+// action.category, including aliases.
+func Categories() []string {
+	var names []string
+	for name := range categoryLabelIDs {
+		names = append(names, name)
+	}
+	for alias := range categoryAliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// JSONSchema describes Terms, which is either a string or a list of strings.
+func (Terms) JSONSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "a search term, or a list of literal values joined with OR",
+		OneOf: []*schema.Schema{
+			{Type: "string"},
+			{Type: "array", Items: &schema.Schema{Type: "string"}},
+		},
+	}
+}
+
+// JSONSchema describes the values of FilterActionImportant.
+func (FilterActionImportant) JSONSchema() *schema.Schema {
+	return &schema.Schema{
+		Type: "string",
+		Enum: []interface{}{string(FilterActionImportantAlways), string(FilterActionImportantNever)},
+	}
+}
+
+// JSONSchemaExtend adds the constraints of the sizes.
+func (FilterCriteria) JSONSchemaExtend(s *schema.Schema) {
+	s.Properties["larger_than"].Minimum = schema.Float(0)
+	s.Properties["smaller_than"].Minimum = schema.Float(0)
+	s.Not = &schema.Schema{
+		Description: "larger_than and smaller_than cannot be used together",
+		Required:    []string{"larger_than", "smaller_than"},
+	}
+}
+
+// JSONSchemaExtend adds the categories.
+func (FilterAction) JSONSchemaExtend(s *schema.Schema) {
+	s.Properties["category"].Enum = categoryEnum()
+}
+
+// JSONSchemaExtend adds the categories.
+func (FilterExpectation) JSONSchemaExtend(s *schema.Schema) {
+	s.Properties["category"].Enum = categoryEnum()
+}
+
+// JSONSchemaExtend adds the constraint of the size.
+func (TestMessage) JSONSchemaExtend(s *schema.Schema) {
+	s.Properties["size"].Minimum = schema.Float(0)
+}
+
+func categoryEnum() []interface{} {
+	var enum []interface{}
+	for _, name := range Categories() {
+		enum = append(enum, name)
+	}
+	return enum
+}
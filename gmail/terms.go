@@ -0,0 +1,116 @@
+package gmail
+
+import (
+	"strings"
+
+	"github.com/nasa9084/gmac/query"
+)
+
+// Terms is a list of search terms for a criteria field such as from,
+// to or subject. In YAML it can be written either as a plain string or
+// as a list of strings. A plain string is passed to Gmail as-is, so it
+// may contain any Gmail search syntax. Each element of a list is
+// treated as a literal value: it is quoted if needed and the elements
+// are joined with OR. A single term holds a plain string, so a list of
+// one element is held as the quoted element.
+type Terms []string
+
+// LiteralTerms returns the terms matching any of given literal values.
+// The value is quoted if needed even if only one value is given.
+func LiteralTerms(values ...string) Terms {
+	switch len(values) {
+	case 0:
+		return nil
+	case 1:
+		if values[0] == "" {
+			return nil
+		}
+		return Terms{query.Term(values[0]).String()}
+	}
+	return Terms(values)
+}
+
+// UnmarshalYAML accepts both a string and a list of strings.
+func (t *Terms) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		*t = LiteralTerms(list...)
+		return nil
+	}
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	if s == "" {
+		*t = nil
+		return nil
+	}
+	*t = Terms{s}
+	return nil
+}
+
+// MarshalYAML marshals single term as a plain string, and two or more
+// terms as a list of strings.
+func (t Terms) MarshalYAML() (interface{}, error) {
+	if len(t) == 1 {
+		return t[0], nil
+	}
+	return []string(t), nil
+}
+
+func termsOf(s string) Terms {
+	if s == "" {
+		return nil
+	}
+	return Terms{s}
+}
+
+// Query returns Gmail search expression of the terms.
+func (t Terms) Query() string {
+	switch len(t) {
+	case 0:
+		return ""
+	case 1:
+		return t[0]
+	}
+	qs := make([]string, 0, len(t))
+	for _, term := range t {
+		if term == "" {
+			continue
+		}
+		qs = append(qs, query.Term(term).String())
+	}
+	return strings.Join(qs, " OR ")
+}
+
+// Node returns the syntax tree of the terms.
+func (t Terms) Node() query.Node {
+	switch len(t) {
+	case 0:
+		return nil
+	case 1:
+		return parseQuery(t[0])
+	}
+	nodes := make([]query.Node, 0, len(t))
+	for _, term := range t {
+		if term == "" {
+			continue
+		}
+		nodes = append(nodes, query.Term(term))
+	}
+	return query.NewOr(nodes...)
+}
+
+// parseQuery parses given search query. if the query cannot be parsed,
+// parseQuery returns a word node which holds the query as-is, as Gmail
+// accepts such query anyway.
+func parseQuery(s string) query.Node {
+	if s == "" {
+		return nil
+	}
+	n, err := query.Parse(s)
+	if err != nil {
+		return query.Word{Value: s}
+	}
+	return n
+}
@@ -0,0 +1,133 @@
+package gmail
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+func TestTermsUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		label string
+		input string
+		want  Terms
+	}{
+		{
+			label: "plain string",
+			input: `from: foo@example.com`,
+			want:  Terms{"foo@example.com"},
+		},
+		{
+			label: "list of strings",
+			input: `from: [foo@example.com, bar@example.com]`,
+			want:  Terms{"foo@example.com", "bar@example.com"},
+		},
+		{
+			label: "block style list",
+			input: "from:\n  - foo@example.com\n  - John Doe\n",
+			want:  Terms{"foo@example.com", "John Doe"},
+		},
+		{
+			label: "plain string is not quoted",
+			input: `from: John Doe`,
+			want:  Terms{"John Doe"},
+		},
+		{
+			label: "list of one string is quoted as well as longer lists",
+			input: `from: [John Doe]`,
+			want:  Terms{`"John Doe"`},
+		},
+		{
+			label: "list of one string without special characters",
+			input: `from: [foo@example.com]`,
+			want:  Terms{"foo@example.com"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			var got FilterCriteria
+			if err := yaml.Unmarshal([]byte(tt.input), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.From, tt.want) {
+				t.Errorf("unexpected terms: %#v != %#v", got.From, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestTermsMarshalYAML(t *testing.T) {
+	tests := []struct {
+		label string
+		input FilterCriteria
+		want  string
+	}{
+		{
+			label: "single term",
+			input: FilterCriteria{From: Terms{"foo@example.com"}},
+			want:  "from: foo@example.com\n",
+		},
+		{
+			label: "multiple terms",
+			input: FilterCriteria{From: Terms{"foo@example.com", "bar@example.com"}},
+			want:  "from:\n- foo@example.com\n- bar@example.com\n",
+		},
+		{
+			label: "empty",
+			input: FilterCriteria{Query: "foo"},
+			want:  "query: foo\n",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			got, err := yaml.Marshal(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("unexpected YAML:\n  got:  %q\n  want: %q", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestTermsQuery(t *testing.T) {
+	tests := []struct {
+		label string
+		input Terms
+		want  string
+	}{
+		{
+			label: "empty",
+			input: nil,
+			want:  "",
+		},
+		{
+			label: "single term is passed as-is",
+			input: Terms{"foo@example.com OR bar@example.com"},
+			want:  "foo@example.com OR bar@example.com",
+		},
+		{
+			label: "multiple terms",
+			input: Terms{"foo@example.com", "bar@example.com"},
+			want:  "foo@example.com OR bar@example.com",
+		},
+		{
+			label: "terms with spaces and special characters",
+			input: Terms{"John Doe", `say "hello"`, "OR", "-foo", "{bar}"},
+			want:  `"John Doe" OR "say hello" OR "OR" OR "-foo" OR "{bar}"`,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			if got := tt.input.Query(); got != tt.want {
+				t.Errorf("unexpected query: %s != %s", got, tt.want)
+				return
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package gmail
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mailFiltersFeed is the Atom feed of mailFilters.xml, which is exported
+// from Gmail settings.
+type mailFiltersFeed struct {
+	XMLName xml.Name          `xml:"http://www.w3.org/2005/Atom feed"`
+	Entries []mailFilterEntry `xml:"entry"`
+}
+
+type mailFilterEntry struct {
+	Properties []mailFilterProperty `xml:"http://schemas.google.com/apps/2006 property"`
+}
+
+type mailFilterProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// smartLabels maps smart labels in mailFilters.xml to categories.
+var smartLabels = map[string]string{
+	"^smartlabel_personal":     "primary",
+	"^smartlabel_social":       "social",
+	"^smartlabel_notification": "updates",
+	"^smartlabel_group":        "forums",
+	"^smartlabel_promo":        "promotions",
+}
+
+// sizeUnits maps size units in mailFilters.xml to the number of bytes.
+var sizeUnits = map[string]int64{
+	"s_sb":  1,
+	"s_skb": 1 << 10,
+	"s_smb": 1 << 20,
+}
+
+// ReadFiltersXML reads filters from mailFilters.xml, which is exported
+// from Gmail settings. Properties which cannot be represented as Filter
+// are returned as warnings.
+func ReadFiltersXML(r io.Reader) (filters []Filter, warnings []string, err error) {
+	var feed mailFiltersFeed
+	if err := xml.NewDecoder(r).Decode(&feed); err != nil {
+		return nil, nil, err
+	}
+	for i, entry := range feed.Entries {
+		f, w, err := convertFilterFromXML(entry)
+		if err != nil {
+			return nil, nil, fmt.Errorf("filter #%d: %w", i+1, err)
+		}
+		for _, msg := range w {
+			warnings = append(warnings, fmt.Sprintf("filter #%d: %s", i+1, msg))
+		}
+		filters = append(filters, f)
+	}
+	return filters, warnings, nil
+}
+
+func convertFilterFromXML(entry mailFilterEntry) (Filter, []string, error) {
+	var f Filter
+	var warnings []string
+	var size int64
+	sizeOperator, sizeUnit := "s_sl", "s_sb"
+	for _, prop := range entry.Properties {
+		v := prop.Value
+		switch prop.Name {
+		// criteria
+		case "from":
+			f.Criteria.From = termsOf(v)
+		case "to":
+			f.Criteria.To = termsOf(v)
+		case "subject":
+			f.Criteria.Subject = termsOf(v)
+		case "hasTheWord":
+			f.Criteria.Query = v
+		case "doesNotHaveTheWord":
+			f.Criteria.NegatedQuery = v
+		case "hasAttachment":
+			f.Criteria.HasAttachment = v == "true"
+		case "excludeChats":
+			f.Criteria.ExcludeChats = v == "true"
+		case "size":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return f, nil, fmt.Errorf("invalid size: %s", v)
+			}
+			size = n
+		case "sizeOperator":
+			sizeOperator = v
+		case "sizeUnit":
+			sizeUnit = v
+		// actions
+		case "label":
+			f.Action.AddLabel = v
+		case "shouldArchive":
+			f.Action.Archive = v == "true"
+		case "shouldMarkAsRead":
+			f.Action.MarkAsRead = v == "true"
+		case "shouldStar":
+			f.Action.Star = v == "true"
+		case "shouldTrash":
+			f.Action.Delete = v == "true"
+		case "shouldNeverSpam":
+			f.Action.NeverMarkAsSpam = v == "true"
+		case "shouldAlwaysMarkAsImportant":
+			if v == "true" {
+				f.Action.Important = FilterActionImportantAlways
+			}
+		case "shouldNeverMarkAsImportant":
+			if v == "true" {
+				f.Action.Important = FilterActionImportantNever
+			}
+		case "smartLabelToApply":
+			category, ok := smartLabels[v]
+			if !ok {
+				warnings = append(warnings, "unknown smart label is ignored: "+v)
+				continue
+			}
+			f.Action.Category = category
+		case "forwardTo":
+			f.Action.ForwardTo = v
+		default:
+			warnings = append(warnings, fmt.Sprintf("unknown property is ignored: %s=%s", prop.Name, v))
+		}
+	}
+	if size > 0 {
+		unit, ok := sizeUnits[sizeUnit]
+		if !ok {
+			return f, nil, fmt.Errorf("unknown size unit: %s", sizeUnit)
+		}
+		switch sizeOperator {
+		case "s_sl":
+			f.Criteria.LargerThan = size * unit
+		case "s_ss":
+			f.Criteria.SmallerThan = size * unit
+		default:
+			return f, nil, fmt.Errorf("unknown size operator: %s", sizeOperator)
+		}
+	}
+	return f, warnings, nil
+}
+
+// xmlNow returns the current time, which is written as the updated time
+// of mailFilters.xml.
+var xmlNow = time.Now
+
+// WriteFiltersXML writes filters in the format of mailFilters.xml, which
+// can be imported from Gmail settings.
+func WriteFiltersXML(w io.Writer, filters []Filter) error {
+	updated := xmlNow().UTC().Format(time.RFC3339)
+	var b strings.Builder
+	b.WriteString("<?xml version='1.0' encoding='UTF-8'?>")
+	b.WriteString("<feed xmlns='http://www.w3.org/2005/Atom' xmlns:apps='http://schemas.google.com/apps/2006'>\n")
+	b.WriteString("\t<title>Mail Filters</title>\n")
+	fmt.Fprintf(&b, "\t<id>tag:mail.google.com,2008:filters:%s</id>\n", xmlFilterIDs(len(filters)))
+	fmt.Fprintf(&b, "\t<updated>%s</updated>\n", updated)
+	for i, f := range filters {
+		props, err := convertFilterToXML(f)
+		if err != nil {
+			return fmt.Errorf("filter #%d: %w", i+1, err)
+		}
+		b.WriteString("\t<entry>\n")
+		b.WriteString("\t\t<category term='filter'></category>\n")
+		b.WriteString("\t\t<title>Mail Filter</title>\n")
+		fmt.Fprintf(&b, "\t\t<id>tag:mail.google.com,2008:filter:%s</id>\n", xmlFilterID(i))
+		fmt.Fprintf(&b, "\t\t<updated>%s</updated>\n", updated)
+		b.WriteString("\t\t<content></content>\n")
+		for _, prop := range props {
+			fmt.Fprintf(&b, "\t\t<apps:property name='%s' value='%s'/>\n", escapeXMLAttr(prop.Name), escapeXMLAttr(prop.Value))
+		}
+		b.WriteString("\t</entry>\n")
+	}
+	b.WriteString("</feed>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func xmlFilterID(i int) string {
+	return fmt.Sprintf("z%019d", i+1)
+}
+
+func xmlFilterIDs(n int) string {
+	ids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		ids = append(ids, xmlFilterID(i))
+	}
+	return strings.Join(ids, ",")
+}
+
+func escapeXMLAttr(s string) string {
+	var b strings.Builder
+	// EscapeText never fails when writing to strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func convertFilterToXML(f Filter) ([]mailFilterProperty, error) {
+	var props []mailFilterProperty
+	add := func(name, value string) {
+		if value != "" {
+			props = append(props, mailFilterProperty{Name: name, Value: value})
+		}
+	}
+	addBool := func(name string, value bool) {
+		if value {
+			add(name, "true")
+		}
+	}
+
+	// criteria
+	add("from", f.Criteria.From.Query())
+	add("to", f.Criteria.To.Query())
+	add("subject", f.Criteria.Subject.Query())
+	add("hasTheWord", f.Criteria.gmailQuery())
+	add("doesNotHaveTheWord", f.Criteria.NegatedQuery)
+	addBool("hasAttachment", f.Criteria.HasAttachment)
+	addBool("excludeChats", f.Criteria.ExcludeChats)
+	if size, operator := f.Criteria.LargerThan, "s_sl"; size > 0 || f.Criteria.SmallerThan > 0 {
+		if size == 0 {
+			size, operator = f.Criteria.SmallerThan, "s_ss"
+		}
+		unit := "s_sb"
+		switch {
+		case size%(1<<20) == 0:
+			size, unit = size>>20, "s_smb"
+		case size%(1<<10) == 0:
+			size, unit = size>>10, "s_skb"
+		}
+		add("size", strconv.FormatInt(size, 10))
+		add("sizeOperator", operator)
+		add("sizeUnit", unit)
+	}
+
+	// actions
+	add("label", f.Action.AddLabel)
+	addBool("shouldArchive", f.Action.Archive)
+	addBool("shouldMarkAsRead", f.Action.MarkAsRead)
+	addBool("shouldStar", f.Action.Star)
+	addBool("shouldTrash", f.Action.Delete)
+	addBool("shouldNeverSpam", f.Action.NeverMarkAsSpam)
+	switch f.Action.Important {
+	case "":
+	case FilterActionImportantAlways:
+		add("shouldAlwaysMarkAsImportant", "true")
+	case FilterActionImportantNever:
+		add("shouldNeverMarkAsImportant", "true")
+	default:
+		return nil, fmt.Errorf("unknown action.important value: %s", f.Action.Important)
+	}
+	if f.Action.Category != "" {
+		smartLabel := ""
+		for label, category := range smartLabels {
+			if category == CanonicalCategory(f.Action.Category) {
+				smartLabel = label
+			}
+		}
+		if smartLabel == "" {
+			return nil, fmt.Errorf("unknown action.category value: %s", f.Action.Category)
+		}
+		add("smartLabelToApply", smartLabel)
+	}
+	add("forwardTo", f.Action.ForwardTo)
+	return props, nil
+}
@@ -0,0 +1,193 @@
+package gmail
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const mailFiltersXML = `<?xml version='1.0' encoding='UTF-8'?><feed xmlns='http://www.w3.org/2005/Atom' xmlns:apps='http://schemas.google.com/apps/2006'>
+	<title>Mail Filters</title>
+	<id>tag:mail.google.com,2008:filters:z0000001590000000000*1234567890</id>
+	<updated>2020-07-01T00:00:00Z</updated>
+	<author>
+		<name>Foo</name>
+		<email>foo@example.com</email>
+	</author>
+	<entry>
+		<category term='filter'></category>
+		<title>Mail Filter</title>
+		<id>tag:mail.google.com,2008:filter:z0000001590000000000*1234567890</id>
+		<updated>2020-07-01T00:00:00Z</updated>
+		<content></content>
+		<apps:property name='from' value='ci@example.com'/>
+		<apps:property name='hasTheWord' value='subject:(build failed)'/>
+		<apps:property name='label' value='CI/Failed'/>
+		<apps:property name='shouldArchive' value='true'/>
+		<apps:property name='shouldAlwaysMarkAsImportant' value='true'/>
+		<apps:property name='sizeOperator' value='s_sl'/>
+		<apps:property name='sizeUnit' value='s_smb'/>
+	</entry>
+	<entry>
+		<category term='filter'></category>
+		<title>Mail Filter</title>
+		<id>tag:mail.google.com,2008:filter:z0000001590000000001*1234567890</id>
+		<updated>2020-07-01T00:00:00Z</updated>
+		<content></content>
+		<apps:property name='doesNotHaveTheWord' value='unsubscribe'/>
+		<apps:property name='size' value='5'/>
+		<apps:property name='sizeOperator' value='s_ss'/>
+		<apps:property name='sizeUnit' value='s_smb'/>
+		<apps:property name='smartLabelToApply' value='^smartlabel_notification'/>
+		<apps:property name='shouldNeverSpam' value='true'/>
+		<apps:property name='shouldTrash' value='false'/>
+		<apps:property name='shouldDoSomething' value='true'/>
+	</entry>
+</feed>
+`
+
+func TestReadFiltersXML(t *testing.T) {
+	filters, warnings, err := ReadFiltersXML(strings.NewReader(mailFiltersXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Filter{
+		{
+			Criteria: FilterCriteria{
+				From:  Terms{"ci@example.com"},
+				Query: "subject:(build failed)",
+			},
+			Action: FilterAction{
+				AddLabel:  "CI/Failed",
+				Archive:   true,
+				Important: FilterActionImportantAlways,
+			},
+		},
+		{
+			Criteria: FilterCriteria{
+				NegatedQuery: "unsubscribe",
+				SmallerThan:  5 << 20,
+			},
+			Action: FilterAction{
+				Category:        "updates",
+				NeverMarkAsSpam: true,
+			},
+		},
+	}
+	if !reflect.DeepEqual(filters, want) {
+		t.Errorf("%+v != %+v", filters, want)
+		return
+	}
+	wantWarnings := []string{"filter #2: unknown property is ignored: shouldDoSomething=true"}
+	if !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("%q != %q", warnings, wantWarnings)
+		return
+	}
+}
+
+func TestWriteFiltersXML(t *testing.T) {
+	xmlNow = func() time.Time { return time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { xmlNow = time.Now }()
+
+	tests := []struct {
+		label  string
+		filter Filter
+		want   Filter
+	}{
+		{
+			label: "simple",
+			filter: Filter{
+				Criteria: FilterCriteria{
+					From:          Terms{"ci@example.com"},
+					Query:         "subject:(build failed)",
+					HasAttachment: true,
+					LargerThan:    2 << 20,
+				},
+				Action: FilterAction{
+					AddLabel:  "CI/Failed",
+					Archive:   true,
+					Important: FilterActionImportantAlways,
+				},
+			},
+		},
+		{
+			label: "terms and nested criteria are compiled",
+			filter: Filter{
+				Criteria: FilterCriteria{
+					From:  Terms{"a@example.com", "b@example.com"},
+					AnyOf: []FilterCriteria{{Subject: Terms{"foo"}}, {Subject: Terms{"bar"}}},
+				},
+				Action: FilterAction{Star: true},
+			},
+			want: Filter{
+				Criteria: FilterCriteria{
+					From:  Terms{"a@example.com OR b@example.com"},
+					Query: "{subject:foo subject:bar}",
+				},
+				Action: FilterAction{Star: true},
+			},
+		},
+		{
+			label: "category alias and escaped value",
+			filter: Filter{
+				Criteria: FilterCriteria{
+					Subject:     Terms{`"<News & Events>"`},
+					SmallerThan: 1500,
+				},
+				Action: FilterAction{
+					Category:        "new",
+					NeverMarkAsSpam: true,
+					Delete:          true,
+				},
+			},
+			want: Filter{
+				Criteria: FilterCriteria{
+					Subject:     Terms{`"<News & Events>"`},
+					SmallerThan: 1500,
+				},
+				Action: FilterAction{
+					Category:        "updates",
+					NeverMarkAsSpam: true,
+					Delete:          true,
+				},
+			},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteFiltersXML(&buf, []Filter{tt.filter}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			got, warnings, err := ReadFiltersXML(&buf)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if len(warnings) > 0 {
+				t.Errorf("unexpected warnings: %q", warnings)
+				return
+			}
+			want := tt.want
+			if reflect.DeepEqual(want, Filter{}) {
+				want = tt.filter
+			}
+			if !reflect.DeepEqual(got, []Filter{want}) {
+				t.Errorf("%+v != %+v", got, []Filter{want})
+				return
+			}
+		})
+	}
+}
+
+func TestWriteFiltersXMLError(t *testing.T) {
+	err := WriteFiltersXML(&bytes.Buffer{}, []Filter{{Action: FilterAction{Category: "unknown"}}})
+	if err == nil || err.Error() != "filter #1: unknown action.category value: unknown" {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+}
@@ -8,11 +8,12 @@ require (
 	github.com/golang/protobuf v1.4.0 // indirect
 	github.com/google/uuid v1.1.1
 	github.com/jessevdk/go-flags v1.4.0
-	github.com/nasa9084/go-pageloop v0.0.0-20200701125038-a7e9987235de // indirect
+	github.com/nasa9084/go-pageloop v0.0.0-20200701125038-a7e9987235de
 	github.com/spf13/afero v1.2.2
 	golang.org/x/net v0.0.0-20200425230154-ff2c4b7c35a0 // indirect
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
-	golang.org/x/sys v0.0.0-20200427175716-29b57079015a // indirect
+	golang.org/x/sys v0.0.0-20200427175716-29b57079015a
+	golang.org/x/text v0.3.2
 	google.golang.org/api v0.22.0
 	google.golang.org/appengine v1.6.6 // indirect
 	google.golang.org/genproto v0.0.0-20200424135956-bca184e23272 // indirect
@@ -0,0 +1,113 @@
+// Package lint checks filter definitions for common mistakes.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+// Severity is the severity of a problem.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Rule is a lint rule.
+type Rule struct {
+	// Name identifies the rule, which is used to disable the rule.
+	Name        string
+	Description string
+	Severity    Severity
+	// DisabledByDefault is true if the rule is run only when it is
+	// enabled explicitly.
+	DisabledByDefault bool
+
+	check func(filters []gmail.Filter, report reportFunc)
+}
+
+// reportFunc reports a problem of the filter at given index.
+// path is the path to the problematic field in the filter,
+// e.g. []string{"criteria", "larger_than"}.
+type reportFunc func(index int, path []string, format string, args ...interface{})
+
+// Problem is a problem found by a rule.
+type Problem struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	// Filter is the index of the filter in the resource.
+	Filter int `json:"filter"`
+	// Path is the path to the problematic field in the filter.
+	Path    []string `json:"path,omitempty"`
+	Message string   `json:"message"`
+}
+
+// Rules returns all lint rules, sorted by the name.
+func Rules() []*Rule {
+	sorted := make([]*Rule, len(rulesSortedByName))
+	copy(sorted, rulesSortedByName)
+	return sorted
+}
+
+// Lint checks filters with the rules enabled by default and the enabled
+// ones, except disabled ones. The problems are sorted by the index of
+// the filter.
+func Lint(filters []gmail.Filter, enabled, disabled []string) ([]Problem, error) {
+	enabledSet := map[string]bool{}
+	for _, name := range enabled {
+		if _, ok := ruleMap[name]; !ok {
+			return nil, fmt.Errorf("unknown rule: %s", name)
+		}
+		enabledSet[name] = true
+	}
+	disabledSet := map[string]bool{}
+	for _, name := range disabled {
+		if _, ok := ruleMap[name]; !ok {
+			return nil, fmt.Errorf("unknown rule: %s", name)
+		}
+		disabledSet[name] = true
+	}
+
+	var problems []Problem
+	for _, rule := range rulesSortedByName {
+		if disabledSet[rule.Name] || (rule.DisabledByDefault && !enabledSet[rule.Name]) {
+			continue
+		}
+		rule := rule
+		rule.check(filters, func(index int, path []string, format string, args ...interface{}) {
+			problems = append(problems, Problem{
+				Rule:     rule.Name,
+				Severity: rule.Severity,
+				Filter:   index,
+				Path:     path,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		})
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Filter != problems[j].Filter {
+			return problems[i].Filter < problems[j].Filter
+		}
+		return strings.Join(problems[i].Path, ".") < strings.Join(problems[j].Path, ".")
+	})
+	return problems, nil
+}
+
+var (
+	ruleMap           = map[string]*Rule{}
+	rulesSortedByName []*Rule
+)
+
+func init() {
+	for _, rule := range rules {
+		ruleMap[rule.Name] = rule
+		rulesSortedByName = append(rulesSortedByName, rule)
+	}
+	sort.Slice(rulesSortedByName, func(i, j int) bool {
+		return rulesSortedByName[i].Name < rulesSortedByName[j].Name
+	})
+}
@@ -0,0 +1,327 @@
+package lint
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		label   string
+		filters []gmail.Filter
+		want    []string // rule names of problems
+	}{
+		{
+			label: "no problem",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+					Action:   gmail.FilterAction{AddLabel: "foo"},
+				},
+			},
+		},
+		{
+			label: "size comparison",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{LargerThan: 10, SmallerThan: 100},
+					Action:   gmail.FilterAction{Archive: true},
+				},
+			},
+			want: []string{"size-comparison"},
+		},
+		{
+			label: "delete with actions",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+					Action:   gmail.FilterAction{Delete: true, Star: true},
+				},
+			},
+			want: []string{"delete-with-actions"},
+		},
+		{
+			label: "forward to",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+					Action:   gmail.FilterAction{ForwardTo: "bar@example.com"},
+				},
+			},
+			want: []string{"forward-to"},
+		},
+		{
+			label: "empty criteria and action",
+			filters: []gmail.Filter{
+				{},
+			},
+			want: []string{"empty-action", "empty-criteria"},
+		},
+		{
+			label: "narrower criteria with different actions",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+					Action:   gmail.FilterAction{AddLabel: "foo"},
+				},
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}, Subject: gmail.Terms{"bar"}},
+					Action:   gmail.FilterAction{Star: true},
+				},
+			},
+		},
+		{
+			label: "duplicate filter",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"Foo@example.com"}, Subject: gmail.Terms{"bar"}},
+					Action:   gmail.FilterAction{Category: "update"},
+				},
+				{
+					Criteria: gmail.FilterCriteria{Query: "subject:bar from:foo@example.com"},
+					Action:   gmail.FilterAction{Category: "updates"},
+				},
+			},
+			want: []string{"duplicate-filter"},
+		},
+		{
+			label: "conflicting actions",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+					Action:   gmail.FilterAction{Important: gmail.FilterActionImportantAlways},
+				},
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+					Action:   gmail.FilterAction{Important: gmail.FilterActionImportantNever},
+				},
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}, HasAttachment: true},
+					Action:   gmail.FilterAction{Category: "social"},
+				},
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}, HasAttachment: true},
+					Action:   gmail.FilterAction{Category: "forums"},
+				},
+			},
+			want: []string{"conflicting-actions", "conflicting-actions"},
+		},
+		{
+			label: "mergeable filters",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+					Action:   gmail.FilterAction{AddLabel: "foo"},
+				},
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+					Action:   gmail.FilterAction{Archive: true},
+				},
+			},
+			want: []string{"mergeable-filters"},
+		},
+		{
+			label: "subsumed filter",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}, Subject: gmail.Terms{"bar"}},
+					Action:   gmail.FilterAction{Archive: true},
+				},
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+					Action:   gmail.FilterAction{Archive: true, Star: true},
+				},
+			},
+			want: []string{"subsumed-filter"},
+		},
+		{
+			label: "invalid important and category",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+					Action:   gmail.FilterAction{Important: "sometimes", Category: "spam"},
+				},
+			},
+			want: []string{"invalid-category", "invalid-important"},
+		},
+		{
+			label: "category alias",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+					Action:   gmail.FilterAction{Category: "updates"},
+				},
+			},
+		},
+		{
+			label: "label case",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+					Action:   gmail.FilterAction{AddLabel: "CI"},
+				},
+				{
+					Criteria: gmail.FilterCriteria{From: gmail.Terms{"bar@example.com"}},
+					Action:   gmail.FilterAction{AddLabel: "ci"},
+				},
+			},
+			want: []string{"label-case"},
+		},
+		{
+			label: "query syntax",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{Query: "(foo bar"},
+					Action:   gmail.FilterAction{Star: true},
+				},
+			},
+			want: []string{"query-syntax"},
+		},
+		{
+			label: "unknown operator",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{Query: "lable:foo https://example.com"},
+					Action:   gmail.FilterAction{Star: true},
+				},
+			},
+			want: []string{"unknown-operator"},
+		},
+		{
+			label: "nested query",
+			filters: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{AnyOf: []gmail.FilterCriteria{{Query: "foo"}, {Query: "{bar"}}},
+					Action:   gmail.FilterAction{Star: true},
+				},
+			},
+			want: []string{"query-syntax"},
+		},
+	}
+	for i, tt := range tests {
+		problems, err := Lint(tt.filters, nil, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %+v", strconv.Itoa(i)+"."+tt.label, err)
+			return
+		}
+		var got []string
+		for _, p := range problems {
+			got = append(got, p.Rule)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v != %v", strconv.Itoa(i)+"."+tt.label, got, tt.want)
+			return
+		}
+	}
+}
+
+func TestLintProblem(t *testing.T) {
+	filters := []gmail.Filter{
+		{
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+			Action:   gmail.FilterAction{Star: true},
+		},
+		{
+			Criteria: gmail.FilterCriteria{AllOf: []gmail.FilterCriteria{{Query: "foo"}, {Query: "(bar"}}},
+			Action:   gmail.FilterAction{Star: true},
+		},
+	}
+	problems, err := Lint(filters, nil, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %+v", err)
+		return
+	}
+	want := []Problem{
+		{
+			Rule:     "query-syntax",
+			Severity: SeverityError,
+			Filter:   1,
+			Path:     []string{"criteria", "all_of", "1", "query"},
+			Message:  `query: unclosed bracket at offset 0 in "(bar"`,
+		},
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("%+v != %+v", problems, want)
+		return
+	}
+}
+
+func TestLintDisable(t *testing.T) {
+	filters := []gmail.Filter{{}}
+	problems, err := Lint(filters, nil, []string{"empty-criteria", "empty-action"})
+	if err != nil {
+		t.Errorf("unexpected error: %+v", err)
+		return
+	}
+	if len(problems) != 0 {
+		t.Errorf("unexpected problems: %+v", problems)
+		return
+	}
+
+	if _, err := Lint(filters, nil, []string{"no-such-rule"}); err == nil {
+		t.Error("error should be returned for unknown rule")
+		return
+	}
+}
+
+func TestLintEnable(t *testing.T) {
+	filters := []gmail.Filter{
+		{
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+			Action:   gmail.FilterAction{AddLabel: "foo"},
+		},
+		{
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}, Subject: gmail.Terms{"bar"}},
+			Action:   gmail.FilterAction{Star: true},
+		},
+		{
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}, Subject: gmail.Terms{"baz"}},
+			Action:   gmail.FilterAction{AddLabel: "foo"},
+		},
+	}
+	tests := []struct {
+		label    string
+		enabled  []string
+		disabled []string
+		want     []string // rule names of problems
+	}{
+		{
+			label: "disabled by default",
+			want:  []string{"subsumed-filter"},
+		},
+		{
+			label:   "enabled",
+			enabled: []string{"overlapping-criteria"},
+			want:    []string{"overlapping-criteria", "subsumed-filter"},
+		},
+		{
+			label:    "enabled and disabled",
+			enabled:  []string{"overlapping-criteria"},
+			disabled: []string{"overlapping-criteria"},
+			want:     []string{"subsumed-filter"},
+		},
+	}
+	for i, tt := range tests {
+		problems, err := Lint(filters, tt.enabled, tt.disabled)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %+v", strconv.Itoa(i)+"."+tt.label, err)
+			return
+		}
+		var got []string
+		for _, p := range problems {
+			got = append(got, p.Rule)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v != %v", strconv.Itoa(i)+"."+tt.label, got, tt.want)
+			return
+		}
+	}
+
+	if _, err := Lint(filters, []string{"no-such-rule"}, nil); err == nil {
+		t.Error("error should be returned for unknown rule")
+		return
+	}
+}
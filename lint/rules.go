@@ -0,0 +1,421 @@
+package lint
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/query"
+)
+
+var rules = []*Rule{
+	{
+		Name:        "size-comparison",
+		Description: "larger_than and smaller_than cannot be used together; smaller_than is ignored",
+		Severity:    SeverityError,
+		check:       checkSizeComparison,
+	},
+	{
+		Name:        "delete-with-actions",
+		Description: "delete is combined with other actions",
+		Severity:    SeverityWarning,
+		check:       checkDeleteWithActions,
+	},
+	{
+		Name:        "forward-to",
+		Description: "messages are forwarded to another address",
+		Severity:    SeverityWarning,
+		check:       checkForwardTo,
+	},
+	{
+		Name:        "empty-criteria",
+		Description: "criteria is empty and the filter matches all messages",
+		Severity:    SeverityError,
+		check:       checkEmptyCriteria,
+	},
+	{
+		Name:        "empty-action",
+		Description: "action is empty and the filter does nothing",
+		Severity:    SeverityWarning,
+		check:       checkEmptyAction,
+	},
+	{
+		Name:              "overlapping-criteria",
+		Description:       "criteria is the same as, or narrower than, the criteria of another filter",
+		Severity:          SeverityWarning,
+		DisabledByDefault: true,
+		check:             checkOverlappingCriteria,
+	},
+	{
+		Name:        "duplicate-filter",
+		Description: "filter has the same criteria and action as another filter",
+		Severity:    SeverityWarning,
+		check:       checkDuplicateFilter,
+	},
+	{
+		Name:        "conflicting-actions",
+		Description: "actions of filters matching the same messages conflict",
+		Severity:    SeverityError,
+		check:       checkConflictingActions,
+	},
+	{
+		Name:        "mergeable-filters",
+		Description: "filters with the same criteria can be merged into one filter",
+		Severity:    SeverityWarning,
+		check:       checkMergeableFilters,
+	},
+	{
+		Name:        "subsumed-filter",
+		Description: "another filter with broader criteria already does everything the filter does",
+		Severity:    SeverityWarning,
+		check:       checkSubsumedFilter,
+	},
+	{
+		Name:        "invalid-important",
+		Description: `important must be "always" or "never"`,
+		Severity:    SeverityError,
+		check:       checkInvalidImportant,
+	},
+	{
+		Name:        "invalid-category",
+		Description: "category is not a known category",
+		Severity:    SeverityError,
+		check:       checkInvalidCategory,
+	},
+	{
+		Name:        "label-case",
+		Description: "labels differ only in case",
+		Severity:    SeverityWarning,
+		check:       checkLabelCase,
+	},
+	{
+		Name:        "query-syntax",
+		Description: "search query has a syntax error",
+		Severity:    SeverityError,
+		check:       checkQuerySyntax,
+	},
+	{
+		Name:        "unknown-operator",
+		Description: "search query uses an unknown operator, which is searched as a plain word",
+		Severity:    SeverityWarning,
+		check:       checkUnknownOperator,
+	},
+}
+
+func checkSizeComparison(filters []gmail.Filter, report reportFunc) {
+	for i, f := range filters {
+		if f.Criteria.LargerThan > 0 && f.Criteria.SmallerThan > 0 {
+			report(i, []string{"criteria", "smaller_than"}, "smaller_than is ignored because larger_than is also set")
+		}
+	}
+}
+
+func checkDeleteWithActions(filters []gmail.Filter, report reportFunc) {
+	for i, f := range filters {
+		if !f.Action.Delete {
+			continue
+		}
+		others := f.Action
+		others.Delete = false
+		if others != (gmail.FilterAction{}) {
+			report(i, []string{"action", "delete"}, "delete is combined with other actions: %s", others.String())
+		}
+	}
+}
+
+func checkForwardTo(filters []gmail.Filter, report reportFunc) {
+	for i, f := range filters {
+		if f.Action.ForwardTo != "" {
+			report(i, []string{"action", "forward_to"}, "messages are forwarded to %s", f.Action.ForwardTo)
+		}
+	}
+}
+
+func checkEmptyCriteria(filters []gmail.Filter, report reportFunc) {
+	for i, f := range filters {
+		if query.IsEmpty(f.Criteria.Node()) {
+			report(i, []string{"criteria"}, "criteria is empty, the filter matches all messages")
+		}
+	}
+}
+
+func checkEmptyAction(filters []gmail.Filter, report reportFunc) {
+	for i, f := range filters {
+		if f.Action == (gmail.FilterAction{}) {
+			report(i, []string{"action"}, "action is empty, the filter does nothing")
+		}
+	}
+}
+
+// overlap describes how criteria of two filters overlap.
+type overlap int
+
+const (
+	overlapNone overlap = iota
+	// overlapSame means the criteria are the same.
+	overlapSame
+	// overlapNarrower means all messages matching the latter filter
+	// also match the former filter.
+	overlapNarrower
+	// overlapBroader means all messages matching the former filter
+	// also match the latter filter.
+	overlapBroader
+)
+
+// forEachOverlap calls f for each pair of filters whose criteria overlap.
+// i is always less than j.
+func forEachOverlap(filters []gmail.Filter, f func(i, j int, o overlap)) {
+	sets := make([]map[string]bool, len(filters))
+	for i, filter := range filters {
+		sets[i] = conjuncts(filter.Criteria)
+	}
+	for j := range filters {
+		for i := 0; i < j; i++ {
+			if len(sets[i]) == 0 || len(sets[j]) == 0 {
+				continue
+			}
+			switch {
+			case isSubset(sets[i], sets[j]) && len(sets[i]) == len(sets[j]):
+				f(i, j, overlapSame)
+			case isSubset(sets[i], sets[j]):
+				f(i, j, overlapNarrower)
+			case isSubset(sets[j], sets[i]):
+				f(i, j, overlapBroader)
+			}
+		}
+	}
+}
+
+// checkOverlappingCriteria reports the overlapping filters except ones
+// reported by the more specific rules, i.e. duplicate-filter,
+// conflicting-actions, mergeable-filters and subsumed-filter.
+func checkOverlappingCriteria(filters []gmail.Filter, report reportFunc) {
+	forEachOverlap(filters, func(i, j int, o overlap) {
+		if isReportedOverlap(filters[i], filters[j], o) {
+			return
+		}
+		switch o {
+		case overlapSame:
+			report(j, []string{"criteria"}, "criteria is the same as filter #%d", i+1)
+		case overlapNarrower:
+			report(j, []string{"criteria"}, "all messages matching this filter also match filter #%d", i+1)
+		case overlapBroader:
+			report(j, []string{"criteria"}, "all messages matching filter #%d also match this filter", i+1)
+		}
+	})
+}
+
+// isReportedOverlap reports whether the overlap of filters a and b is
+// reported by the more specific rules.
+func isReportedOverlap(a, b gmail.Filter, o overlap) bool {
+	if len(a.Action.Conflicts(b.Action)) > 0 {
+		return true
+	}
+	switch o {
+	case overlapSame:
+		if a.Action.Normalize() == b.Action.Normalize() {
+			return true
+		}
+		_, err := a.Action.Merge(b.Action)
+		return err == nil
+	case overlapNarrower:
+		return a.Action.Includes(b.Action)
+	case overlapBroader:
+		return b.Action.Includes(a.Action)
+	}
+	return false
+}
+
+func checkDuplicateFilter(filters []gmail.Filter, report reportFunc) {
+	forEachOverlap(filters, func(i, j int, o overlap) {
+		if o == overlapSame && filters[i].Action.Normalize() == filters[j].Action.Normalize() {
+			report(j, []string{"criteria"}, "filter is a duplicate of filter #%d", i+1)
+		}
+	})
+}
+
+func checkConflictingActions(filters []gmail.Filter, report reportFunc) {
+	forEachOverlap(filters, func(i, j int, o overlap) {
+		conflicts := filters[i].Action.Conflicts(filters[j].Action)
+		if len(conflicts) == 0 {
+			return
+		}
+		var subject string
+		switch o {
+		case overlapSame:
+			subject = "criteria is the same as filter #%d"
+		case overlapNarrower:
+			subject = "all messages matching this filter also match filter #%d"
+		case overlapBroader:
+			subject = "all messages matching filter #%d also match this filter"
+		}
+		for _, name := range conflicts {
+			report(j, []string{"action", name}, subject+", but %s conflicts", i+1, name)
+		}
+	})
+}
+
+func checkMergeableFilters(filters []gmail.Filter, report reportFunc) {
+	forEachOverlap(filters, func(i, j int, o overlap) {
+		if o != overlapSame || filters[i].Action.Normalize() == filters[j].Action.Normalize() {
+			return
+		}
+		if _, err := filters[i].Action.Merge(filters[j].Action); err == nil {
+			report(j, []string{"criteria"}, "filter can be merged with filter #%d, try `gmac fmt --merge`", i+1)
+		}
+	})
+}
+
+func checkSubsumedFilter(filters []gmail.Filter, report reportFunc) {
+	forEachOverlap(filters, func(i, j int, o overlap) {
+		switch o {
+		case overlapNarrower:
+			if filters[i].Action.Includes(filters[j].Action) {
+				report(j, []string{"criteria"}, "filter #%d matches all messages matching this filter and does the same", i+1)
+			}
+		case overlapBroader:
+			if filters[j].Action.Includes(filters[i].Action) {
+				report(i, []string{"criteria"}, "filter #%d matches all messages matching this filter and does the same", j+1)
+			}
+		}
+	})
+}
+
+// conjuncts returns the set of the terms of the normalized criteria,
+// which are combined with AND.
+func conjuncts(c gmail.FilterCriteria) map[string]bool {
+	set := map[string]bool{}
+	n := c.Normalize()
+	if query.IsEmpty(n) {
+		return set
+	}
+	if and, ok := n.(query.And); ok {
+		for _, c := range and.Nodes {
+			set[c.String()] = true
+		}
+		return set
+	}
+	set[n.String()] = true
+	return set
+}
+
+func isSubset(a, b map[string]bool) bool {
+	for k := range a {
+		if !b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func checkInvalidImportant(filters []gmail.Filter, report reportFunc) {
+	for i, f := range filters {
+		switch f.Action.Important {
+		case "", gmail.FilterActionImportantAlways, gmail.FilterActionImportantNever:
+		default:
+			report(i, []string{"action", "important"}, `unknown important value %q, must be "always" or "never"`, f.Action.Important)
+		}
+	}
+}
+
+func checkInvalidCategory(filters []gmail.Filter, report reportFunc) {
+	for i, f := range filters {
+		if f.Action.Category == "" {
+			continue
+		}
+		if _, _, err := (gmail.FilterAction{Category: f.Action.Category}).SystemLabelChanges(); err != nil {
+			report(i, []string{"action", "category"}, "unknown category %q", f.Action.Category)
+		}
+	}
+}
+
+func checkLabelCase(filters []gmail.Filter, report reportFunc) {
+	seen := map[string]string{}
+	for i, f := range filters {
+		label := f.Action.AddLabel
+		if label == "" {
+			continue
+		}
+		key := strings.ToLower(label)
+		if prev, ok := seen[key]; ok && prev != label {
+			report(i, []string{"action", "add_label"}, "label %q differs only in case from %q", label, prev)
+			continue
+		}
+		seen[key] = label
+	}
+}
+
+// queryField is a field of the criteria which holds a search query.
+type queryField struct {
+	path  []string
+	value string
+}
+
+// queryFields returns all fields holding a search query in the criteria,
+// including ones in nested criteria.
+func queryFields(c gmail.FilterCriteria, path []string) []queryField {
+	var fields []queryField
+	add := func(name, value string) {
+		if value != "" {
+			fields = append(fields, queryField{path: appendPath(path, name), value: value})
+		}
+	}
+	addTerms := func(name string, terms gmail.Terms) {
+		// list terms are literal values, not search queries
+		if len(terms) == 1 {
+			add(name, terms[0])
+		}
+	}
+	addNested := func(name string, list []gmail.FilterCriteria) {
+		for i, nested := range list {
+			fields = append(fields, queryFields(nested, appendPath(path, name, strconv.Itoa(i)))...)
+		}
+	}
+	addTerms("from", c.From)
+	addTerms("to", c.To)
+	addTerms("subject", c.Subject)
+	add("query", c.Query)
+	add("negated_query", c.NegatedQuery)
+	addNested("any_of", c.AnyOf)
+	addNested("all_of", c.AllOf)
+	addNested("none_of", c.NoneOf)
+	return fields
+}
+
+func appendPath(path []string, elems ...string) []string {
+	p := make([]string, 0, len(path)+len(elems))
+	p = append(p, path...)
+	return append(p, elems...)
+}
+
+func checkQuerySyntax(filters []gmail.Filter, report reportFunc) {
+	for i, f := range filters {
+		for _, field := range queryFields(f.Criteria, []string{"criteria"}) {
+			if _, err := query.Parse(field.value); err != nil {
+				report(i, field.path, "%v in %q", err, field.value)
+			}
+		}
+	}
+}
+
+var operatorLike = regexp.MustCompile(`^-?([A-Za-z_]+):([^/]|$)`)
+
+func checkUnknownOperator(filters []gmail.Filter, report reportFunc) {
+	for i, f := range filters {
+		for _, field := range queryFields(f.Criteria, []string{"criteria"}) {
+			n, err := query.Parse(field.value)
+			if err != nil {
+				continue
+			}
+			query.Inspect(n, func(n query.Node) bool {
+				if w, ok := n.(query.Word); ok {
+					if m := operatorLike.FindStringSubmatch(w.Value); m != nil && !query.IsOperator(m[1]) {
+						report(i, field.path, "unknown search operator %q", m[1]+":")
+					}
+				}
+				return true
+			})
+		}
+	}
+}
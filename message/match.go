@@ -0,0 +1,320 @@
+package message
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nasa9084/gmac/query"
+)
+
+// now is replacable function for testing purpose.
+var now = time.Now
+
+// UnsupportedError is returned when the query contains an operator
+// which cannot be evaluated locally, e.g. `label:` or `is:unread`.
+type UnsupportedError struct {
+	Operator string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("operator %q cannot be evaluated locally", e.Operator)
+}
+
+// Match reports whether the message matches given query.
+// If the result depends on an operator which cannot be evaluated
+// locally, Match returns *UnsupportedError.
+func Match(n query.Node, m *Message) (bool, error) {
+	return m.match(n, m.fullText())
+}
+
+func (m *Message) fullText() string {
+	return strings.Join([]string{
+		m.HeaderValue("From"),
+		m.HeaderValue("To"),
+		m.HeaderValue("Cc"),
+		m.Subject(),
+		m.Body,
+	}, "\n")
+}
+
+// match evaluates the node against given text, which is the whole
+// message at the top level, or the value of a header field in the
+// field value.
+func (m *Message) match(n query.Node, text string) (bool, error) {
+	switch v := n.(type) {
+	case query.Word:
+		return containsTerm(text, strings.TrimPrefix(v.Value, "+")), nil
+	case query.Phrase:
+		return containsTerm(text, strings.Join(strings.Fields(v.Value), " ")), nil
+	case query.Field:
+		return m.matchField(v)
+	case query.And:
+		var unsupported error
+		for _, c := range v.Nodes {
+			ok, err := m.match(c, text)
+			if err != nil {
+				unsupported = err
+				continue
+			}
+			if !ok {
+				return false, nil
+			}
+		}
+		return unsupported == nil, unsupported
+	case query.Or:
+		var unsupported error
+		for _, c := range v.Nodes {
+			ok, err := m.match(c, text)
+			if err != nil {
+				unsupported = err
+				continue
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, unsupported
+	case query.Not:
+		ok, err := m.match(v.Node, text)
+		if err != nil {
+			return false, err
+		}
+		return !ok, nil
+	case query.Around:
+		return m.matchAround(v, text)
+	}
+	return false, fmt.Errorf("unknown node type: %T", n)
+}
+
+func (m *Message) matchField(f query.Field) (bool, error) {
+	switch f.Name {
+	case "from":
+		return m.match(f.Value, m.HeaderValue("From"))
+	case "to":
+		return m.match(f.Value, strings.Join([]string{m.HeaderValue("To"), m.HeaderValue("Cc"), m.HeaderValue("Bcc")}, "\n"))
+	case "cc":
+		return m.match(f.Value, m.HeaderValue("Cc"))
+	case "bcc":
+		return m.match(f.Value, m.HeaderValue("Bcc"))
+	case "subject":
+		return m.match(f.Value, m.Subject())
+	case "deliveredto":
+		return m.match(f.Value, m.HeaderValue("Delivered-To"))
+	case "list":
+		return m.match(f.Value, m.HeaderValue("List-Id"))
+	case "rfc822msgid":
+		return m.match(f.Value, m.HeaderValue("Message-Id"))
+	case "filename":
+		return m.match(f.Value, strings.Join(m.Attachments, "\n"))
+	}
+
+	// operators below take a single value
+	w, ok := f.Value.(query.Word)
+	if !ok {
+		return false, &UnsupportedError{Operator: f.String()}
+	}
+	value := strings.ToLower(w.Value)
+	switch f.Name {
+	case "has":
+		if value == "attachment" {
+			return len(m.Attachments) > 0, nil
+		}
+	case "in":
+		switch value {
+		case "chats":
+			return false, nil
+		case "anywhere":
+			return true, nil
+		}
+	case "larger", "size":
+		size, err := ParseSize(value)
+		if err != nil {
+			return false, err
+		}
+		return m.Size > size, nil
+	case "smaller":
+		size, err := ParseSize(value)
+		if err != nil {
+			return false, err
+		}
+		return m.Size < size, nil
+	case "older_than", "newer_than":
+		d, err := parseRelativeDate(value)
+		if err != nil {
+			return false, err
+		}
+		date, err := m.Header.Date()
+		if err != nil {
+			return false, &UnsupportedError{Operator: f.String()}
+		}
+		if f.Name == "older_than" {
+			return date.Before(d), nil
+		}
+		return !date.Before(d), nil
+	case "after", "newer", "before", "older":
+		t, err := parseDate(value)
+		if err != nil {
+			return false, err
+		}
+		date, err := m.Header.Date()
+		if err != nil {
+			return false, &UnsupportedError{Operator: f.String()}
+		}
+		if f.Name == "after" || f.Name == "newer" {
+			return !date.Before(t), nil
+		}
+		return date.Before(t), nil
+	}
+	return false, &UnsupportedError{Operator: f.String()}
+}
+
+func (m *Message) matchAround(a query.Around, text string) (bool, error) {
+	left, lok := termOf(a.Left)
+	right, rok := termOf(a.Right)
+	if !lok || !rok {
+		return false, &UnsupportedError{Operator: "AROUND"}
+	}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '@' && r != '.' && r != '_' && r != '-'
+	})
+	var lpos, rpos []int
+	for i, w := range words {
+		if w == left {
+			lpos = append(lpos, i)
+		}
+		if w == right {
+			rpos = append(rpos, i)
+		}
+	}
+	for _, l := range lpos {
+		for _, r := range rpos {
+			d := l - r
+			if d < 0 {
+				d = -d
+			}
+			if d-1 <= a.Distance {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func termOf(n query.Node) (string, bool) {
+	switch v := n.(type) {
+	case query.Word:
+		return strings.ToLower(v.Value), true
+	case query.Phrase:
+		if strings.ContainsAny(v.Value, " \t") {
+			return "", false
+		}
+		return strings.ToLower(v.Value), true
+	}
+	return "", false
+}
+
+// containsTerm reports whether text contains term, case-insensitively.
+// The term must not be a part of a longer word.
+func containsTerm(text, term string) bool {
+	if term == "" {
+		return true
+	}
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	term = strings.ToLower(term)
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], term)
+		if i < 0 {
+			return false
+		}
+		begin, end := offset+i, offset+i+len(term)
+		if isBoundary(text, begin, term, true) && isBoundary(text, end, term, false) {
+			return true
+		}
+		offset = begin + 1
+	}
+}
+
+func isBoundary(text string, pos int, term string, before bool) bool {
+	var edge, neighbor rune
+	if before {
+		if pos == 0 {
+			return true
+		}
+		edge, _ = utf8.DecodeRuneInString(term)
+		neighbor, _ = utf8.DecodeLastRuneInString(text[:pos])
+	} else {
+		if pos == len(text) {
+			return true
+		}
+		edge, _ = utf8.DecodeLastRuneInString(term)
+		neighbor, _ = utf8.DecodeRuneInString(text[pos:])
+	}
+	if !isWordRune(edge) {
+		return true
+	}
+	return !isWordRune(neighbor)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_'
+}
+
+// ParseSize parses size value of `larger:` and `smaller:` operators,
+// e.g. `1000`, `10K` or `5M`.
+func ParseSize(s string) (int64, error) {
+	s = strings.ToLower(s)
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier = 1 << 10
+		s = strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		multiplier = 1 << 20
+		s = strings.TrimSuffix(s, "m")
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return n * multiplier, nil
+}
+
+// parseRelativeDate parses the value of `older_than:` and `newer_than:`
+// operators, e.g. `2d`, `3m` or `1y`, and returns the time point.
+func parseRelativeDate(s string) (time.Time, error) {
+	if len(s) < 2 {
+		return time.Time{}, fmt.Errorf("invalid relative date: %s", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid relative date: %s", s)
+	}
+	t := now()
+	switch s[len(s)-1] {
+	case 'd':
+		return t.AddDate(0, 0, -n), nil
+	case 'm':
+		return t.AddDate(0, -n, 0), nil
+	case 'y':
+		return t.AddDate(-n, 0, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid relative date: %s", s)
+}
+
+// parseDate parses the value of `after:` and `before:` operators,
+// e.g. `2004/04/16`, `04/16/2004` or unix timestamp.
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{"2006/01/02", "2006/1/2", "01/02/2006", "1/2/2006", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s", s)
+}
@@ -0,0 +1,78 @@
+package message
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nasa9084/gmac/query"
+)
+
+func TestMatch(t *testing.T) {
+	now = func() time.Time { return time.Date(2006, 1, 10, 0, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	plain, err := Read("plain", strings.NewReader(plainMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	multipart, err := Read("multipart", strings.NewReader(multipartMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query   string
+		message *Message
+		want    bool
+	}{
+		{query: "from:ci@example.com", message: plain, want: true},
+		{query: "from:CI", message: plain, want: true},
+		{query: "from:example", message: plain, want: true},
+		{query: "from:exam", message: plain, want: false},
+		{query: `subject:"[FAILED]"`, message: plain, want: true},
+		{query: `subject:"[failed] ビルド"`, message: plain, want: true},
+		{query: "subject:{passed succeeded}", message: plain, want: false},
+		{query: "to:team@example.com -from:foo", message: plain, want: true},
+		{query: "build AROUND 1 failed", message: plain, want: true},
+		{query: "has:attachment", message: plain, want: false},
+		{query: "has:attachment filename:pdf", message: multipart, want: true},
+		{query: "larger:10K", message: multipart, want: false},
+		{query: "smaller:1M", message: multipart, want: true},
+		{query: "older_than:5d", message: plain, want: true},
+		{query: "newer_than:5d", message: plain, want: false},
+		{query: "after:2006/01/01 before:2006/01/03", message: plain, want: true},
+		{query: "monthly report -in:chats", message: multipart, want: true},
+		{query: "{label:foo from:ci}", message: plain, want: true},
+		{query: "label:foo from:nobody", message: plain, want: false},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.query, func(t *testing.T) {
+			got, err := Match(query.MustParse(tt.query), tt.message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("unexpected result: %t != %t", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestMatchUnsupported(t *testing.T) {
+	m, err := Read("plain", strings.NewReader(plainMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []string{"label:foo", "is:unread from:ci", "-category:social"} {
+		t.Run(q, func(t *testing.T) {
+			_, err := Match(query.MustParse(q), m)
+			if _, ok := err.(*UnsupportedError); !ok {
+				t.Errorf("UnsupportedError should be returned: %v", err)
+				return
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+// Package message reads RFC822 email messages from local files and
+// evaluates Gmail search queries against them.
+package message
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Message is an email message read from local file.
+type Message struct {
+	// Name identifies the message, e.g. its file path.
+	Name string
+
+	Header      mail.Header
+	Body        string
+	Attachments []string
+	Size        int64
+}
+
+var wordDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		// only UTF-8 and US-ASCII are supported by standard library,
+		// other charsets are passed as-is.
+		return input, nil
+	},
+}
+
+// HeaderValue returns decoded values of the header field, joined with a comma.
+func (m *Message) HeaderValue(key string) string {
+	values := m.Header[textproto.CanonicalMIMEHeaderKey(key)]
+	decoded := make([]string, 0, len(values))
+	for _, v := range values {
+		if d, err := wordDecoder.DecodeHeader(v); err == nil {
+			v = d
+		}
+		decoded = append(decoded, v)
+	}
+	return strings.Join(decoded, ", ")
+}
+
+// Subject returns decoded subject of the message.
+func (m *Message) Subject() string {
+	return m.HeaderValue("Subject")
+}
+
+// Read reads a RFC822 message from r.
+func Read(name string, r io.Reader) (*Message, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	m := &Message{
+		Name:   name,
+		Header: msg.Header,
+		Size:   int64(len(b)),
+	}
+	var body strings.Builder
+	if err := m.readPart(&body, msg.Header, msg.Body); err != nil {
+		return nil, err
+	}
+	m.Body = body.String()
+	return m, nil
+}
+
+func (m *Message) readPart(body *strings.Builder, header map[string][]string, r io.Reader) error {
+	get := func(key string) string {
+		if v := header[key]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	mediaType, params, err := mime.ParseMediaType(get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if disposition, dparams, err := mime.ParseMediaType(get("Content-Disposition")); err == nil {
+		filename := dparams["filename"]
+		if filename == "" {
+			filename = params["name"]
+		}
+		if disposition == "attachment" || filename != "" {
+			if d, err := wordDecoder.DecodeHeader(filename); err == nil {
+				filename = d
+			}
+			m.Attachments = append(m.Attachments, filename)
+			return nil
+		}
+	} else if params["name"] != "" {
+		m.Attachments = append(m.Attachments, params["name"])
+		return nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := m.readPart(body, p.Header, p); err != nil {
+				return err
+			}
+		}
+	}
+	if !strings.HasPrefix(mediaType, "text/") {
+		return nil
+	}
+
+	switch strings.ToLower(get("Content-Transfer-Encoding")) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, newlineStripper{r})
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if body.Len() > 0 {
+		body.WriteByte('\n')
+	}
+	body.Write(b)
+	return nil
+}
+
+// newlineStripper removes CR and LF from base64 encoded content.
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (s newlineStripper) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	j := 0
+	for _, c := range p[:n] {
+		if c != '\r' && c != '\n' {
+			p[j] = c
+			j++
+		}
+	}
+	return j, err
+}
+
+// Load reads messages from given path. The path can be:
+//
+//   - a RFC822 message file, e.g. .eml file
+//   - a mbox file
+//   - a Maildir directory, which has cur and new subdirectories
+//   - a directory which contains files above
+func Load(path string) ([]*Message, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return loadFile(path)
+	}
+	if isMaildir(path) {
+		return loadMaildir(path)
+	}
+
+	var msgs []*Message
+	err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if p != path && isMaildir(p) {
+				m, err := loadMaildir(p)
+				if err != nil {
+					return err
+				}
+				msgs = append(msgs, m...)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(fi.Name(), ".") {
+			return nil
+		}
+		m, err := loadFile(p)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, m...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func isMaildir(path string) bool {
+	for _, sub := range []string{"cur", "new"} {
+		fi, err := os.Stat(filepath.Join(path, sub))
+		if err != nil || !fi.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+func loadMaildir(path string) ([]*Message, error) {
+	var msgs []*Message
+	for _, sub := range []string{"new", "cur"} {
+		files, err := ioutil.ReadDir(filepath.Join(path, sub))
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+		for _, fi := range files {
+			if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+				continue
+			}
+			m, err := loadMessageFile(filepath.Join(path, sub, fi.Name()))
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs, nil
+}
+
+func loadFile(path string) ([]*Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	head, err := br.Peek(5)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if string(head) == "From " {
+		return readMbox(path, br)
+	}
+	m, err := Read(path, br)
+	if err != nil {
+		return nil, err
+	}
+	return []*Message{m}, nil
+}
+
+func loadMessageFile(path string) (*Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(path, f)
+}
+
+// readMbox reads messages from mbox format (mboxrd) stream.
+func readMbox(name string, r io.Reader) ([]*Message, error) {
+	var msgs []*Message
+	var buf bytes.Buffer
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		m, err := Read(name+"#"+strconv.Itoa(len(msgs)+1), &buf)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, m)
+		buf.Reset()
+		return nil
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	started := false
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "From ") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			started = true
+			continue
+		}
+		if !started {
+			continue
+		}
+		// unescape ">From " quoting
+		if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") && strings.HasPrefix(line, ">") {
+			line = line[1:]
+		}
+		buf.WriteString(line)
+		buf.WriteString("\r\n")
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
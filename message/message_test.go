@@ -0,0 +1,123 @@
+package message
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const plainMessage = "From: CI <ci@example.com>\r\n" +
+	"To: team@example.com\r\n" +
+	"Subject: =?UTF-8?B?W0ZBSUxFRF0g44OT44Or44OJ?=\r\n" +
+	"Date: Mon, 02 Jan 2006 15:04:05 +0000\r\n" +
+	"\r\n" +
+	"build failed\r\n"
+
+const multipartMessage = "From: foo@example.com\r\n" +
+	"To: bar@example.com\r\n" +
+	"Subject: report\r\n" +
+	"Content-Type: multipart/mixed; boundary=BOUNDARY\r\n" +
+	"\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"monthly=20report\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: application/pdf; name=\"report.pdf\"\r\n" +
+	"Content-Disposition: attachment; filename=\"report.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQK\r\n" +
+	"--BOUNDARY--\r\n"
+
+func TestRead(t *testing.T) {
+	t.Run("plain message", func(t *testing.T) {
+		m, err := Read("plain", strings.NewReader(plainMessage))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := m.Subject(), "[FAILED] ビルド"; got != want {
+			t.Errorf("unexpected subject: %s != %s", got, want)
+			return
+		}
+		if got, want := m.Body, "build failed\r\n"; got != want {
+			t.Errorf("unexpected body: %q != %q", got, want)
+			return
+		}
+		if m.Size != int64(len(plainMessage)) {
+			t.Errorf("unexpected size: %d != %d", m.Size, len(plainMessage))
+			return
+		}
+	})
+	t.Run("multipart message", func(t *testing.T) {
+		m, err := Read("multipart", strings.NewReader(multipartMessage))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := m.Body, "monthly report"; got != want {
+			t.Errorf("unexpected body: %q != %q", got, want)
+			return
+		}
+		if !reflect.DeepEqual(m.Attachments, []string{"report.pdf"}) {
+			t.Errorf("unexpected attachments: %v", m.Attachments)
+			return
+		}
+	})
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gmac-message-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mustWrite := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustWrite(filepath.Join(dir, "a.eml"), plainMessage)
+	mustWrite(filepath.Join(dir, "box.mbox"),
+		"From ci@example.com Mon Jan  2 15:04:05 2006\n"+strings.Replace(plainMessage, "\r\n", "\n", -1)+
+			">From the team\n"+
+			"\nFrom foo@example.com Mon Jan  2 15:04:05 2006\n"+strings.Replace(plainMessage, "\r\n", "\n", -1))
+	mustWrite(filepath.Join(dir, "maildir", "cur", "1.host:2,S"), plainMessage)
+	mustWrite(filepath.Join(dir, "maildir", "new", "2.host"), multipartMessage)
+	if err := os.MkdirAll(filepath.Join(dir, "maildir", "tmp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range msgs {
+		rel, _ := filepath.Rel(dir, m.Name)
+		names = append(names, rel)
+	}
+	want := []string{
+		"a.eml",
+		"box.mbox#1",
+		"box.mbox#2",
+		filepath.Join("maildir", "new", "2.host"),
+		filepath.Join("maildir", "cur", "1.host:2,S"),
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("unexpected messages:\n  got:  %v\n  want: %v", names, want)
+		return
+	}
+	if !strings.Contains(msgs[1].Body, "\nFrom the team") {
+		t.Errorf("escaped From line should be unescaped: %q", msgs[1].Body)
+		return
+	}
+}
@@ -0,0 +1,268 @@
+// Package query implements a parser and a printer for Gmail search
+// query syntax.
+package query
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Node is a node of the query syntax tree.
+// String() returns the node in canonical Gmail search syntax.
+type Node interface {
+	String() string
+	node()
+}
+
+// Word is a bare search term, e.g. `foo` or `foo@example.com`.
+type Word struct {
+	Value string
+}
+
+// Phrase is a quoted search term, e.g. `"hello world"`. Value cannot
+// contain double quotes, which are removed on printing.
+type Phrase struct {
+	Value string
+}
+
+// Field is an operator with its value, e.g. `from:foo`,
+// `subject:(foo bar)` or `label:{foo bar}`.
+type Field struct {
+	Name  string
+	Value Node
+}
+
+// And is a sequence of nodes, which matches when all of the nodes match.
+type And struct {
+	Nodes []Node
+}
+
+// Or matches when any of the nodes match, e.g. `foo OR bar` or `{foo bar}`.
+type Or struct {
+	Nodes []Node
+}
+
+// Not matches when the node does not match, e.g. `-foo`.
+type Not struct {
+	Node Node
+}
+
+// Around matches when the two terms appear within Distance words
+// of each other, e.g. `foo AROUND 5 bar`.
+type Around struct {
+	Left     Node
+	Right    Node
+	Distance int
+}
+
+func (Word) node()   {}
+func (Phrase) node() {}
+func (Field) node()  {}
+func (And) node()    {}
+func (Or) node()     {}
+func (Not) node()    {}
+func (Around) node() {}
+
+func (n Word) String() string {
+	return n.Value
+}
+
+func (n Phrase) String() string {
+	// Gmail search does not support escaping quotes in a phrase, so
+	// embedded quotes are removed
+	return `"` + strings.ReplaceAll(n.Value, `"`, "") + `"`
+}
+
+func (n Field) String() string {
+	switch v := n.Value.(type) {
+	case Word, Phrase:
+		return n.Name + ":" + v.String()
+	case Or:
+		return n.Name + ":{" + joinNodes(v.Nodes, orItem) + "}"
+	case And:
+		return n.Name + ":(" + joinNodes(v.Nodes, andItem) + ")"
+	}
+	return n.Name + ":(" + n.Value.String() + ")"
+}
+
+func (n And) String() string {
+	return joinNodes(n.Nodes, andItem)
+}
+
+func (n Or) String() string {
+	return "{" + joinNodes(n.Nodes, orItem) + "}"
+}
+
+func (n Not) String() string {
+	switch v := n.Node.(type) {
+	case Word, Phrase, Field, Or:
+		return "-" + v.String()
+	}
+	return "-(" + n.Node.String() + ")"
+}
+
+func (n Around) String() string {
+	return n.Left.String() + " AROUND " + strconv.Itoa(n.Distance) + " " + n.Right.String()
+}
+
+// andItem formats a node as an item of And sequence.
+func andItem(n Node) string {
+	if v, ok := n.(And); ok {
+		return "(" + v.String() + ")"
+	}
+	return n.String()
+}
+
+// orItem formats a node as an item of Or group.
+func orItem(n Node) string {
+	switch v := n.(type) {
+	case And, Around:
+		return "(" + v.String() + ")"
+	}
+	return n.String()
+}
+
+func joinNodes(nodes []Node, format func(Node) string) string {
+	s := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		s = append(s, format(n))
+	}
+	return strings.Join(s, " ")
+}
+
+// Inspect traverses the syntax tree in depth-first order.
+// It calls f(node) for each node, and if f returns true,
+// Inspect invokes f recursively for each of the children of node.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+	switch n := node.(type) {
+	case Field:
+		Inspect(n.Value, f)
+	case And:
+		for _, c := range n.Nodes {
+			Inspect(c, f)
+		}
+	case Or:
+		for _, c := range n.Nodes {
+			Inspect(c, f)
+		}
+	case Not:
+		Inspect(n.Node, f)
+	case Around:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	}
+}
+
+// Term returns a node which matches given literal term.
+// The term is quoted if it contains whitespace or characters which
+// have a special meaning in Gmail search syntax. Double quotes in the
+// term are removed, as Gmail search cannot escape them.
+func Term(s string) Node {
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"(){}") || strings.HasPrefix(s, "-") || isKeyword(s) {
+		return Phrase{Value: s}
+	}
+	if i := strings.IndexByte(s, ':'); i > 0 && IsOperator(s[:i]) {
+		return Phrase{Value: s}
+	}
+	return Word{Value: s}
+}
+
+// NewAnd returns a node which matches when all of given nodes match.
+// nested And nodes are flattened, and empty nodes are ignored.
+func NewAnd(nodes ...Node) Node {
+	var flat []Node
+	for _, n := range nodes {
+		switch v := n.(type) {
+		case nil:
+		case And:
+			flat = append(flat, v.Nodes...)
+		default:
+			flat = append(flat, n)
+		}
+	}
+	if len(flat) == 1 {
+		return flat[0]
+	}
+	return And{Nodes: flat}
+}
+
+// NewOr returns a node which matches when any of given nodes match.
+// nested Or nodes are flattened, and empty nodes are ignored.
+func NewOr(nodes ...Node) Node {
+	var flat []Node
+	for _, n := range nodes {
+		switch v := n.(type) {
+		case nil:
+		case And:
+			if len(v.Nodes) == 0 {
+				continue
+			}
+			flat = append(flat, v)
+		case Or:
+			flat = append(flat, v.Nodes...)
+		default:
+			flat = append(flat, n)
+		}
+	}
+	switch len(flat) {
+	case 0:
+		return And{}
+	case 1:
+		return flat[0]
+	}
+	return Or{Nodes: flat}
+}
+
+// IsEmpty reports whether the node is an empty query which matches all messages.
+func IsEmpty(n Node) bool {
+	if n == nil {
+		return true
+	}
+	v, ok := n.(And)
+	return ok && len(v.Nodes) == 0
+}
+
+// operators is a set of known Gmail search operators.
+var operators = map[string]struct{}{
+	"from":        {},
+	"to":          {},
+	"cc":          {},
+	"bcc":         {},
+	"subject":     {},
+	"label":       {},
+	"has":         {},
+	"is":          {},
+	"in":          {},
+	"list":        {},
+	"filename":    {},
+	"after":       {},
+	"before":      {},
+	"older":       {},
+	"newer":       {},
+	"older_than":  {},
+	"newer_than":  {},
+	"larger":      {},
+	"smaller":     {},
+	"size":        {},
+	"category":    {},
+	"deliveredto": {},
+	"rfc822msgid": {},
+}
+
+// IsOperator reports whether given name is a known Gmail search operator.
+// name is case-insensitive.
+func IsOperator(name string) bool {
+	_, ok := operators[strings.ToLower(name)]
+	return ok
+}
+
+func isKeyword(s string) bool {
+	switch s {
+	case "OR", "AND", "AROUND":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package query
+
+import (
+	"sort"
+	"strings"
+)
+
+// Normalize returns a node which is equivalent to given node in the
+// normalized form, so that two queries can be compared regardless of
+// how they are written. Gmail search is case-insensitive, so the terms
+// are lowercased, and the items of AND and OR are sorted and
+// deduplicated.
+func Normalize(n Node) Node {
+	switch v := n.(type) {
+	case Word:
+		return Term(strings.ToLower(v.Value))
+	case Phrase:
+		return Term(strings.ToLower(v.Value))
+	case Field:
+		return Field{Name: strings.ToLower(v.Name), Value: Normalize(v.Value)}
+	case And:
+		// normalized items may be AND themselves, so flatten them first
+		n := NewAnd(normalizeNodes(v.Nodes)...)
+		if and, ok := n.(And); ok {
+			return NewAnd(sortNodes(and.Nodes)...)
+		}
+		return n
+	case Or:
+		n := NewOr(normalizeNodes(v.Nodes)...)
+		if or, ok := n.(Or); ok {
+			return NewOr(sortNodes(or.Nodes)...)
+		}
+		return n
+	case Not:
+		inner := Normalize(v.Node)
+		if not, ok := inner.(Not); ok {
+			return not.Node
+		}
+		return Not{Node: inner}
+	case Around:
+		return Around{Left: Normalize(v.Left), Right: Normalize(v.Right), Distance: v.Distance}
+	}
+	return n
+}
+
+func normalizeNodes(nodes []Node) []Node {
+	normalized := make([]Node, 0, len(nodes))
+	for _, n := range nodes {
+		normalized = append(normalized, Normalize(n))
+	}
+	return normalized
+}
+
+// sortNodes sorts nodes by their string representation and removes
+// duplicated ones.
+func sortNodes(nodes []Node) []Node {
+	seen := map[string]bool{}
+	var sorted []Node
+	for _, n := range nodes {
+		if s := n.String(); !seen[s] {
+			seen[s] = true
+			sorted = append(sorted, n)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].String() < sorted[j].String()
+	})
+	return sorted
+}
@@ -0,0 +1,284 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenWord
+	tokenPhrase
+	tokenField // operator name followed by colon, e.g. `from:`
+	tokenMinus
+	tokenLParen
+	tokenRParen
+	tokenLBrace
+	tokenRBrace
+)
+
+type token struct {
+	typ   tokenType
+	value string
+	pos   int
+}
+
+// SyntaxError is an error on parsing search query.
+type SyntaxError struct {
+	// Offset is the byte offset in the query where the error occurred.
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("query: %s at offset %d", e.Msg, e.Offset)
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{typ: tokenLParen, pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{typ: tokenRParen, pos: i})
+			i++
+		case c == '{':
+			tokens = append(tokens, token{typ: tokenLBrace, pos: i})
+			i++
+		case c == '}':
+			tokens = append(tokens, token{typ: tokenRBrace, pos: i})
+			i++
+		case c == '"':
+			start := i
+			var b strings.Builder
+			i++
+			// Gmail search has no escape in a phrase, so the phrase
+			// ends at the next double quote
+			for ; i < len(s) && s[i] != '"'; i++ {
+				b.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, &SyntaxError{Offset: start, Msg: "unterminated quoted phrase"}
+			}
+			i++ // closing quote
+			tokens = append(tokens, token{typ: tokenPhrase, value: b.String(), pos: start})
+		case c == '-' && i+1 < len(s) && !isSpace(s[i+1]) && (i == 0 || isDelimiter(s[i-1])):
+			tokens = append(tokens, token{typ: tokenMinus, pos: i})
+			i++
+		default:
+			start := i
+			for ; i < len(s) && !isDelimiter(s[i]) && s[i] != '"'; i++ {
+				if s[i] == ':' && IsOperator(s[start:i]) {
+					break
+				}
+			}
+			if i < len(s) && s[i] == ':' {
+				i++
+				tokens = append(tokens, token{typ: tokenField, value: strings.ToLower(s[start : i-1]), pos: start})
+				continue
+			}
+			tokens = append(tokens, token{typ: tokenWord, value: s[start:i], pos: start})
+		}
+	}
+	tokens = append(tokens, token{typ: tokenEOF, pos: len(s)})
+	return tokens, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isDelimiter(c byte) bool {
+	return isSpace(c) || c == '(' || c == ')' || c == '{' || c == '}'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses given Gmail search query into the syntax tree.
+// An empty query is parsed into an empty And node.
+func Parse(s string) (Node, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	nodes, err := p.parseSequence(tokenEOF)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokenEOF {
+		return nil, &SyntaxError{Offset: t.pos, Msg: "unexpected closing bracket"}
+	}
+	return NewAnd(nodes...), nil
+}
+
+// MustParse is like Parse but panics if the query cannot be parsed.
+func MustParse(s string) Node {
+	n, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// parseSequence parses a sequence of nodes until given closing token.
+func (p *parser) parseSequence(end tokenType) ([]Node, error) {
+	var nodes []Node
+	for {
+		t := p.peek()
+		if t.typ == end || t.typ == tokenEOF || t.typ == tokenRParen || t.typ == tokenRBrace {
+			break
+		}
+		if t.typ == tokenWord && t.value == "AND" {
+			p.next()
+			continue
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// parseOr parses `a OR b OR ...`. OR binds tighter than implicit AND.
+func (p *parser) parseOr() (Node, error) {
+	n, err := p.parseAround()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []Node{n}
+	for {
+		t := p.peek()
+		if t.typ != tokenWord || (t.value != "OR" && t.value != "|") {
+			break
+		}
+		p.next()
+		n, err := p.parseAround()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return NewOr(nodes...), nil
+}
+
+// parseAround parses `a AROUND n b`.
+func (p *parser) parseAround() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.typ != tokenWord || t.value != "AROUND" {
+			return left, nil
+		}
+		dt := p.tokens[p.pos+1]
+		distance, err := strconv.Atoi(dt.value)
+		if dt.typ != tokenWord || err != nil || distance < 0 {
+			return nil, &SyntaxError{Offset: dt.pos, Msg: "AROUND must be followed by a number"}
+		}
+		p.next()
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = Around{Left: left, Right: right, Distance: distance}
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.peek().typ == tokenMinus {
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Node: n}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+	switch t.typ {
+	case tokenWord:
+		switch t.value {
+		case "OR", "|", "AROUND":
+			return nil, &SyntaxError{Offset: t.pos, Msg: fmt.Sprintf("unexpected %s", t.value)}
+		}
+		return Word{Value: t.value}, nil
+	case tokenPhrase:
+		return Phrase{Value: t.value}, nil
+	case tokenField:
+		v, err := p.parseFieldValue()
+		if err != nil {
+			return nil, err
+		}
+		return Field{Name: t.value, Value: v}, nil
+	case tokenLParen:
+		nodes, err := p.parseGroup(t, tokenRParen)
+		if err != nil {
+			return nil, err
+		}
+		return NewAnd(nodes...), nil
+	case tokenLBrace:
+		nodes, err := p.parseGroup(t, tokenRBrace)
+		if err != nil {
+			return nil, err
+		}
+		return NewOr(nodes...), nil
+	case tokenEOF:
+		return nil, &SyntaxError{Offset: t.pos, Msg: "unexpected end of query"}
+	}
+	return nil, &SyntaxError{Offset: t.pos, Msg: "unexpected closing bracket"}
+}
+
+func (p *parser) parseGroup(open token, end tokenType) ([]Node, error) {
+	nodes, err := p.parseSequence(end)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.next(); t.typ != end {
+		if t.typ == tokenEOF {
+			return nil, &SyntaxError{Offset: open.pos, Msg: "unclosed bracket"}
+		}
+		return nil, &SyntaxError{Offset: t.pos, Msg: "mismatched closing bracket"}
+	}
+	return nodes, nil
+}
+
+func (p *parser) parseFieldValue() (Node, error) {
+	t := p.peek()
+	switch t.typ {
+	case tokenWord, tokenPhrase, tokenLParen, tokenLBrace:
+		return p.parsePrimary()
+	}
+	return nil, &SyntaxError{Offset: t.pos, Msg: "operator requires a value"}
+}
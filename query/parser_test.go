@@ -0,0 +1,279 @@
+package query
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		label string
+		input string
+		want  Node
+	}{
+		{
+			label: "empty query",
+			input: "",
+			want:  And{},
+		},
+		{
+			label: "single word",
+			input: "foo",
+			want:  Word{Value: "foo"},
+		},
+		{
+			label: "implicit and",
+			input: "foo  bar",
+			want:  And{Nodes: []Node{Word{Value: "foo"}, Word{Value: "bar"}}},
+		},
+		{
+			label: "field",
+			input: "from:foo@example.com",
+			want:  Field{Name: "from", Value: Word{Value: "foo@example.com"}},
+		},
+		{
+			label: "field name is case-insensitive",
+			input: "Label:Work",
+			want:  Field{Name: "label", Value: Word{Value: "Work"}},
+		},
+		{
+			label: "unknown operator is a word",
+			input: "http://example.com",
+			want:  Word{Value: "http://example.com"},
+		},
+		{
+			label: "quoted phrase",
+			input: `subject:"hello world"`,
+			want:  Field{Name: "subject", Value: Phrase{Value: "hello world"}},
+		},
+		{
+			label: "backslash is not an escape in phrase",
+			input: `subject:"hello\" world`,
+			want:  And{Nodes: []Node{Field{Name: "subject", Value: Phrase{Value: `hello\`}}, Word{Value: "world"}}},
+		},
+		{
+			label: "OR binds tighter than AND",
+			input: "foo bar OR baz",
+			want: And{Nodes: []Node{
+				Word{Value: "foo"},
+				Or{Nodes: []Node{Word{Value: "bar"}, Word{Value: "baz"}}},
+			}},
+		},
+		{
+			label: "brace group",
+			input: "{from:a (from:b has:attachment)}",
+			want: Or{Nodes: []Node{
+				Field{Name: "from", Value: Word{Value: "a"}},
+				And{Nodes: []Node{
+					Field{Name: "from", Value: Word{Value: "b"}},
+					Field{Name: "has", Value: Word{Value: "attachment"}},
+				}},
+			}},
+		},
+		{
+			label: "negation",
+			input: "-{foo bar} -label:spam",
+			want: And{Nodes: []Node{
+				Not{Node: Or{Nodes: []Node{Word{Value: "foo"}, Word{Value: "bar"}}}},
+				Not{Node: Field{Name: "label", Value: Word{Value: "spam"}}},
+			}},
+		},
+		{
+			label: "hyphen in a word",
+			input: "foo-bar",
+			want:  Word{Value: "foo-bar"},
+		},
+		{
+			label: "around",
+			input: `holiday AROUND 10 "vacation plan"`,
+			want: Around{
+				Left:     Word{Value: "holiday"},
+				Right:    Phrase{Value: "vacation plan"},
+				Distance: 10,
+			},
+		},
+		{
+			label: "field with grouped value",
+			input: "older_than:1y from:(foo bar)",
+			want: And{Nodes: []Node{
+				Field{Name: "older_than", Value: Word{Value: "1y"}},
+				Field{Name: "from", Value: And{Nodes: []Node{Word{Value: "foo"}, Word{Value: "bar"}}}},
+			}},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected node:\n  got:  %#v\n  want: %#v", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		label string
+		input string
+	}{
+		{label: "unterminated phrase", input: `subject:"foo`},
+		{label: "unclosed paren", input: `(foo bar`},
+		{label: "unclosed brace", input: `{foo bar`},
+		{label: "unexpected closing paren", input: `foo)`},
+		{label: "mismatched bracket", input: `(foo}`},
+		{label: "operator without value", input: `from: `},
+		{label: "dangling OR", input: `foo OR`},
+		{label: "AROUND without number", input: `foo AROUND bar`},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil {
+				t.Errorf("error should be returned")
+				return
+			}
+			if _, ok := err.(*SyntaxError); !ok {
+				t.Errorf("unexpected error type: %T", err)
+				return
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		label string
+		input string
+		want  string
+	}{
+		{
+			label: "canonical form is kept",
+			input: "from:foo subject:(hello world) has:attachment",
+			want:  "from:foo subject:(hello world) has:attachment",
+		},
+		{
+			label: "redundant parentheses are removed",
+			input: "((foo) (bar baz))",
+			want:  "foo bar baz",
+		},
+		{
+			label: "OR is printed as brace group",
+			input: "from:a OR from:b",
+			want:  "{from:a from:b}",
+		},
+		{
+			label: "OR group in a field",
+			input: "from:(a OR b)",
+			want:  "from:{a b}",
+		},
+		{
+			label: "nested groups",
+			input: "{(from:a to:b) c} -(d e)",
+			want:  "{(from:a to:b) c} -(d e)",
+		},
+		{
+			label: "phrase",
+			input: `subject:"foo  bar"`,
+			want:  `subject:"foo  bar"`,
+		},
+		{
+			label: "around",
+			input: `{foo AROUND 3 bar baz}`,
+			want:  `{(foo AROUND 3 bar) baz}`,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			n, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := n.String()
+			if got != tt.want {
+				t.Errorf("unexpected string: %s != %s", got, tt.want)
+				return
+			}
+			// canonical form should be stable
+			n2, err := Parse(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n2.String() != got {
+				t.Errorf("canonical form is not stable: %s != %s", n2.String(), got)
+				return
+			}
+		})
+	}
+}
+
+func TestTerm(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Node
+	}{
+		{input: "foo@example.com", want: Word{Value: "foo@example.com"}},
+		{input: "John Doe", want: Phrase{Value: "John Doe"}},
+		{input: "OR", want: Phrase{Value: "OR"}},
+		{input: "-foo", want: Phrase{Value: "-foo"}},
+		{input: "from:foo", want: Phrase{Value: "from:foo"}},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.input, func(t *testing.T) {
+			if got := Term(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected node: %#v != %#v", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		label string
+		input string
+		want  string
+	}{
+		{
+			label: "lowercase",
+			input: "From:Foo@Example.com Bar",
+			want:  "bar from:foo@example.com",
+		},
+		{
+			label: "sort and deduplicate",
+			input: "foo bar foo",
+			want:  "bar foo",
+		},
+		{
+			label: "deduplicated into single term",
+			input: "{foo foo}",
+			want:  "foo",
+		},
+		{
+			label: "sort or",
+			input: "from:(b OR a) c",
+			want:  "c from:{a b}",
+		},
+		{
+			label: "phrase without spaces",
+			input: `"foo" "Bar Baz"`,
+			want:  `"bar baz" foo`,
+		},
+		{
+			label: "double negation",
+			input: "-(-foo)",
+			want:  "foo",
+		},
+	}
+	for i, tt := range tests {
+		got := Normalize(MustParse(tt.input)).String()
+		if got != tt.want {
+			t.Errorf("%s: %s != %s", strconv.Itoa(i)+"."+tt.label, got, tt.want)
+			return
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package report
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+)
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #202124; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #dadce0; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background-color: #f1f3f4; }
+td.count { text-align: right; }
+code { font-size: 0.95em; }
+.label { border-radius: 0.3em; padding: 0.1em 0.4em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{- if .ShowLabels}}
+<h2>Labels</h2>
+{{- if .LabelTree}}
+{{template "tree" .LabelTree}}
+{{- else}}
+<p>No labels.</p>
+{{- end}}
+{{- end}}
+{{- range .Groups}}
+<h2>{{template "label" .}}</h2>
+<table>
+<tr><th>Criteria</th><th>Action</th>{{if $.ShowCounts}}<th>Messages</th>{{end}}</tr>
+{{- range .Rules}}
+<tr><td><code>{{.Criteria}}</code></td><td>{{.Action}}</td>{{if $.ShowCounts}}<td class="count">{{if .HasCount}}{{.Count}}{{else}}-{{end}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No filters.</p>
+{{- end}}
+</body>
+</html>
+{{define "label"}}{{if .Color}}<span class="label" style="background-color: {{.Color.Background}}; color: {{.Color.Text}}">{{.Heading}}</span>{{else}}{{.Heading}}{{end}}{{end}}
+{{- define "tree"}}<ul>
+{{- range .}}
+<li>{{if .Color}}<span class="label" style="background-color: {{.Color.Background}}; color: {{.Color.Text}}">{{.Name}}</span>{{else}}{{.Name}}{{end}}
+{{- if .Children}}
+{{template "tree" .Children}}
+{{- end}}</li>
+{{- end}}
+</ul>{{end}}`))
+
+// WriteHTML writes the report as a standalone HTML document, which has
+// no external resources.
+func WriteHTML(w io.Writer, r Report) error {
+	data := struct {
+		Title      string
+		ShowLabels bool
+		LabelTree  []*labelNode
+		ShowCounts bool
+		Groups     []group
+	}{
+		Title:      r.title(),
+		ShowLabels: r.Labels != nil,
+		LabelTree:  r.labelTree(),
+		ShowCounts: r.Counts != nil,
+		Groups:     r.groups(),
+	}
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
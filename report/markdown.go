@@ -0,0 +1,84 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"|", `\|`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+	"<", "&lt;",
+	"\r\n", " ",
+	"\n", " ",
+)
+
+// WriteMarkdown writes the report in Markdown. The filters are written
+// as a table for each destination.
+func WriteMarkdown(w io.Writer, r Report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", markdownEscaper.Replace(r.title()))
+
+	if r.Labels != nil {
+		b.WriteString("\n## Labels\n\n")
+		tree := r.labelTree()
+		if len(tree) == 0 {
+			b.WriteString("No labels.\n")
+		}
+		writeMarkdownTree(&b, tree, 0)
+	}
+
+	groups := r.groups()
+	if len(groups) == 0 {
+		b.WriteString("\nNo filters.\n")
+	}
+	for _, g := range groups {
+		fmt.Fprintf(&b, "\n## %s\n\n", markdownEscaper.Replace(g.Heading))
+		if g.Color != nil {
+			fmt.Fprintf(&b, "Color: %s\n\n", markdownColor(g.Color))
+		}
+		if r.Counts != nil {
+			b.WriteString("| Criteria | Action | Messages |\n| --- | --- | ---: |\n")
+		} else {
+			b.WriteString("| Criteria | Action |\n| --- | --- |\n")
+		}
+		for _, rl := range g.Rules {
+			fmt.Fprintf(&b, "| %s | %s |", markdownEscaper.Replace(rl.Criteria), markdownEscaper.Replace(rl.Action))
+			if r.Counts != nil {
+				fmt.Fprintf(&b, " %s |", formatCount(rl))
+			}
+			b.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMarkdownTree(b *strings.Builder, nodes []*labelNode, depth int) {
+	for _, node := range nodes {
+		fmt.Fprintf(b, "%s- %s", strings.Repeat("  ", depth), markdownEscaper.Replace(node.Name))
+		if node.Color != nil {
+			fmt.Fprintf(b, " (%s)", markdownColor(node.Color))
+		}
+		b.WriteString("\n")
+		writeMarkdownTree(b, node.Children, depth+1)
+	}
+}
+
+func markdownColor(c *color) string {
+	return fmt.Sprintf("`%s` on `%s`", c.Text, c.Background)
+}
+
+func formatCount(rl rule) string {
+	if !rl.HasCount {
+		return "-"
+	}
+	return strconv.FormatInt(rl.Count, 10)
+}
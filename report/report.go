@@ -0,0 +1,194 @@
+// Package report writes a document of filters which describes where
+// messages go, in Markdown or HTML. The document is stable for the same
+// filters, so it can be committed next to the resource file.
+package report
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+// DefaultTitle is the title of the report if it is not specified.
+const DefaultTitle = "Mail Filters"
+
+// Headings of the groups of filters which do not add labels.
+const (
+	headingInbox   = "Inbox (no label)"
+	headingArchive = "Archive (no label)"
+	headingTrash   = "Trash"
+)
+
+var colorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{3}(?:[0-9A-Fa-f]{3})?$`)
+
+// Report is the content of a report.
+type Report struct {
+	// Title is the title of the report. DefaultTitle is used if empty.
+	Title   string
+	Filters []gmail.Filter
+	// Counts is the number of messages matching each filter, in the same
+	// order as Filters. The counts are not shown if Counts is nil.
+	Counts []int64
+	// Labels is the labels in Gmail. If it is not nil, the user labels
+	// are shown as a tree, and the labels are shown with their colors.
+	Labels []gmail.Label
+}
+
+// group is the filters which have the same destination.
+type group struct {
+	Heading string
+	// Color is the color of the label, which is nil for the groups of
+	// filters without labels, or labels without colors.
+	Color *color
+	Rules []rule
+}
+
+type rule struct {
+	Criteria string
+	Action   string
+	// Count is the number of matching messages, which is valid only if
+	// HasCount is true.
+	Count    int64
+	HasCount bool
+}
+
+type color struct {
+	Background string
+	Text       string
+}
+
+// labelNode is a node of the label tree.
+type labelNode struct {
+	// Name is the last part of the label name split by "/".
+	Name     string
+	Color    *color
+	Children []*labelNode
+}
+
+func (r Report) title() string {
+	if r.Title == "" {
+		return DefaultTitle
+	}
+	return r.Title
+}
+
+// groups returns the filters grouped by their destinations. Groups of
+// labels come first in the order of label names, followed by inbox,
+// archive and trash. The filters in each group are sorted by their
+// criteria.
+func (r Report) groups() []group {
+	colors := r.colors()
+	byLabel := map[string][]rule{}
+	special := map[string][]rule{}
+	for i, f := range r.Filters {
+		rl := rule{
+			Criteria: f.Criteria.String(),
+			Action:   f.Action.String(),
+		}
+		if r.Counts != nil && i < len(r.Counts) {
+			rl.Count = r.Counts[i]
+			rl.HasCount = true
+		}
+		switch {
+		case f.Action.Delete:
+			special[headingTrash] = append(special[headingTrash], rl)
+		case f.Action.AddLabel != "":
+			byLabel[f.Action.AddLabel] = append(byLabel[f.Action.AddLabel], rl)
+		case f.Action.Archive:
+			special[headingArchive] = append(special[headingArchive], rl)
+		default:
+			special[headingInbox] = append(special[headingInbox], rl)
+		}
+	}
+
+	labels := make([]string, 0, len(byLabel))
+	for label := range byLabel {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	var groups []group
+	for _, label := range labels {
+		groups = append(groups, group{
+			Heading: label,
+			Color:   colors[label],
+			Rules:   sortRules(byLabel[label]),
+		})
+	}
+	for _, heading := range []string{headingInbox, headingArchive, headingTrash} {
+		if rules, ok := special[heading]; ok {
+			groups = append(groups, group{
+				Heading: heading,
+				Rules:   sortRules(rules),
+			})
+		}
+	}
+	return groups
+}
+
+func sortRules(rules []rule) []rule {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Criteria != rules[j].Criteria {
+			return rules[i].Criteria < rules[j].Criteria
+		}
+		return rules[i].Action < rules[j].Action
+	})
+	return rules
+}
+
+// colors returns the colors of labels by their names. Invalid colors are
+// ignored, as they are written in the report as they are.
+func (r Report) colors() map[string]*color {
+	colors := map[string]*color{}
+	for _, l := range r.Labels {
+		if colorPattern.MatchString(l.BackgroundColor) && colorPattern.MatchString(l.TextColor) {
+			colors[l.Name] = &color{
+				Background: strings.ToLower(l.BackgroundColor),
+				Text:       strings.ToLower(l.TextColor),
+			}
+		}
+	}
+	return colors
+}
+
+// labelTree returns the tree of the user labels. Parent labels which
+// do not exist in Gmail are added without colors.
+func (r Report) labelTree() []*labelNode {
+	colors := r.colors()
+	names := make([]string, 0, len(r.Labels))
+	for _, l := range r.Labels {
+		if l.Type != "system" {
+			names = append(names, l.Name)
+		}
+	}
+	sort.Strings(names)
+
+	root := &labelNode{}
+	nodes := map[string]*labelNode{}
+	for _, name := range names {
+		parent := root
+		parts := strings.Split(name, "/")
+		for i, part := range parts {
+			path := strings.Join(parts[:i+1], "/")
+			node, ok := nodes[path]
+			if !ok {
+				node = &labelNode{Name: part}
+				nodes[path] = node
+				parent.Children = append(parent.Children, node)
+			}
+			parent = node
+		}
+		parent.Color = colors[name]
+	}
+	sortTree(root.Children)
+	return root.Children
+}
+
+func sortTree(nodes []*labelNode) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	for _, node := range nodes {
+		sortTree(node.Children)
+	}
+}
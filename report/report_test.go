@@ -0,0 +1,187 @@
+package report
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+var testReport = Report{
+	Filters: []gmail.Filter{
+		{
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"ci@example.com"}},
+			Action:   gmail.FilterAction{AddLabel: "Work/CI", Archive: true},
+		},
+		{
+			Criteria: gmail.FilterCriteria{Subject: gmail.Terms{"[spam] *sale*"}},
+			Action:   gmail.FilterAction{Delete: true},
+		},
+		{
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"alerts@example.com"}},
+			Action:   gmail.FilterAction{AddLabel: "Work/CI", Star: true},
+		},
+		{
+			Criteria: gmail.FilterCriteria{To: gmail.Terms{"me@example.com"}},
+			Action:   gmail.FilterAction{Important: gmail.FilterActionImportantAlways},
+		},
+		{
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"news@example.com"}},
+			Action:   gmail.FilterAction{AddLabel: "News <daily>"},
+		},
+	},
+	Counts: []int64{120, 3, 7, 42, 0},
+	Labels: []gmail.Label{
+		{ID: "INBOX", Name: "INBOX", Type: "system"},
+		{ID: "Label_1", Name: "Work/CI", Type: "user", BackgroundColor: "#16A766", TextColor: "#FFFFFF"},
+		{ID: "Label_2", Name: "News <daily>", Type: "user", BackgroundColor: "red", TextColor: "#000000"},
+		{ID: "Label_3", Name: "Work/Docs", Type: "user"},
+	},
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	tests := []struct {
+		label  string
+		report Report
+		want   string
+	}{
+		{
+			label:  "with counts and labels",
+			report: testReport,
+			want: `# Mail Filters
+
+## Labels
+
+- News &lt;daily>
+- Work
+  - CI (` + "`#ffffff` on `#16a766`" + `)
+  - Docs
+
+## News &lt;daily>
+
+| Criteria | Action | Messages |
+| --- | --- | ---: |
+| from:news@example.com | Apply label "News &lt;daily>" | 0 |
+
+## Work/CI
+
+Color: ` + "`#ffffff` on `#16a766`" + `
+
+| Criteria | Action | Messages |
+| --- | --- | ---: |
+| from:alerts@example.com | Star it, Apply label "Work/CI" | 7 |
+| from:ci@example.com | Skip Inbox, Apply label "Work/CI" | 120 |
+
+## Inbox (no label)
+
+| Criteria | Action | Messages |
+| --- | --- | ---: |
+| to:me@example.com | Mark it as important | 42 |
+
+## Trash
+
+| Criteria | Action | Messages |
+| --- | --- | ---: |
+| subject:(\[spam\] \*sale\*) | Delete it | 3 |
+`,
+		},
+		{
+			label: "without counts and labels",
+			report: Report{
+				Title:   "Where mail goes",
+				Filters: testReport.Filters[:2],
+			},
+			want: `# Where mail goes
+
+## Work/CI
+
+| Criteria | Action |
+| --- | --- |
+| from:ci@example.com | Skip Inbox, Apply label "Work/CI" |
+
+## Trash
+
+| Criteria | Action |
+| --- | --- |
+| subject:(\[spam\] \*sale\*) | Delete it |
+`,
+		},
+		{
+			label:  "no filters",
+			report: Report{},
+			want: `# Mail Filters
+
+No filters.
+`,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteMarkdown(&buf, tt.report); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("unexpected output:\n%s\nwant:\n%s", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, testReport); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"<title>Mail Filters</title>",
+		"<li>News &lt;daily&gt;</li>\n<li>Work\n<ul>\n<li><span class=\"label\" style=\"background-color: #16a766; color: #ffffff\">CI</span></li>\n<li>Docs</li>\n</ul></li>",
+		"<h2>News &lt;daily&gt;</h2>",
+		"<h2><span class=\"label\" style=\"background-color: #16a766; color: #ffffff\">Work/CI</span></h2>",
+		"<tr><td><code>from:alerts@example.com</code></td><td>Star it, Apply label &#34;Work/CI&#34;</td><td class=\"count\">7</td></tr>\n<tr><td><code>from:ci@example.com</code></td>",
+		"<h2>Trash</h2>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+			return
+		}
+	}
+	if strings.Contains(got, "red") {
+		t.Errorf("invalid color should be ignored:\n%s", got)
+		return
+	}
+}
+
+func TestReportIsStable(t *testing.T) {
+	reversed := testReport
+	reversed.Filters = make([]gmail.Filter, len(testReport.Filters))
+	reversed.Counts = make([]int64, len(testReport.Counts))
+	reversed.Labels = make([]gmail.Label, len(testReport.Labels))
+	for i := range testReport.Filters {
+		reversed.Filters[len(testReport.Filters)-1-i] = testReport.Filters[i]
+		reversed.Counts[len(testReport.Counts)-1-i] = testReport.Counts[i]
+	}
+	for i := range testReport.Labels {
+		reversed.Labels[len(testReport.Labels)-1-i] = testReport.Labels[i]
+	}
+
+	for _, write := range []func(io.Writer, Report) error{WriteMarkdown, WriteHTML} {
+		var want, got bytes.Buffer
+		if err := write(&want, testReport); err != nil {
+			t.Fatal(err)
+		}
+		if err := write(&got, reversed); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("output depends on the order of filters:\n%s\nwant:\n%s", got.String(), want.String())
+			return
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Package schema generates JSON Schema of resource files from Go types,
+// and validates decoded resource files against the schema.
+package schema
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Draft is the JSON Schema draft which the generated schema conforms to.
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a JSON Schema.
+// Only the keywords required to describe resource files are supported.
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Type  string        `json:"type,omitempty"`
+	Enum  []interface{} `json:"enum,omitempty"`
+	Const interface{}   `json:"const,omitempty"`
+
+	// Minimum is the minimum value of a number.
+	Minimum *float64 `json:"minimum,omitempty"`
+
+	Items *Schema `json:"items,omitempty"`
+
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	// AdditionalProperties is false if no other properties are allowed,
+	// or the schema of the values of other properties.
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+
+	OneOf []*Schema `json:"oneOf,omitempty"`
+	Not   *Schema   `json:"not,omitempty"`
+
+	Definitions map[string]*Schema `json:"definitions,omitempty"`
+}
+
+// Schemer is implemented by types which describe their own schema.
+type Schemer interface {
+	JSONSchema() *Schema
+}
+
+// Extender is implemented by struct types which modify the schema
+// generated from their fields, e.g. to add enums or constraints.
+type Extender interface {
+	JSONSchemaExtend(s *Schema)
+}
+
+// Float returns a pointer to given value, which is used to set Minimum.
+func Float(v float64) *float64 {
+	return &v
+}
+
+var (
+	schemerType  = reflect.TypeOf((*Schemer)(nil)).Elem()
+	extenderType = reflect.TypeOf((*Extender)(nil)).Elem()
+)
+
+// Generate generates the schema of given type from its yaml struct tags.
+// Named struct types other than the root are placed in definitions and
+// referred by $ref, so recursive types are supported.
+func Generate(t reflect.Type) *Schema {
+	g := generator{definitions: map[string]*Schema{}}
+	s := g.structSchema(t)
+	s.Schema = Draft
+	if len(g.definitions) > 0 {
+		s.Definitions = g.definitions
+	}
+	return s
+}
+
+type generator struct {
+	definitions map[string]*Schema
+}
+
+func (g *generator) schemaOf(t reflect.Type) *Schema {
+	if t.Implements(schemerType) {
+		return reflect.Zero(t).Interface().(Schemer).JSONSchema()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaOf(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		ref := &Schema{Ref: "#/definitions/" + t.Name()}
+		if _, ok := g.definitions[t.Name()]; !ok {
+			// placeholder for recursive types
+			g.definitions[t.Name()] = &Schema{}
+			*g.definitions[t.Name()] = *g.structSchema(t)
+		}
+		return ref
+	}
+	return &Schema{}
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:                 "object",
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: false,
+	}
+	g.addFields(s, t)
+	if t.Implements(extenderType) {
+		reflect.Zero(t).Interface().(Extender).JSONSchemaExtend(s)
+	}
+	return s
+}
+
+func (g *generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		if hasOption(opts, "inline") {
+			g.addFields(s, field.Type)
+			continue
+		}
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		s.Properties[name] = g.schemaOf(field.Type)
+		if !hasOption(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func hasOption(opts, name string) bool {
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the schema of the value at given path, whose elements
+// are property names (string) or array indices (int). References are
+// resolved with the definitions of the schema. nil is returned if the
+// path is not defined in the schema.
+func (s *Schema) Lookup(path ...interface{}) *Schema {
+	cur := s
+	for _, elem := range path {
+		cur = s.resolve(cur)
+		switch elem := elem.(type) {
+		case string:
+			if prop, ok := cur.Properties[elem]; ok {
+				cur = prop
+			} else if additional, ok := cur.AdditionalProperties.(*Schema); ok {
+				cur = additional
+			} else {
+				return nil
+			}
+		case int:
+			if cur.Items == nil {
+				return nil
+			}
+			cur = cur.Items
+		default:
+			return nil
+		}
+	}
+	return s.resolve(cur)
+}
+
+// resolve resolves the reference of given schema with the definitions
+// of s.
+func (s *Schema) resolve(ref *Schema) *Schema {
+	for ref.Ref != "" {
+		def, ok := s.Definitions[strings.TrimPrefix(ref.Ref, "#/definitions/")]
+		if !ok {
+			return &Schema{}
+		}
+		ref = def
+	}
+	return ref
+}
@@ -0,0 +1,177 @@
+package schema
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type testColor string
+
+func (testColor) JSONSchema() *Schema {
+	return &Schema{Type: "string", Enum: []interface{}{"red", "blue"}}
+}
+
+type testNode struct {
+	Name     string     `yaml:"name"`
+	Color    testColor  `yaml:"color,omitempty"`
+	Size     int64      `yaml:"size,omitempty"`
+	Children []testNode `yaml:"children,omitempty"`
+	ignored  string
+}
+
+func (testNode) JSONSchemaExtend(s *Schema) {
+	s.Properties["size"].Minimum = Float(0)
+}
+
+type testRoot struct {
+	Kind     string `yaml:"kind"`
+	testBody `yaml:",inline"`
+}
+
+type testBody struct {
+	Nodes  []testNode        `yaml:"nodes"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+	Skip   string            `yaml:"-"`
+}
+
+func TestGenerate(t *testing.T) {
+	got := Generate(reflect.TypeOf(testRoot{}))
+	want := &Schema{
+		Schema: Draft,
+		Type:   "object",
+		Properties: map[string]*Schema{
+			"kind":   {Type: "string"},
+			"nodes":  {Type: "array", Items: &Schema{Ref: "#/definitions/testNode"}},
+			"labels": {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+		},
+		Required:             []string{"kind", "nodes"},
+		AdditionalProperties: false,
+		Definitions: map[string]*Schema{
+			"testNode": {
+				Type: "object",
+				Properties: map[string]*Schema{
+					"name":     {Type: "string"},
+					"color":    {Type: "string", Enum: []interface{}{"red", "blue"}},
+					"size":     {Type: "integer", Minimum: Float(0)},
+					"children": {Type: "array", Items: &Schema{Ref: "#/definitions/testNode"}},
+				},
+				Required:             []string{"name"},
+				AdditionalProperties: false,
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%+v != %+v", got, want)
+		return
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := Generate(reflect.TypeOf(testRoot{}))
+	tests := []struct {
+		label string
+		input interface{}
+		want  []string
+	}{
+		{
+			label: "valid",
+			input: map[string]interface{}{
+				"kind": "Test",
+				"nodes": []interface{}{
+					map[string]interface{}{"name": "foo", "color": "red", "size": uint64(1)},
+				},
+				"labels": map[string]interface{}{"a": "b"},
+			},
+		},
+		{
+			label: "required",
+			input: map[string]interface{}{
+				"kind": "Test",
+			},
+			want: []string{"required key `nodes` not found"},
+		},
+		{
+			label: "nested errors",
+			input: map[string]interface{}{
+				"kind": "Test",
+				"nodes": []interface{}{
+					map[string]interface{}{
+						"name": "foo",
+						"children": []interface{}{
+							map[string]interface{}{"name": true, "colour": "red", "size": int64(-1)},
+						},
+					},
+					map[string]interface{}{"name": "bar", "color": "green"},
+				},
+				"labels": map[string]interface{}{"a": uint64(1)},
+			},
+			want: []string{
+				"labels.a: must be a string, but number",
+				"nodes.0.children.0.colour: unknown key `colour`",
+				"nodes.0.children.0.name: must be a string, but boolean",
+				"nodes.0.children.0.size: must be greater than or equal to 0",
+				"nodes.1.color: unknown value green, must be one of: red, blue",
+			},
+		},
+		{
+			label: "type mismatch",
+			input: []interface{}{},
+			want:  []string{"must be an object, but array"},
+		},
+	}
+	for i, tt := range tests {
+		var got []string
+		for _, err := range s.Validate(tt.input) {
+			got = append(got, err.Error())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %q != %q", strconv.Itoa(i)+"."+tt.label, got, tt.want)
+			return
+		}
+	}
+}
+
+func TestValidateOneOfAndNot(t *testing.T) {
+	s := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"terms": {OneOf: []*Schema{{Type: "string"}, {Type: "array", Items: &Schema{Type: "string"}}}},
+		},
+		Not: &Schema{Description: "a and b cannot be used together", Required: []string{"a", "b"}},
+	}
+	tests := []struct {
+		label string
+		input interface{}
+		want  []string
+	}{
+		{
+			label: "string",
+			input: map[string]interface{}{"terms": "foo"},
+		},
+		{
+			label: "array",
+			input: map[string]interface{}{"terms": []interface{}{"foo", "bar"}},
+		},
+		{
+			label: "invalid",
+			input: map[string]interface{}{"terms": []interface{}{uint64(1)}},
+			want:  []string{"terms: must be one of: string, array of string"},
+		},
+		{
+			label: "not",
+			input: map[string]interface{}{"a": 1, "b": 2},
+			want:  []string{"a and b cannot be used together"},
+		},
+	}
+	for i, tt := range tests {
+		var got []string
+		for _, err := range s.Validate(tt.input) {
+			got = append(got, err.Error())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %q != %q", strconv.Itoa(i)+"."+tt.label, got, tt.want)
+			return
+		}
+	}
+}
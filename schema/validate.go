@@ -0,0 +1,242 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ValidationError is an error of a value which does not conform to the
+// schema.
+type ValidationError struct {
+	// Path is the path to the invalid value, whose elements are
+	// property names (string) or array indices (int).
+	Path    []interface{}
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	elems := make([]string, 0, len(e.Path))
+	for _, elem := range e.Path {
+		elems = append(elems, fmt.Sprint(elem))
+	}
+	return strings.Join(elems, ".") + ": " + e.Message
+}
+
+// Validate validates given value, which is decoded from YAML or JSON
+// into interface{}, against the schema. All errors found are returned.
+func (s *Schema) Validate(v interface{}) []*ValidationError {
+	vd := validator{root: s}
+	vd.validate(s, v, nil)
+	return vd.errors
+}
+
+type validator struct {
+	root   *Schema
+	errors []*ValidationError
+}
+
+func (vd *validator) errorf(path []interface{}, format string, args ...interface{}) {
+	vd.errors = append(vd.errors, &ValidationError{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (vd *validator) resolve(s *Schema) *Schema {
+	return vd.root.resolve(s)
+}
+
+// matches reports whether given value conforms to the schema.
+func (vd *validator) matches(s *Schema, v interface{}) bool {
+	sub := validator{root: vd.root}
+	sub.validate(s, v, nil)
+	return len(sub.errors) == 0
+}
+
+func (vd *validator) validate(s *Schema, v interface{}, path []interface{}) {
+	s = vd.resolve(s)
+
+	if len(s.OneOf) > 0 {
+		n := 0
+		var types []string
+		for _, alt := range s.OneOf {
+			if vd.matches(alt, v) {
+				n++
+			}
+			types = append(types, describe(vd.resolve(alt)))
+		}
+		if n != 1 {
+			vd.errorf(path, "must be one of: %s", strings.Join(types, ", "))
+			return
+		}
+	}
+	if s.Not != nil && vd.matches(s.Not, v) {
+		msg := s.Not.Description
+		if msg == "" {
+			msg = "must not match " + describe(vd.resolve(s.Not))
+		}
+		vd.errorf(path, "%s", msg)
+	}
+
+	if s.Type != "" && !isType(v, s.Type) {
+		vd.errorf(path, "must be %s, but %s", withArticle(s.Type), typeName(v))
+		return
+	}
+	if s.Const != nil && !equal(s.Const, v) {
+		vd.errorf(path, "must be %v", s.Const)
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if equal(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values := make([]string, 0, len(s.Enum))
+			for _, e := range s.Enum {
+				values = append(values, fmt.Sprint(e))
+			}
+			vd.errorf(path, "unknown value %v, must be one of: %s", v, strings.Join(values, ", "))
+		}
+	}
+	if s.Minimum != nil {
+		if n, ok := toFloat(v); ok && n < *s.Minimum {
+			vd.errorf(path, "must be greater than or equal to %v", *s.Minimum)
+		}
+	}
+
+	switch v := v.(type) {
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				vd.validate(s.Items, item, appendPath(path, i))
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				vd.errorf(path, "required key `%s` not found", name)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if prop, ok := s.Properties[key]; ok {
+				vd.validate(prop, v[key], appendPath(path, key))
+				continue
+			}
+			switch additional := s.AdditionalProperties.(type) {
+			case bool:
+				if !additional {
+					vd.errorf(appendPath(path, key), "unknown key `%s`", key)
+				}
+			case *Schema:
+				vd.validate(additional, v[key], appendPath(path, key))
+			}
+		}
+	}
+}
+
+func appendPath(path []interface{}, elem interface{}) []interface{} {
+	p := make([]interface{}, 0, len(path)+1)
+	p = append(p, path...)
+	return append(p, elem)
+}
+
+// describe returns short description of the schema for error messages.
+func describe(s *Schema) string {
+	switch {
+	case s.Type == "array" && s.Items != nil && s.Items.Type != "":
+		return "array of " + s.Items.Type
+	case s.Type != "":
+		return s.Type
+	case len(s.Required) > 0:
+		return "object with " + strings.Join(s.Required, ", ")
+	}
+	return "schema"
+}
+
+func isType(v interface{}, typ string) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "integer":
+		n, ok := toFloat(v)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := toFloat(v)
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "null":
+		return v == nil
+	}
+	return true
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	if _, ok := toFloat(v); ok {
+		return "number"
+	}
+	return reflect.TypeOf(v).String()
+}
+
+func withArticle(typ string) string {
+	switch typ[0] {
+	case 'a', 'i', 'o':
+		return "an " + typ
+	}
+	return "a " + typ
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func equal(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
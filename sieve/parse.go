@@ -0,0 +1,549 @@
+package sieve
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/query"
+)
+
+// SyntaxError is an error on parsing Sieve script, including the parts
+// of Sieve which are not supported.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("sieve: %s at line %d", e.Msg, e.Line)
+}
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdentifier
+	tokenTag // e.g. `:contains`
+	tokenString
+	tokenNumber
+	tokenLBracket
+	tokenRBracket
+	tokenLParen
+	tokenRParen
+	tokenLBrace
+	tokenRBrace
+	tokenComma
+	tokenSemicolon
+)
+
+var tokenNames = map[tokenType]string{
+	tokenEOF:        "end of script",
+	tokenIdentifier: "identifier",
+	tokenTag:        "tag",
+	tokenString:     "string",
+	tokenNumber:     "number",
+	tokenLBracket:   "`[`",
+	tokenRBracket:   "`]`",
+	tokenLParen:     "`(`",
+	tokenRParen:     "`)`",
+	tokenLBrace:     "`{`",
+	tokenRBrace:     "`}`",
+	tokenComma:      "`,`",
+	tokenSemicolon:  "`;`",
+}
+
+type token struct {
+	typ   tokenType
+	value string
+	line  int
+}
+
+// quantifiers maps quantifiers of numbers to the multipliers.
+var quantifiers = map[byte]int64{
+	'K': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return nil, &SyntaxError{Line: line, Msg: "unclosed comment"}
+			}
+			line += strings.Count(s[i:i+2+end], "\n")
+			i += end + 4
+		case c == '"':
+			var b strings.Builder
+			start := line
+			i++
+			for {
+				if i >= len(s) {
+					return nil, &SyntaxError{Line: start, Msg: "unclosed string"}
+				}
+				if s[i] == '"' {
+					i++
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				if s[i] == '\n' {
+					line++
+				}
+				b.WriteByte(s[i])
+				i++
+			}
+			tokens = append(tokens, token{typ: tokenString, value: b.String(), line: start})
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				i++
+			}
+			n, err := strconv.ParseInt(s[start:i], 10, 64)
+			if err != nil {
+				return nil, &SyntaxError{Line: line, Msg: "invalid number " + s[start:i]}
+			}
+			if i < len(s) {
+				if q, ok := quantifiers[upper(s[i])]; ok {
+					n *= q
+					i++
+				}
+			}
+			tokens = append(tokens, token{typ: tokenNumber, value: strconv.FormatInt(n, 10), line: line})
+		case c == ':' || isIdentifierChar(c):
+			start := i
+			i++
+			for i < len(s) && isIdentifierChar(s[i]) {
+				i++
+			}
+			typ := tokenIdentifier
+			if c == ':' {
+				typ = tokenTag
+			}
+			value := strings.ToLower(s[start:i])
+			if value == "text" && i < len(s) && s[i] == ':' {
+				return nil, &SyntaxError{Line: line, Msg: "multi-line string is not supported"}
+			}
+			tokens = append(tokens, token{typ: typ, value: value, line: line})
+		default:
+			typ, ok := map[byte]tokenType{
+				'[': tokenLBracket,
+				']': tokenRBracket,
+				'(': tokenLParen,
+				')': tokenRParen,
+				'{': tokenLBrace,
+				'}': tokenRBrace,
+				',': tokenComma,
+				';': tokenSemicolon,
+			}[c]
+			if !ok {
+				return nil, &SyntaxError{Line: line, Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+			tokens = append(tokens, token{typ: typ, line: line})
+			i++
+		}
+	}
+	return append(tokens, token{typ: tokenEOF, line: line}), nil
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+// Parse parses a Sieve script into filters. Each `if` command at the top
+// level of the script is parsed as a filter. The supported subset is:
+//
+//   - tests: header, address, size, allof, anyof, not and true
+//   - actions: fileinto, addflag, setflag, redirect, discard, keep and stop
+//
+// Parts which are parsed but cannot be represented exactly are returned
+// as warnings.
+func Parse(r io.Reader) (filters []gmail.Filter, warnings []string, err error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens, err := tokenize(string(b))
+	if err != nil {
+		return nil, nil, err
+	}
+	p := parser{tokens: tokens}
+	for p.peek().typ != tokenEOF {
+		f, ok, err := p.parseCommand()
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			filters = append(filters, f)
+		}
+	}
+	return filters, p.warnings, nil
+}
+
+type parser struct {
+	tokens   []token
+	pos      int
+	warnings []string
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &SyntaxError{Line: t.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) warnf(t token, format string, args ...interface{}) {
+	p.warnings = append(p.warnings, fmt.Sprintf("line %d: ", t.line)+fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expect(typ tokenType) (token, error) {
+	t := p.next()
+	if t.typ != typ {
+		return t, p.errorf(t, "expected %s, but got %s", tokenNames[typ], describe(t))
+	}
+	return t, nil
+}
+
+func describe(t token) string {
+	switch t.typ {
+	case tokenIdentifier, tokenTag:
+		return "`" + t.value + "`"
+	case tokenString:
+		return strconv.Quote(t.value)
+	}
+	return tokenNames[t.typ]
+}
+
+// parseCommand parses a command at the top level. ok is true if the
+// command is parsed as a filter.
+func (p *parser) parseCommand() (f gmail.Filter, ok bool, err error) {
+	t, err := p.expect(tokenIdentifier)
+	if err != nil {
+		return f, false, err
+	}
+	switch t.value {
+	case "require":
+		if _, err := p.parseStringList(); err != nil {
+			return f, false, err
+		}
+		_, err := p.expect(tokenSemicolon)
+		return f, false, err
+	case "if":
+		f.Criteria, err = p.parseTest()
+		if err != nil {
+			return f, false, err
+		}
+		f.Action, err = p.parseBlock()
+		if err != nil {
+			return f, false, err
+		}
+		if t := p.peek(); t.typ == tokenIdentifier && (t.value == "elsif" || t.value == "else") {
+			return f, false, p.errorf(t, "%s is not supported", t.value)
+		}
+		return f, true, nil
+	case "keep", "stop":
+		p.warnf(t, "%s outside of if is ignored", t.value)
+		_, err := p.expect(tokenSemicolon)
+		return f, false, err
+	}
+	return f, false, p.errorf(t, "%s outside of if is not supported", t.value)
+}
+
+func (p *parser) parseStringList() ([]string, error) {
+	t := p.next()
+	switch t.typ {
+	case tokenString:
+		return []string{t.value}, nil
+	case tokenLBracket:
+		var ss []string
+		for {
+			s, err := p.expect(tokenString)
+			if err != nil {
+				return nil, err
+			}
+			ss = append(ss, s.value)
+			t := p.next()
+			if t.typ == tokenRBracket {
+				return ss, nil
+			}
+			if t.typ != tokenComma {
+				return nil, p.errorf(t, "expected `,` or `]`, but got %s", describe(t))
+			}
+		}
+	}
+	return nil, p.errorf(t, "expected string or string list, but got %s", describe(t))
+}
+
+func (p *parser) parseTest() (gmail.FilterCriteria, error) {
+	t, err := p.expect(tokenIdentifier)
+	if err != nil {
+		return gmail.FilterCriteria{}, err
+	}
+	switch t.value {
+	case "true":
+		return gmail.FilterCriteria{}, nil
+	case "not":
+		c, err := p.parseTest()
+		if err != nil {
+			return c, err
+		}
+		return gmail.FilterCriteria{NoneOf: []gmail.FilterCriteria{c}}, nil
+	case "allof", "anyof":
+		tests, err := p.parseTestList()
+		if err != nil {
+			return gmail.FilterCriteria{}, err
+		}
+		if len(tests) == 1 {
+			return tests[0], nil
+		}
+		if t.value == "anyof" {
+			return gmail.FilterCriteria{AnyOf: tests}, nil
+		}
+		return mergeCriteria(tests), nil
+	case "header", "address":
+		return p.parseHeaderTest(t)
+	case "size":
+		tag, err := p.expect(tokenTag)
+		if err != nil {
+			return gmail.FilterCriteria{}, err
+		}
+		n, err := p.expect(tokenNumber)
+		if err != nil {
+			return gmail.FilterCriteria{}, err
+		}
+		size, _ := strconv.ParseInt(n.value, 10, 64)
+		switch tag.value {
+		case ":over":
+			return gmail.FilterCriteria{LargerThan: size}, nil
+		case ":under":
+			return gmail.FilterCriteria{SmallerThan: size}, nil
+		}
+		return gmail.FilterCriteria{}, p.errorf(tag, "unknown tag %s for size", tag.value)
+	}
+	return gmail.FilterCriteria{}, p.errorf(t, "test %s is not supported", t.value)
+}
+
+func (p *parser) parseTestList() ([]gmail.FilterCriteria, error) {
+	if _, err := p.expect(tokenLParen); err != nil {
+		return nil, err
+	}
+	var tests []gmail.FilterCriteria
+	for {
+		c, err := p.parseTest()
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, c)
+		t := p.next()
+		if t.typ == tokenRParen {
+			return tests, nil
+		}
+		if t.typ != tokenComma {
+			return nil, p.errorf(t, "expected `,` or `)`, but got %s", describe(t))
+		}
+	}
+}
+
+func (p *parser) parseHeaderTest(test token) (gmail.FilterCriteria, error) {
+	for p.peek().typ == tokenTag {
+		tag := p.next()
+		switch tag.value {
+		case ":contains", ":all":
+		case ":is":
+			p.warnf(tag, ":is is translated as :contains")
+		case ":comparator":
+			if _, err := p.expect(tokenString); err != nil {
+				return gmail.FilterCriteria{}, err
+			}
+		default:
+			return gmail.FilterCriteria{}, p.errorf(tag, "%s is not supported", tag.value)
+		}
+	}
+	names, err := p.parseStringList()
+	if err != nil {
+		return gmail.FilterCriteria{}, err
+	}
+	keys, err := p.parseStringList()
+	if err != nil {
+		return gmail.FilterCriteria{}, err
+	}
+
+	terms := gmail.Terms(keys)
+	if len(keys) == 1 {
+		// a single term is passed to Gmail as-is
+		terms = gmail.Terms{query.Term(keys[0]).String()}
+	}
+	var fields []gmail.FilterCriteria
+	seen := map[string]bool{}
+	for _, name := range names {
+		var c gmail.FilterCriteria
+		switch strings.ToLower(name) {
+		case "from":
+			c.From = terms
+		case "to", "cc", "bcc":
+			c.To = terms
+		case "subject":
+			c.Subject = terms
+		default:
+			return gmail.FilterCriteria{}, p.errorf(test, "header %s is not supported", name)
+		}
+		if key := fmt.Sprint(c); !seen[key] {
+			seen[key] = true
+			fields = append(fields, c)
+		}
+	}
+	if len(fields) == 1 {
+		return fields[0], nil
+	}
+	return gmail.FilterCriteria{AnyOf: fields}, nil
+}
+
+// mergeCriteria returns criteria matching all of given criteria. The
+// criteria are merged into one if they have no fields in common.
+func mergeCriteria(criteria []gmail.FilterCriteria) gmail.FilterCriteria {
+	var merged gmail.FilterCriteria
+	dst := reflect.ValueOf(&merged).Elem()
+	for _, c := range criteria {
+		src := reflect.ValueOf(c)
+		for i := 0; i < src.NumField(); i++ {
+			if src.Field(i).IsZero() {
+				continue
+			}
+			if !dst.Field(i).IsZero() {
+				return gmail.FilterCriteria{AllOf: criteria}
+			}
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+	return merged
+}
+
+func (p *parser) parseBlock() (gmail.FilterAction, error) {
+	var action gmail.FilterAction
+	if _, err := p.expect(tokenLBrace); err != nil {
+		return action, err
+	}
+	var keep, cancelKeep bool
+	for {
+		t := p.next()
+		if t.typ == tokenRBrace {
+			break
+		}
+		if t.typ != tokenIdentifier {
+			return action, p.errorf(t, "expected action, but got %s", describe(t))
+		}
+		tags, err := p.parseTags()
+		if err != nil {
+			return action, err
+		}
+		copied := tags[":copy"]
+		switch t.value {
+		case "fileinto", "redirect":
+			s, err := p.expect(tokenString)
+			if err != nil {
+				return action, err
+			}
+			dst := &action.AddLabel
+			if t.value == "redirect" {
+				dst = &action.ForwardTo
+			}
+			if *dst != "" {
+				return action, p.errorf(t, "multiple %s is not supported", t.value)
+			}
+			*dst = s.value
+			if !copied {
+				cancelKeep = true
+			}
+		case "addflag", "setflag":
+			var flags []string
+			for p.peek().typ != tokenSemicolon {
+				ss, err := p.parseStringList()
+				if err != nil {
+					return action, err
+				}
+				// the last argument is the flags, and the preceding
+				// one is the variable name
+				flags = ss
+			}
+			for _, s := range flags {
+				for _, flag := range strings.Fields(s) {
+					switch strings.ToLower(flag) {
+					case `\seen`:
+						action.MarkAsRead = true
+					case `\flagged`:
+						action.Star = true
+					default:
+						p.warnf(t, "flag %s is ignored", flag)
+					}
+				}
+			}
+		case "discard":
+			action.Delete = true
+		case "keep":
+			keep = true
+		case "stop":
+			p.warnf(t, "stop is ignored, as all of Gmail filters are applied")
+		default:
+			return action, p.errorf(t, "action %s is not supported", t.value)
+		}
+		if _, err := p.expect(tokenSemicolon); err != nil {
+			return action, err
+		}
+	}
+	action.Archive = cancelKeep && !keep && !action.Delete
+	return action, nil
+}
+
+// parseTags parses tagged arguments of an action.
+func (p *parser) parseTags() (map[string]bool, error) {
+	tags := map[string]bool{}
+	for p.peek().typ == tokenTag {
+		tag := p.next()
+		switch tag.value {
+		case ":copy":
+		case ":create":
+			// fileinto :create of mailbox extension
+		default:
+			return nil, p.errorf(tag, "%s is not supported", tag.value)
+		}
+		tags[tag.value] = true
+	}
+	return tags, nil
+}
@@ -0,0 +1,137 @@
+package sieve
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		label    string
+		script   string
+		want     []gmail.Filter
+		warnings []string
+	}{
+		{
+			label: "header and address",
+			script: `require ["fileinto", "imap4flags"];
+# comment
+if address :is :all "From" "foo@example.com" {
+    setflag "\\Seen \\Flagged";
+    fileinto :copy "foo";
+}`,
+			want: []gmail.Filter{{
+				Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+				Action:   gmail.FilterAction{AddLabel: "foo", MarkAsRead: true, Star: true},
+			}},
+			warnings: []string{"line 3: :is is translated as :contains"},
+		},
+		{
+			label: "allof is merged",
+			script: `if allof(header :contains "subject" ["foo", "bar baz"], size :over 1K, header :contains "to" "me") {
+    fileinto "x";
+}`,
+			want: []gmail.Filter{{
+				Criteria: gmail.FilterCriteria{
+					To:         gmail.Terms{"me"},
+					Subject:    gmail.Terms{"foo", "bar baz"},
+					LargerThan: 1024,
+				},
+				Action: gmail.FilterAction{AddLabel: "x", Archive: true},
+			}},
+		},
+		{
+			label: "allof with the same field",
+			script: `if allof(header :contains "subject" "foo", header :contains "subject" "bar") { discard; }
+/* block
+comment */
+if true { stop; }`,
+			want: []gmail.Filter{
+				{
+					Criteria: gmail.FilterCriteria{AllOf: []gmail.FilterCriteria{
+						{Subject: gmail.Terms{"foo"}},
+						{Subject: gmail.Terms{"bar"}},
+					}},
+					Action: gmail.FilterAction{Delete: true},
+				},
+				{},
+			},
+			warnings: []string{"line 4: stop is ignored, as all of Gmail filters are applied"},
+		},
+		{
+			label:  "multiple headers",
+			script: `if header :contains ["from", "to", "cc"] "a b" { redirect "me@example.com"; keep; }`,
+			want: []gmail.Filter{{
+				Criteria: gmail.FilterCriteria{AnyOf: []gmail.FilterCriteria{
+					{From: gmail.Terms{`"a b"`}},
+					{To: gmail.Terms{`"a b"`}},
+				}},
+				Action: gmail.FilterAction{ForwardTo: "me@example.com"},
+			}},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			got, warnings, err := Parse(strings.NewReader(tt.script))
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%+v != %+v", got, tt.want)
+				return
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("%q != %q", warnings, tt.warnings)
+				return
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		label  string
+		script string
+		want   string
+	}{
+		{
+			label:  "unsupported header",
+			script: `if header :contains ["from", "sender"] "foo" { keep; }`,
+			want:   "sieve: header sender is not supported at line 1",
+		},
+		{
+			label:  "unsupported match type",
+			script: `if header :matches "from" "*@example.com" { keep; }`,
+			want:   "sieve: :matches is not supported at line 1",
+		},
+		{
+			label:  "else",
+			script: "if true {\n  keep;\n}\nelse { discard; }",
+			want:   "sieve: else is not supported at line 4",
+		},
+		{
+			label:  "missing semicolon",
+			script: `if true { keep }`,
+			want:   "sieve: expected `;`, but got `}` at line 1",
+		},
+		{
+			label:  "unclosed string",
+			script: `if header :contains "from" "foo { keep; }`,
+			want:   "sieve: unclosed string at line 1",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			_, _, err := Parse(strings.NewReader(tt.script))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("%v != %s", err, tt.want)
+				return
+			}
+		})
+	}
+}
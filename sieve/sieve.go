@@ -0,0 +1,304 @@
+// Package sieve translates filters into Sieve scripts (RFC 5228), and
+// parses a practical subset of Sieve scripts back into filters.
+package sieve
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nasa9084/gmac/gmail"
+	"github.com/nasa9084/gmac/query"
+)
+
+const indent = "    "
+
+// Sieve flags which are used for mark_as_read and star.
+const (
+	flagSeen    = `\Seen`
+	flagFlagged = `\Flagged`
+)
+
+// headerFields maps search operators to the header fields which
+// are tested for the operators.
+var headerFields = map[string][]string{
+	"from":    {"from"},
+	"to":      {"to", "cc", "bcc"},
+	"cc":      {"cc"},
+	"bcc":     {"bcc"},
+	"subject": {"subject"},
+}
+
+var sizePattern = regexp.MustCompile(`^[0-9]+[KMGkmg]?$`)
+
+// Write writes filters as a Sieve script. Filters are translated using
+// fileinto, imap4flags (addflag), redirect and discard. Criteria and
+// actions which cannot be translated are written as comments, and a
+// filter whose criteria cannot be translated is not translated at all,
+// as the rule would match more messages than the filter.
+func Write(w io.Writer, filters []gmail.Filter) error {
+	sw := scriptWriter{requires: map[string]bool{}}
+	for i, f := range filters {
+		if i > 0 {
+			sw.body.WriteString("\n")
+		}
+		if err := sw.writeFilter(i, f); err != nil {
+			return fmt.Errorf("filter #%d: %w", i+1, err)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by gmac.\n")
+	if len(sw.requires) > 0 {
+		requires := make([]string, 0, len(sw.requires))
+		for ext := range sw.requires {
+			requires = append(requires, ext)
+		}
+		sort.Strings(requires)
+		fmt.Fprintf(&b, "require %s;\n", stringList(requires))
+	}
+	b.WriteString("\n")
+	b.WriteString(sw.body.String())
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type scriptWriter struct {
+	body     strings.Builder
+	requires map[string]bool
+}
+
+func (sw *scriptWriter) writeFilter(i int, f gmail.Filter) error {
+	fmt.Fprintf(&sw.body, "# filter #%d: %s\n", i+1, commentText(f.String()))
+
+	criteria := f.Criteria
+	if criteria.ExcludeChats {
+		// there are no chats in Sieve
+		criteria.ExcludeChats = false
+	}
+	test, err := translateTest(criteria.Node())
+	if err != nil {
+		fmt.Fprintf(&sw.body, "# not translated: %s\n", commentText(err.Error()))
+		return nil
+	}
+
+	actions, notes, err := sw.translateAction(f.Action)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&sw.body, "if %s {\n", test)
+	for _, note := range notes {
+		fmt.Fprintf(&sw.body, "%s# not translated: %s\n", indent, commentText(note))
+	}
+	for _, action := range actions {
+		fmt.Fprintf(&sw.body, "%s%s;\n", indent, action)
+	}
+	sw.body.WriteString("}\n")
+	return nil
+}
+
+// translateAction returns Sieve actions of given filter action, and notes
+// of the parts which cannot be translated.
+func (sw *scriptWriter) translateAction(action gmail.FilterAction) (actions, notes []string, err error) {
+	var flags []string
+	if action.MarkAsRead {
+		flags = append(flags, flagSeen)
+	}
+	if action.Star {
+		flags = append(flags, flagFlagged)
+	}
+	if len(flags) > 0 {
+		sw.requires["imap4flags"] = true
+		actions = append(actions, "addflag "+stringList(flags))
+	}
+	if action.AddLabel != "" {
+		sw.requires["fileinto"] = true
+		actions = append(actions, "fileinto "+quote(action.AddLabel))
+	}
+	if action.ForwardTo != "" {
+		actions = append(actions, "redirect "+quote(action.ForwardTo))
+	}
+	switch {
+	case action.Delete:
+		actions = append(actions, "discard")
+	case action.Archive && action.AddLabel == "" && action.ForwardTo == "":
+		notes = append(notes, "archive, as there is no mailbox like All Mail")
+	case !action.Archive && (action.AddLabel != "" || action.ForwardTo != ""):
+		// fileinto and redirect cancel the implicit keep
+		actions = append(actions, "keep")
+	}
+
+	if action.NeverMarkAsSpam {
+		notes = append(notes, "never_mark_as_spam")
+	}
+	switch action.Important {
+	case "":
+	case gmail.FilterActionImportantAlways, gmail.FilterActionImportantNever:
+		notes = append(notes, "important: "+string(action.Important))
+	default:
+		return nil, nil, fmt.Errorf("unknown action.important value: %s", action.Important)
+	}
+	if action.Category != "" {
+		notes = append(notes, "category: "+gmail.CanonicalCategory(action.Category))
+	}
+	return actions, notes, nil
+}
+
+// translateTest translates given search query into a Sieve test.
+func translateTest(n query.Node) (string, error) {
+	switch n := n.(type) {
+	case query.And:
+		switch len(n.Nodes) {
+		case 0:
+			return "true", nil
+		case 1:
+			return translateTest(n.Nodes[0])
+		}
+		tests, err := translateTests(n.Nodes)
+		if err != nil {
+			return "", err
+		}
+		return "allof(" + strings.Join(tests, ", ") + ")", nil
+	case query.Or:
+		tests, err := translateTests(n.Nodes)
+		if err != nil {
+			return "", err
+		}
+		return "anyof(" + strings.Join(tests, ", ") + ")", nil
+	case query.Not:
+		test, err := translateTest(n.Node)
+		if err != nil {
+			return "", err
+		}
+		return "not " + test, nil
+	case query.Field:
+		return translateField(n)
+	}
+	return "", fmt.Errorf("%s cannot be translated, as Sieve cannot search whole messages", n)
+}
+
+func translateTests(nodes []query.Node) ([]string, error) {
+	tests := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		test, err := translateTest(n)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, test)
+	}
+	return tests, nil
+}
+
+func translateField(n query.Field) (string, error) {
+	name := strings.ToLower(n.Name)
+	switch name {
+	case "larger", "smaller":
+		v, ok := n.Value.(query.Word)
+		if !ok || !sizePattern.MatchString(v.Value) {
+			return "", fmt.Errorf("%s cannot be translated", n)
+		}
+		comparator := ":over"
+		if name == "smaller" {
+			comparator = ":under"
+		}
+		return "size " + comparator + " " + strings.ToUpper(v.Value), nil
+	}
+
+	fields, ok := headerFields[name]
+	if !ok {
+		return "", fmt.Errorf("%s cannot be translated, as Sieve has no equivalent of %s:", n, n.Name)
+	}
+	test, err := headerTest(fields, n.Value)
+	if err != nil {
+		return "", fmt.Errorf("%s cannot be translated: %w", n, err)
+	}
+	return test, nil
+}
+
+// headerTest returns a Sieve test which tests given header fields
+// contain the value of search operator.
+func headerTest(fields []string, n query.Node) (string, error) {
+	switch n := n.(type) {
+	case query.Word:
+		return headerTest(fields, query.Or{Nodes: []query.Node{n}})
+	case query.Phrase:
+		return headerTest(fields, query.Or{Nodes: []query.Node{n}})
+	case query.Or:
+		keys, ok := keysOf(n.Nodes)
+		if ok {
+			return "header :contains " + stringList(fields) + " " + stringList(keys), nil
+		}
+		tests, err := headerTests(fields, n.Nodes)
+		if err != nil {
+			return "", err
+		}
+		return "anyof(" + strings.Join(tests, ", ") + ")", nil
+	case query.And:
+		tests, err := headerTests(fields, n.Nodes)
+		if err != nil {
+			return "", err
+		}
+		return "allof(" + strings.Join(tests, ", ") + ")", nil
+	case query.Not:
+		test, err := headerTest(fields, n.Node)
+		if err != nil {
+			return "", err
+		}
+		return "not " + test, nil
+	}
+	return "", fmt.Errorf("%s is not supported", n)
+}
+
+func headerTests(fields []string, nodes []query.Node) ([]string, error) {
+	tests := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		test, err := headerTest(fields, n)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, test)
+	}
+	return tests, nil
+}
+
+// keysOf returns the key list of Sieve test if all of given nodes are
+// words or phrases.
+func keysOf(nodes []query.Node) ([]string, bool) {
+	keys := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case query.Word:
+			keys = append(keys, n.Value)
+		case query.Phrase:
+			keys = append(keys, n.Value)
+		default:
+			return nil, false
+		}
+	}
+	return keys, true
+}
+
+// quote returns given string as Sieve quoted string.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// stringList returns given strings as Sieve string list. A single
+// string is written without brackets.
+func stringList(ss []string) string {
+	if len(ss) == 1 {
+		return quote(ss[0])
+	}
+	quoted := make([]string, 0, len(ss))
+	for _, s := range ss {
+		quoted = append(quoted, quote(s))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// commentText makes given string safe to be written in a line comment.
+func commentText(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
@@ -0,0 +1,135 @@
+package sieve
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+func TestWrite(t *testing.T) {
+	filters := []gmail.Filter{
+		{
+			Criteria: gmail.FilterCriteria{
+				From:    gmail.Terms{"a@example.com", "b@example.com"},
+				Subject: gmail.Terms{`"[FAILED]"`},
+			},
+			Action: gmail.FilterAction{
+				AddLabel: `CI/"Failed"`,
+				Star:     true,
+				Category: "new",
+			},
+		},
+		{
+			Criteria: gmail.FilterCriteria{
+				Query:        "has:attachment",
+				ExcludeChats: true,
+			},
+			Action: gmail.FilterAction{Delete: true},
+		},
+		{
+			Criteria: gmail.FilterCriteria{
+				To:         gmail.Terms{"team@example.com"},
+				LargerThan: 1000,
+			},
+			Action: gmail.FilterAction{ForwardTo: "me@example.org", Archive: true},
+		},
+	}
+	want := `# Generated by gmac.
+require ["fileinto", "imap4flags"];
+
+# filter #1: from:{a@example.com b@example.com} subject:"[FAILED]" => Star it, Apply label "CI/"Failed"", Categorize as new
+if allof(header :contains "from" ["a@example.com", "b@example.com"], header :contains "subject" "[FAILED]") {
+    # not translated: category: updates
+    addflag "\\Flagged";
+    fileinto "CI/\"Failed\"";
+    keep;
+}
+
+# filter #2: has:attachment -in:chats => Delete it
+# not translated: has:attachment cannot be translated, as Sieve has no equivalent of has:
+
+# filter #3: to:team@example.com larger:1000 => Skip Inbox, Forward to me@example.org
+if allof(header :contains ["to", "cc", "bcc"] "team@example.com", size :over 1000) {
+    redirect "me@example.org";
+}
+`
+	var buf bytes.Buffer
+	if err := Write(&buf, filters); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected script:\n%s\nwant:\n%s", got, want)
+		return
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	tests := []struct {
+		label  string
+		filter gmail.Filter
+	}{
+		{
+			label: "label and keep",
+			filter: gmail.Filter{
+				Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+				Action:   gmail.FilterAction{AddLabel: "foo", MarkAsRead: true},
+			},
+		},
+		{
+			label: "label and archive",
+			filter: gmail.Filter{
+				Criteria: gmail.FilterCriteria{Subject: gmail.Terms{`"hello world"`}, SmallerThan: 1 << 20},
+				Action:   gmail.FilterAction{AddLabel: "foo", Archive: true},
+			},
+		},
+		{
+			label: "nested criteria",
+			filter: gmail.Filter{
+				Criteria: gmail.FilterCriteria{
+					AnyOf: []gmail.FilterCriteria{
+						{From: gmail.Terms{"a@example.com"}},
+						{To: gmail.Terms{"b@example.com"}},
+					},
+					NoneOf: []gmail.FilterCriteria{{Subject: gmail.Terms{"spam"}}},
+				},
+				Action: gmail.FilterAction{Delete: true},
+			},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, []gmail.Filter{tt.filter}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			got, warnings, err := Parse(strings.NewReader(buf.String()))
+			if err != nil {
+				t.Errorf("unexpected error: %v\n%s", err, buf.String())
+				return
+			}
+			if len(warnings) > 0 {
+				t.Errorf("unexpected warnings: %q", warnings)
+				return
+			}
+			if len(got) != 1 || got[0].String() != tt.filter.String() {
+				t.Errorf("%v != %v\n%s", got, tt.filter, buf.String())
+				return
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	err := Write(&bytes.Buffer{}, []gmail.Filter{{
+		Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo"}},
+		Action:   gmail.FilterAction{Important: "sometimes"},
+	}})
+	if err == nil || err.Error() != "filter #1: unknown action.important value: sometimes" {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+}
@@ -0,0 +1,166 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StateBackend stores the state, which may be shared between multiple
+// users and machines. Lock must be acquired before loading the state to
+// be modified, and be held until the modified state is saved, so that
+// concurrent applies do not overwrite the state of each other.
+type StateBackend interface {
+	// Lock acquires the exclusive lock of the state, retrying until ctx
+	// is done. It returns *LockError if the state is locked by others.
+	// The lock is released by calling the returned function.
+	Lock(ctx context.Context, info LockInfo) (unlock func() error, err error)
+	// Load returns the state. If the state has not been saved yet, an
+	// empty state is returned.
+	Load(ctx context.Context) (*State, error)
+	// Save saves the state.
+	Save(ctx context.Context, s *State) error
+}
+
+// LockInfo is the information of a lock, which is shown to others when
+// they fail to acquire the lock.
+type LockInfo struct {
+	ID        string    `yaml:"id"`
+	Operation string    `yaml:"operation"`
+	Who       string    `yaml:"who"`
+	Created   time.Time `yaml:"created"`
+}
+
+// NewLockInfo returns LockInfo of given operation by the current user
+// with a new lock ID.
+func NewLockInfo(operation string) LockInfo {
+	who := "unknown"
+	if u, err := user.Current(); err == nil {
+		who = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		who += "@" + host
+	}
+	return LockInfo{
+		ID:        uuid.New().String(),
+		Operation: operation,
+		Who:       who,
+		Created:   time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// LockError is the error returned if the state is locked by others.
+type LockError struct {
+	// Info is the information of the current lock, which is zero if it
+	// cannot be read.
+	Info LockInfo
+	// Path is the path to the lock, which can be removed manually if
+	// the lock is left by a process which has exited.
+	Path string
+}
+
+func (e *LockError) Error() string {
+	msg := "state is locked"
+	if e.Info.ID != "" {
+		msg += fmt.Sprintf(" by %s for %s since %s (lock ID: %s)", e.Info.Who, e.Info.Operation, e.Info.Created.Format(time.RFC3339), e.Info.ID)
+	}
+	if e.Path != "" {
+		msg += fmt.Sprintf("; if no other gmac is running, remove %s", e.Path)
+	}
+	return msg
+}
+
+// lockRetryInterval is the interval to retry acquiring locks. It is
+// replaceable for testing purpose.
+var lockRetryInterval = time.Second
+
+// retryLock calls tryLock until it succeeds, it returns an error other
+// than *LockError, or ctx is done.
+func retryLock(ctx context.Context, tryLock func() error) error {
+	for {
+		err := tryLock()
+		if _, locked := err.(*LockError); !locked {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// BackendFunc returns StateBackend of given URL.
+type BackendFunc func(u *url.URL) (StateBackend, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]BackendFunc{}
+)
+
+func init() {
+	RegisterBackend("file", func(u *url.URL) (StateBackend, error) {
+		path := u.Host + u.Path
+		if path == "" {
+			return nil, fmt.Errorf("state: path is empty in %s", u)
+		}
+		return NewFileBackend(path), nil
+	})
+	RegisterBackend("dir", func(u *url.URL) (StateBackend, error) {
+		dir := u.Host + u.Path
+		if dir == "" {
+			return nil, fmt.Errorf("state: directory is empty in %s", u)
+		}
+		return NewDirBackend(dir, u.Query().Get("name"))
+	})
+}
+
+// RegisterBackend makes StateBackend available by the URL scheme, so
+// that OpenBackend returns it for URLs like "<scheme>://...". Library
+// users can add their own backends, e.g. a backend storing the state in
+// a database. RegisterBackend panics if the scheme is empty or is
+// already registered.
+func RegisterBackend(scheme string, newBackend BackendFunc) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if scheme == "" {
+		panic("state: scheme is empty")
+	}
+	if newBackend == nil {
+		panic("state: RegisterBackend function is nil")
+	}
+	if _, dup := backends[scheme]; dup {
+		panic("state: RegisterBackend called twice for " + scheme)
+	}
+	backends[scheme] = newBackend
+}
+
+// OpenBackend returns StateBackend of given location, which is a path
+// to the state file, or a URL of registered backend:
+//
+//   - file://<path>: the state file, same as the path
+//   - dir://<directory>?name=<name>: the directory which may be on a
+//     shared filesystem, storing the states of given names
+func OpenBackend(location string) (StateBackend, error) {
+	if !strings.Contains(location, "://") {
+		return NewFileBackend(location), nil
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("state: %w", err)
+	}
+	backendsMu.RLock()
+	newBackend, ok := backends[u.Scheme]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("state: unknown backend: %s", u.Scheme)
+	}
+	return newBackend(u)
+}
@@ -0,0 +1,286 @@
+package state
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+func testBackends(t *testing.T) (map[string]StateBackend, func()) {
+	dir, err := ioutil.TempDir("", "gmac-state-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirBackend, err := NewDirBackend(filepath.Join(dir, "shared"), "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]StateBackend{
+		"file": NewFileBackend(filepath.Join(dir, "state.yml")),
+		"dir":  dirBackend,
+	}, func() { os.RemoveAll(dir) }
+}
+
+func TestBackendLock(t *testing.T) {
+	backends, cleanup := testBackends(t)
+	defer cleanup()
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			info := NewLockInfo("apply")
+			unlock, err := backend.Lock(ctx, info)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expired, cancel := context.WithTimeout(ctx, 0)
+			defer cancel()
+			_, err = backend.Lock(expired, NewLockInfo("apply"))
+			lockErr, ok := err.(*LockError)
+			if !ok {
+				t.Errorf("LockError should be returned while locked: %v", err)
+				return
+			}
+			if lockErr.Info != info {
+				t.Errorf("%+v != %+v", lockErr.Info, info)
+				return
+			}
+
+			if err := unlock(); err != nil {
+				t.Fatal(err)
+			}
+			unlock, err = backend.Lock(ctx, NewLockInfo("apply"))
+			if err != nil {
+				t.Errorf("lock should be acquired after unlocked: %v", err)
+				return
+			}
+			if err := unlock(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestBackendLockRetry(t *testing.T) {
+	defer func(interval time.Duration) { lockRetryInterval = interval }(lockRetryInterval)
+	lockRetryInterval = 10 * time.Millisecond
+
+	backends, cleanup := testBackends(t)
+	defer cleanup()
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			unlock, err := backend.Lock(ctx, NewLockInfo("apply"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				unlock()
+			}()
+
+			timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			unlock2, err := backend.Lock(timeout, NewLockInfo("apply"))
+			if err != nil {
+				t.Errorf("lock should be acquired after retrying: %v", err)
+				return
+			}
+			if err := unlock2(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestBackendConcurrentUpdate(t *testing.T) {
+	defer func(interval time.Duration) { lockRetryInterval = interval }(lockRetryInterval)
+	lockRetryInterval = time.Millisecond
+
+	backends, cleanup := testBackends(t)
+	defer cleanup()
+
+	const n = 10
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			var wg sync.WaitGroup
+			errs := make(chan error, n)
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					unlock, err := backend.Lock(ctx, NewLockInfo("apply"))
+					if err != nil {
+						errs <- err
+						return
+					}
+					defer unlock()
+					s, err := backend.Load(ctx)
+					if err != nil {
+						errs <- err
+						return
+					}
+					s.Filters = append(s.Filters, Entry{Filter: gmail.Filter{ID: "id" + strconv.Itoa(i)}})
+					errs <- backend.Save(ctx, s)
+				}(i)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s, err := backend.Load(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(s.Filters) != n {
+				t.Errorf("updates are lost: %d filters != %d", len(s.Filters), n)
+				return
+			}
+		})
+	}
+}
+
+func TestUnlockRemovedLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gmac-state-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	backend := NewFileBackend(filepath.Join(dir, "state.yml"))
+	ctx := context.Background()
+	unlock, err := backend.Lock(ctx, NewLockInfo("apply"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the lock is removed manually, then acquired by others
+	if err := os.Remove(backend.lockPath()); err != nil {
+		t.Fatal(err)
+	}
+	unlock2, err := backend.Lock(ctx, NewLockInfo("apply"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock2()
+
+	if err := unlock(); err == nil {
+		t.Errorf("error should be occurred on unlocking the lock of others")
+		return
+	}
+	if _, err := os.Stat(backend.lockPath()); err != nil {
+		t.Errorf("the lock of others should not be removed: %v", err)
+		return
+	}
+}
+
+type memoryBackend struct {
+	StateBackend
+	host string
+}
+
+func TestOpenBackend(t *testing.T) {
+	RegisterBackend("test-memory", func(u *url.URL) (StateBackend, error) {
+		return &memoryBackend{host: u.Host}, nil
+	})
+	defer func() {
+		backendsMu.Lock()
+		delete(backends, "test-memory")
+		backendsMu.Unlock()
+	}()
+
+	tests := []struct {
+		label    string
+		location string
+		want     StateBackend
+	}{
+		{
+			label:    "path",
+			location: "/tmp/state.yml",
+			want:     &FileBackend{path: "/tmp/state.yml"},
+		},
+		{
+			label:    "relative path",
+			location: "state.yml",
+			want:     &FileBackend{path: "state.yml"},
+		},
+		{
+			label:    "file",
+			location: "file:///tmp/state.yml",
+			want:     &FileBackend{path: "/tmp/state.yml"},
+		},
+		{
+			label:    "dir",
+			location: "dir:///mnt/shared/gmac?name=alice",
+			want:     &DirBackend{dir: "/mnt/shared/gmac", name: "alice"},
+		},
+		{
+			label:    "dir with default name",
+			location: "dir:///mnt/shared/gmac",
+			want:     &DirBackend{dir: "/mnt/shared/gmac", name: DefaultName},
+		},
+		{
+			label:    "custom",
+			location: "test-memory://bucket",
+			want:     &memoryBackend{host: "bucket"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			got, err := OpenBackend(tt.location)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%#v != %#v", got, tt.want)
+				return
+			}
+		})
+	}
+}
+
+func TestOpenBackendError(t *testing.T) {
+	tests := []struct {
+		label    string
+		location string
+	}{
+		{
+			label:    "unknown scheme",
+			location: "s3://bucket/state.yml",
+		},
+		{
+			label:    "invalid name",
+			location: "dir:///mnt/shared/gmac?name=../alice",
+		},
+		{
+			label:    "empty path",
+			location: "file://",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			if _, err := OpenBackend(tt.location); err == nil {
+				t.Errorf("error should be occurred")
+				return
+			}
+		})
+	}
+}
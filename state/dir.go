@@ -0,0 +1,89 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/goccy/go-yaml"
+)
+
+// DefaultName is the name of the state in DirBackend if not specified.
+const DefaultName = "default"
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// DirBackend is StateBackend which stores the states in a directory,
+// which may be on a shared filesystem like NFS or SMB, so that teammates
+// and CI runners can share the states. The directory can store the
+// states of multiple names, e.g. one for each account, as
+// "<name>.yml". The state is locked by the directory "<name>.lock",
+// as creating a directory is atomic even on network filesystems.
+type DirBackend struct {
+	dir  string
+	name string
+}
+
+// NewDirBackend returns DirBackend of the state of given name in given
+// directory. DefaultName is used if name is empty.
+func NewDirBackend(dir, name string) (*DirBackend, error) {
+	if name == "" {
+		name = DefaultName
+	}
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("state: invalid name %q", name)
+	}
+	return &DirBackend{dir: dir, name: name}, nil
+}
+
+func (b *DirBackend) statePath() string {
+	return filepath.Join(b.dir, b.name+".yml")
+}
+
+func (b *DirBackend) lockPath() string {
+	return filepath.Join(b.dir, b.name+".lock")
+}
+
+func (b *DirBackend) lockInfoPath() string {
+	return filepath.Join(b.lockPath(), "info.yml")
+}
+
+func (b *DirBackend) Lock(ctx context.Context, info LockInfo) (func() error, error) {
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return nil, err
+	}
+	content, err := yaml.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	err = retryLock(ctx, func() error {
+		err := os.Mkdir(b.lockPath(), 0755)
+		if os.IsExist(err) {
+			return &LockError{Info: readLockInfo(b.lockInfoPath()), Path: b.lockPath()}
+		}
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(b.lockInfoPath(), content); err != nil {
+			os.RemoveAll(b.lockPath())
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		return unlock(b.lockPath(), b.lockInfoPath(), info)
+	}, nil
+}
+
+func (b *DirBackend) Load(context.Context) (*State, error) {
+	return Load(b.statePath())
+}
+
+func (b *DirBackend) Save(_ context.Context, s *State) error {
+	return s.Save(b.statePath())
+}
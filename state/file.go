@@ -0,0 +1,93 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+)
+
+// FileBackend is StateBackend which stores the state in a local file.
+// The state is locked by the lock file next to the state file, which is
+// created exclusively.
+type FileBackend struct {
+	path string
+}
+
+// NewFileBackend returns FileBackend of the state file of given path.
+// The lock file is the path with ".lock" suffix.
+func NewFileBackend(path string) *FileBackend {
+	return &FileBackend{path: path}
+}
+
+func (b *FileBackend) lockPath() string {
+	return b.path + ".lock"
+}
+
+func (b *FileBackend) Lock(ctx context.Context, info LockInfo) (func() error, error) {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return nil, err
+	}
+	content, err := yaml.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	err = retryLock(ctx, func() error {
+		f, err := os.OpenFile(b.lockPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			return &LockError{Info: readLockInfo(b.lockPath()), Path: b.lockPath()}
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(content); err != nil {
+			f.Close()
+			os.Remove(b.lockPath())
+			return err
+		}
+		return f.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		return unlock(b.lockPath(), b.lockPath(), info)
+	}, nil
+}
+
+func (b *FileBackend) Load(context.Context) (*State, error) {
+	return Load(b.path)
+}
+
+func (b *FileBackend) Save(_ context.Context, s *State) error {
+	return s.Save(b.path)
+}
+
+// readLockInfo reads the lock information from given file. The zero
+// value is returned if it cannot be read, e.g. the lock is just being
+// created.
+func readLockInfo(path string) LockInfo {
+	var info LockInfo
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return info
+	}
+	if err := yaml.Unmarshal(b, &info); err != nil {
+		return LockInfo{}
+	}
+	return info
+}
+
+// unlock removes the lock at given path after checking the lock is
+// still the one acquired with info, as the lock may be removed manually
+// and acquired by others.
+func unlock(path, infoPath string, info LockInfo) error {
+	current := readLockInfo(infoPath)
+	if current.ID != info.ID {
+		return fmt.Errorf("state: lock %s is not held, it may be removed manually", info.ID)
+	}
+	return os.RemoveAll(path)
+}
@@ -0,0 +1,152 @@
+// Package state records which filters in Gmail are managed by gmac.
+// Gmail filters have no field to store such metadata, so the IDs of the
+// filters created by gmac are kept in a state file with the definitions
+// they are created from.
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+// Version is the version of the state file format.
+const Version = 1
+
+// State is the set of filters managed by gmac.
+type State struct {
+	Version int     `yaml:"version"`
+	Filters []Entry `yaml:"filters"`
+}
+
+// Entry is a filter created by gmac. The ID of the embedded filter is
+// the ID in Gmail, and the criteria and action are the definition in
+// the resource file which the filter is created from.
+type Entry struct {
+	gmail.Filter `yaml:",inline"`
+	// Source is the resource file which the filter is defined in.
+	Source string `yaml:"source,omitempty"`
+}
+
+// New returns an empty state.
+func New() *State {
+	return &State{Version: Version}
+}
+
+// Load reads the state file of given path. If the file does not exist,
+// an empty state is returned, which means that gmac manages no filters.
+func Load(path string) (*State, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse parses the content of a state file.
+func Parse(b []byte) (*State, error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return New(), nil
+	}
+	var s State
+	if err := yaml.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("state: %w", err)
+	}
+	if s.Version > Version {
+		return nil, fmt.Errorf("state: unsupported version %d, please upgrade gmac", s.Version)
+	}
+	s.Version = Version
+	for i, e := range s.Filters {
+		if e.ID == "" {
+			return nil, fmt.Errorf("state: filter #%d: id is empty", i+1)
+		}
+	}
+	return &s, nil
+}
+
+// Marshal returns the content of the state file.
+func (s *State) Marshal() ([]byte, error) {
+	out := *s
+	if out.Filters == nil {
+		out.Filters = []Entry{}
+	}
+	return yaml.Marshal(out)
+}
+
+// Save writes the state into the file of given path. The file is
+// replaced atomically, so the state is not broken even if gmac is
+// interrupted while saving.
+func (s *State) Save(path string) error {
+	b, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+// writeFileAtomic writes the file by renaming a temporary file, so that
+// readers never see a partially written file.
+func writeFileAtomic(path string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".gmac-state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	// TempFile creates the file readable only by the owner, but the
+	// state may be shared by other users
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Lookup returns the entry of given filter ID. A nil state has no
+// entries.
+func (s *State) Lookup(id string) (Entry, bool) {
+	if s == nil {
+		return Entry{}, false
+	}
+	for _, e := range s.Filters {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Remove removes the entry of given filter ID if any.
+func (s *State) Remove(id string) {
+	filters := s.Filters[:0]
+	for _, e := range s.Filters {
+		if e.ID != id {
+			filters = append(filters, e)
+		}
+	}
+	s.Filters = filters
+}
+
+// IsManaged reports whether the filter of given ID is managed by gmac.
+func (s *State) IsManaged(id string) bool {
+	_, ok := s.Lookup(id)
+	return ok
+}
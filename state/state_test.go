@@ -0,0 +1,102 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/nasa9084/gmac/gmail"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gmac-state-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nested", "state.yml")
+
+	st, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(st, New()) {
+		t.Errorf("state should be empty if the file does not exist: %+v", st)
+		return
+	}
+
+	st.Filters = append(st.Filters, Entry{
+		Filter: gmail.Filter{
+			ID:       "id1",
+			Criteria: gmail.FilterCriteria{From: gmail.Terms{"foo@example.com"}},
+			Action:   gmail.FilterAction{AddLabel: "Foo", Archive: true},
+		},
+		Source: "filters.yml",
+	})
+	if err := st.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `version: 1
+filters:
+- id: id1
+  criteria:
+    from: foo@example.com
+  action:
+    archive: true
+    add_label: Foo
+  source: filters.yml
+`
+	if string(b) != want {
+		t.Errorf("unexpected state file:\n%s\nwant:\n%s", b, want)
+		return
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, st) {
+		t.Errorf("%+v != %+v", loaded, st)
+		return
+	}
+	if !loaded.IsManaged("id1") || loaded.IsManaged("id2") {
+		t.Errorf("unexpected IsManaged result")
+		return
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		label string
+		input string
+	}{
+		{
+			label: "future version",
+			input: "version: 2\nfilters: []\n",
+		},
+		{
+			label: "empty id",
+			input: "version: 1\nfilters:\n- criteria:\n    from: foo@example.com\n",
+		},
+		{
+			label: "invalid yaml",
+			input: "version: [",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i)+"."+tt.label, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.input)); err == nil {
+				t.Errorf("error should be occurred")
+				return
+			}
+		})
+	}
+}